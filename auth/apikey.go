@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	// ScopeAnswersRead read answers, their revisions and search
+	ScopeAnswersRead = "answers:read"
+	// ScopeAnswersWrite add, change, vote for and delete answers
+	ScopeAnswersWrite = "answers:write"
+	// ScopeAnswersAdmin moderate answers, e.g. take them back from trash
+	ScopeAnswersAdmin = "answers:admin"
+	// ScopeStatsRead read service usage statistic
+	ScopeStatsRead = "stats:read"
+)
+
+// Scopes every known scope
+var Scopes = []string{ScopeAnswersRead, ScopeAnswersWrite, ScopeAnswersAdmin, ScopeStatsRead}
+
+// apiKeyPrefix marks keys of this service, so a leaked key is easy to recognize
+const apiKeyPrefix = "ak_"
+
+// NewAPIKey returns random key and its hash. The key is shown to the caller once, only the hash is stored.
+func NewAPIKey() (key string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns stored form of key. Keys are random enough to not need salt or slow hashing.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyPrefix returns beginning of key which tells keys apart in listings
+func KeyPrefix(key string) string {
+	if len(key) > len(apiKeyPrefix)+8 {
+		return key[:len(apiKeyPrefix)+8]
+	}
+	return key
+}
+
+// KeyIdentity describes service calling with API key. Services act on behalf of users,
+// keys with ScopeAnswersAdmin moderate answers as well.
+func KeyIdentity(id int, name string, scopes []string) *Identity {
+	who := &Identity{
+		Subject: "key:" + name,
+		KeyID:   id,
+		Roles:   []string{RoleService},
+		Scopes:  scopes,
+	}
+	if who.HasScope(ScopeAnswersAdmin) {
+		who.Roles = append(who.Roles, RoleModerator)
+	}
+	return who
+}
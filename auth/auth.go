@@ -0,0 +1,219 @@
+// Package auth verifies JWT bearer tokens and describes who is calling the service
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	jwt "github.com/golang-jwt/jwt"
+)
+
+const (
+	// RoleModerator may change and delete any answer, see deleted answers and restore them
+	RoleModerator = "moderator"
+	// RoleService is given to other services, they may act on behalf of any user and delete in bulk
+	RoleService = "service"
+)
+
+// Identity caller described by token claims
+type Identity struct {
+	// Subject token "sub" claim, UserID is set when it is a number
+	Subject  string
+	UserID   int
+	Nickname string
+	Roles    []string
+	// Scopes allowed to caller, see ScopeAnswersRead and others
+	Scopes []string
+	// KeyID is set for services calling with API key
+	KeyID int
+}
+
+// Anonymous identity of caller without credentials, it may only read answers
+func Anonymous() *Identity {
+	return &Identity{Scopes: []string{ScopeAnswersRead}}
+}
+
+// HasRole reports whether caller has any of roles
+func (id *Identity) HasRole(roles ...string) bool {
+	for _, have := range id.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScope reports whether caller is allowed scope
+func (id *Identity) HasScope(scope string) bool {
+	for _, have := range id.Scopes {
+		if have == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// userScopes scopes of token holders: everyone reads and writes answers,
+// moderators administer them, moderators and services read usage statistic
+func userScopes(roles []string) []string {
+	who := Identity{Roles: roles}
+	scopes := []string{ScopeAnswersRead, ScopeAnswersWrite}
+	if who.HasRole(RoleModerator) {
+		scopes = append(scopes, ScopeAnswersAdmin)
+	}
+	if who.HasRole(RoleModerator, RoleService) {
+		scopes = append(scopes, ScopeStatsRead)
+	}
+	return scopes
+}
+
+// Verifier checks token signature, lifetime, issuer and audience
+type Verifier struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+}
+
+// NewVerifier loads keys of cfg. It returns nil verifier when authentication is disabled.
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	v := &Verifier{
+		secret:   []byte(cfg.HS256Secret),
+		keys:     make(map[string]*rsa.PublicKey),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+	if cfg.RS256KeyFile != "" {
+		content, err := ioutil.ReadFile(cfg.RS256KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", cfg.RS256KeyFile, err.Error())
+		}
+		v.keys[""] = key
+	}
+	if cfg.JWKSFile != "" {
+		content, err := ioutil.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		if err = v.addJWKS(content); err != nil {
+			return nil, fmt.Errorf("%s: %s", cfg.JWKSFile, err.Error())
+		}
+	}
+	return v, nil
+}
+
+// addJWKS adds RSA keys of JSON Web Key Set, keys of other types are skipped
+func (v *Verifier) addJWKS(content []byte) error {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return err
+	}
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("key %q: %s", k.Kid, err.Error())
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("key %q: %s", k.Kid, err.Error())
+		}
+		v.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(v.keys) == 0 {
+		return errors.New("no RSA keys found")
+	}
+	return nil
+}
+
+// key returns key verifying token signature
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		if len(v.secret) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return v.secret, nil
+	case jwt.SigningMethodRS256:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.keys[kid]; ok {
+			return key, nil
+		}
+		if len(v.keys) == 1 && kid == "" {
+			for _, key := range v.keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+}
+
+// Verify returns identity of valid token. Tokens must expire.
+// Claims used besides the registered ones are "nickname" (string) and "roles" (array of strings).
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	c := jwt.MapClaims{}
+	parser := jwt.Parser{ValidMethods: []string{"HS256", "RS256"}}
+	if _, err := parser.ParseWithClaims(token, c, v.key); err != nil {
+		return nil, err
+	}
+
+	// parser checks exp only when it is set
+	if !c.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("token has no expiry")
+	}
+
+	if v.issuer != "" && !c.VerifyIssuer(v.issuer, true) {
+		return nil, errors.New("unexpected token issuer")
+	}
+	if v.audience != "" && !c.VerifyAudience(v.audience, true) {
+		return nil, errors.New("unexpected token audience")
+	}
+
+	id := &Identity{}
+	id.Subject, _ = c["sub"].(string)
+	if id.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	id.UserID, _ = strconv.Atoi(id.Subject)
+	id.Nickname, _ = c["nickname"].(string)
+	roles, _ := c["roles"].([]interface{})
+	for _, r := range roles {
+		if role, ok := r.(string); ok {
+			id.Roles = append(id.Roles, role)
+		}
+	}
+	id.Scopes = userScopes(id.Roles)
+	return id, nil
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, content []byte) string {
+	f, err := ioutil.TempFile("", "answer-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func claimsOf(sub string, roles ...string) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub":      sub,
+		"nickname": "Test",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+	if len(roles) > 0 {
+		c["roles"] = roles
+	}
+	return c
+}
+
+func TestDisabled(t *testing.T) {
+	v, err := NewVerifier(config.AuthConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v, err := NewVerifier(config.AuthConfig{HS256Secret: "secret"})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	who, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", claimsOf("7", RoleModerator)))
+	if assert.Nil(t, err) {
+		assert.Equal(t, &Identity{
+			Subject:  "7",
+			UserID:   7,
+			Nickname: "Test",
+			Roles:    []string{RoleModerator},
+			Scopes:   []string{ScopeAnswersRead, ScopeAnswersWrite, ScopeAnswersAdmin, ScopeStatsRead},
+		}, who)
+		assert.True(t, who.HasRole(RoleService, RoleModerator))
+		assert.False(t, who.HasRole(RoleService))
+	}
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte("other"), "", claimsOf("7")))
+	assert.NotNil(t, err, "wrong secret")
+	noSubject := claimsOf("7")
+	delete(noSubject, "sub")
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", noSubject))
+	if assert.NotNil(t, err, "no subject") {
+		assert.Equal(t, "token has no subject", err.Error())
+	}
+	_, err = v.Verify("not a token")
+	assert.NotNil(t, err)
+}
+
+func TestVerifyExpired(t *testing.T) {
+	v, _ := NewVerifier(config.AuthConfig{HS256Secret: "secret"})
+	c := claimsOf("7")
+	c["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", c))
+	assert.NotNil(t, err)
+}
+
+func TestVerifyNoExpiry(t *testing.T) {
+	v, _ := NewVerifier(config.AuthConfig{HS256Secret: "secret"})
+	c := claimsOf("7")
+	delete(c, "exp")
+	_, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", c))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "token has no expiry", err.Error())
+	}
+}
+
+func TestVerifyIssuerAudience(t *testing.T) {
+	v, _ := NewVerifier(config.AuthConfig{HS256Secret: "secret", Issuer: "users", Audience: "answer"})
+
+	c := claimsOf("7")
+	c["iss"] = "users"
+	c["aud"] = []string{"question", "answer"}
+	_, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", c))
+	assert.Nil(t, err)
+
+	c["aud"] = "question"
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", c))
+	assert.NotNil(t, err, "wrong audience")
+
+	c["aud"] = "answer"
+	c["iss"] = "somebody"
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", c))
+	assert.NotNil(t, err, "wrong issuer")
+}
+
+func TestVerifyRS256KeyFile(t *testing.T) {
+	key := generateKey(t)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	file := writeFile(t, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	defer os.Remove(file)
+
+	v, err := NewVerifier(config.AuthConfig{RS256KeyFile: file})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	who, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, "", claimsOf("service-a", RoleService)))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "service-a", who.Subject)
+		assert.Equal(t, 0, who.UserID)
+		assert.True(t, who.HasRole(RoleService))
+		assert.True(t, who.HasScope(ScopeStatsRead))
+		assert.False(t, who.HasScope(ScopeAnswersAdmin))
+	}
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, generateKey(t), "", claimsOf("7")))
+	assert.NotNil(t, err, "other key")
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", claimsOf("7")))
+	assert.NotNil(t, err, "HS256 is not configured")
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	first, second := generateKey(t), generateKey(t)
+	jwk := func(kid string, key *rsa.PrivateKey) string {
+		return fmt.Sprintf(`{"kty": "RSA", "kid": %q, "n": %q, "e": %q}`, kid,
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	}
+	file := writeFile(t, []byte(fmt.Sprintf(`{"keys": [%s, {"kty": "EC", "kid": "ec"}, %s]}`,
+		jwk("first", first), jwk("second", second))))
+	defer os.Remove(file)
+
+	v, err := NewVerifier(config.AuthConfig{JWKSFile: file})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, first, "first", claimsOf("7")))
+	assert.Nil(t, err)
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, second, "second", claimsOf("7")))
+	assert.Nil(t, err)
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, first, "second", claimsOf("7")))
+	assert.NotNil(t, err, "key of other kid")
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, first, "", claimsOf("7")))
+	assert.NotNil(t, err, "no kid with many keys")
+}
+
+func TestBrokenKeyFiles(t *testing.T) {
+	file := writeFile(t, []byte("{\"keys\": []}"))
+	defer os.Remove(file)
+
+	_, err := NewVerifier(config.AuthConfig{JWKSFile: file})
+	assert.NotNil(t, err)
+	_, err = NewVerifier(config.AuthConfig{RS256KeyFile: file})
+	assert.NotNil(t, err)
+}
+
+func TestAPIKey(t *testing.T) {
+	key, hash, err := NewAPIKey()
+	if assert.Nil(t, err) {
+		assert.Equal(t, HashAPIKey(key), hash)
+		assert.NotEqual(t, key, hash)
+		assert.Equal(t, key[:11], KeyPrefix(key))
+	}
+
+	other, _, _ := NewAPIKey()
+	assert.NotEqual(t, key, other)
+}
+
+func TestKeyIdentity(t *testing.T) {
+	who := KeyIdentity(3, "gateway", []string{ScopeAnswersRead, ScopeAnswersWrite})
+	assert.Equal(t, "key:gateway", who.Subject)
+	assert.Equal(t, 3, who.KeyID)
+	assert.True(t, who.HasRole(RoleService))
+	assert.False(t, who.HasRole(RoleModerator))
+	assert.False(t, who.HasScope(ScopeStatsRead))
+
+	admin := KeyIdentity(4, "question", []string{ScopeAnswersAdmin})
+	assert.True(t, admin.HasRole(RoleModerator))
+}
+
+func TestAnonymous(t *testing.T) {
+	who := Anonymous()
+	assert.True(t, who.HasScope(ScopeAnswersRead))
+	assert.False(t, who.HasScope(ScopeAnswersWrite))
+}
@@ -0,0 +1,400 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RSOI/answer/model"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "answer*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestLoadDefaults(t *testing.T) {
+	// migrations directory is relative to the repository root
+	_, _, err := Load(nil, env(nil))
+	assert.NotNil(t, err)
+
+	cfg, rest, err := Load([]string{"-migrations", "../database/migrations"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, ":8081", cfg.Listen)
+		assert.Equal(t, "postgres", cfg.Backend)
+		assert.Equal(t, 50, cfg.DB.PoolSize)
+		assert.Equal(t, 20, cfg.PageSize)
+		assert.Equal(t, "logfmt", cfg.LogFormat)
+		assert.Equal(t, 0, len(rest))
+	}
+}
+
+func TestLoadMaxPageSize(t *testing.T) {
+	cfg, _, err := Load([]string{"-max-page-size", "50", "memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 50, cfg.MaxPageSize)
+	}
+
+	_, _, err = Load([]string{"-page-size", "40", "-max-page-size", "30", "memory"}, env(nil))
+	assert.NotNil(t, err, "max page size is less than page size")
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_MAX_PAGE_SIZE": "5000"}))
+	assert.NotNil(t, err)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, "listen: \":9000\"\nbackend: memory\npage_size: 30\nlog_level: warn\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load(
+		[]string{"-config", path, "-page-size", "40"},
+		env(map[string]string{"ANSWER_LISTEN": ":9001", "ANSWER_PAGE_SIZE": "35", "ANSWER_LOG_FORMAT": "json"}),
+	)
+	if assert.Nil(t, err) {
+		assert.Equal(t, ":9001", cfg.Listen, "env overrides file")
+		assert.Equal(t, 40, cfg.PageSize, "flag overrides env")
+		assert.Equal(t, "memory", cfg.Backend, "file overrides default")
+		assert.Equal(t, "warn", cfg.LogLevel)
+		assert.Equal(t, "json", cfg.LogFormat)
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	path := writeConfig(t, "backend: memory\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load(nil, env(map[string]string{"ANSWER_CONFIG": path}))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "memory", cfg.Backend)
+	}
+}
+
+func TestLoadPositionalArgs(t *testing.T) {
+	cfg, rest, err := Load([]string{"debug", "memory", "migrate", "up"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "debug", cfg.LogLevel)
+		assert.Equal(t, "memory", cfg.Backend)
+		assert.Equal(t, []string{"migrate", "up"}, rest)
+	}
+}
+
+func TestLoadUnknownFileField(t *testing.T) {
+	path := writeConfig(t, "backend: memory\nport: 8081\n")
+	defer os.Remove(path)
+
+	_, _, err := Load([]string{"-config", path}, env(nil))
+	assert.NotNil(t, err)
+}
+
+func TestLoadBrokenEnvNumber(t *testing.T) {
+	_, _, err := Load([]string{"memory"}, env(map[string]string{"ANSWER_PAGE_SIZE": "many"}))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ANSWER_PAGE_SIZE")
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Listen = "8081"
+	cfg.DB.DSN = "mysql://localhost"
+	cfg.DB.PoolSize = 0
+	cfg.DB.Migrations = "not/existing"
+	cfg.LogLevel = "verbose"
+	cfg.LogFormat = "xml"
+	cfg.PageSize = 0
+
+	err := cfg.Validate()
+	if assert.NotNil(t, err) {
+		for _, field := range []string{"listen", "db.dsn", "db.pool_size", "db.migrations", "log_level", "log_format", "page_size"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	}
+}
+
+func TestLoadTrashDurations(t *testing.T) {
+	path := writeConfig(t, "backend: memory\ntrash:\n  retention: 48h\n  purge_interval: 48h\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load([]string{"-config", path}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 48*time.Hour, cfg.Trash.Retention)
+		assert.Equal(t, 48*time.Hour, cfg.Trash.PurgeInterval)
+	}
+
+	cfg, _, err = Load(
+		[]string{"-config", path, "-trash-retention", "0"},
+		env(map[string]string{"ANSWER_TRASH_PURGE_INTERVAL": "10m"}),
+	)
+	if assert.Nil(t, err) {
+		assert.Equal(t, time.Duration(0), cfg.Trash.Retention)
+		assert.Equal(t, 10*time.Minute, cfg.Trash.PurgeInterval)
+	}
+
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_TRASH_RETENTION": "month"}))
+	assert.NotNil(t, err)
+
+	broken := writeConfig(t, "backend: memory\ntrash:\n  retention: month\n")
+	defer os.Remove(broken)
+	_, _, err = Load([]string{"-config", broken}, env(nil))
+	assert.NotNil(t, err)
+
+	_, _, err = Load([]string{"-trash-purge-interval", "0", "memory"}, env(nil))
+	assert.NotNil(t, err, "retention without purge interval")
+}
+
+func TestLoadOutboxRetention(t *testing.T) {
+	cfg, _, err := Load([]string{"memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 7*24*time.Hour, cfg.Outbox.Retention)
+	}
+
+	path := writeConfig(t, "backend: memory\noutbox:\n  retention: 24h\n")
+	defer os.Remove(path)
+	cfg, _, err = Load([]string{"-config", path}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 24*time.Hour, cfg.Outbox.Retention)
+	}
+
+	cfg, _, err = Load([]string{"-outbox-retention", "0", "memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, time.Duration(0), cfg.Outbox.Retention)
+	}
+
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_OUTBOX_RETENTION": "-1h"}))
+	assert.NotNil(t, err, "negative retention")
+
+	_, _, err = Load([]string{"-trash-retention", "0", "-trash-purge-interval", "0", "memory"}, env(nil))
+	assert.NotNil(t, err, "outbox retention without purge interval")
+}
+
+func TestLoadBrokenDurations(t *testing.T) {
+	for section, field := range map[string]string{"trash": "purge_interval", "shutdown": "timeout", "stats": "flush_interval",
+		"outbox": "max_backoff", "webhook": "interval", "question": "breaker_cooldown"} {
+		path := writeConfig(t, "backend: memory\n"+section+":\n  "+field+": soon\n")
+		defer os.Remove(path)
+
+		_, _, err := Load([]string{"-config", path}, env(nil))
+		if assert.NotNil(t, err, section) {
+			assert.Contains(t, err.Error(), section+"."+field)
+		}
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	cfg, _, err := Load([]string{"memory"}, env(map[string]string{"ANSWER_AUTH_API_KEYS": "true"}))
+	if assert.Nil(t, err) {
+		assert.True(t, cfg.Auth.APIKeys)
+	}
+
+	cfg, _, err = Load([]string{"-auth-api-keys=false", "memory"}, env(map[string]string{"ANSWER_AUTH_API_KEYS": "true"}))
+	if assert.Nil(t, err) {
+		assert.False(t, cfg.Auth.APIKeys)
+	}
+
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_AUTH_API_KEYS": "sure"}))
+	assert.NotNil(t, err)
+}
+
+func TestLoadOpenAdmin(t *testing.T) {
+	cfg, _, err := Load([]string{"memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.False(t, cfg.Auth.OpenAdmin, "admin routes are closed by default")
+		assert.False(t, cfg.Webhook.AllowPrivateHosts)
+	}
+
+	cfg, _, err = Load([]string{"-auth-open-admin", "memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.True(t, cfg.Auth.OpenAdmin)
+	}
+	cfg, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_AUTH_OPEN_ADMIN": "true"}))
+	if assert.Nil(t, err) {
+		assert.True(t, cfg.Auth.OpenAdmin)
+	}
+}
+
+func TestLoadRateLimit(t *testing.T) {
+	cfg, _, err := Load([]string{"memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "memory", cfg.RateLimit.Store)
+		assert.Equal(t, Rate{Requests: 30, Per: time.Minute}, cfg.RateLimit.Write)
+		assert.Equal(t, Rate{Requests: 600, Per: time.Minute}, cfg.RateLimit.Keys)
+	}
+
+	path := writeConfig(t, "backend: memory\nrate_limit:\n  read: 10/1s\n  write: 0\n")
+	defer os.Remove(path)
+	cfg, _, err = Load(
+		[]string{"-config", path, "-rate-limit-admin", "5/1h", "-rate-limit-keys", "0"},
+		env(map[string]string{"ANSWER_RATE_LIMIT_WRITE": "2/10s"}),
+	)
+	if assert.Nil(t, err) {
+		assert.Equal(t, Rate{Requests: 10, Per: time.Second}, cfg.RateLimit.Read)
+		assert.Equal(t, Rate{Requests: 2, Per: 10 * time.Second}, cfg.RateLimit.Write)
+		assert.Equal(t, Rate{Requests: 5, Per: time.Hour}, cfg.RateLimit.Admin)
+		assert.Equal(t, "5/1h0m0s", cfg.RateLimit.Admin.String())
+		assert.Equal(t, Rate{}, cfg.RateLimit.Keys)
+	}
+
+	for _, broken := range []string{"10", "ten/1m", "-1/1m", "10/never", "10/0s"} {
+		_, _, err = Load([]string{"-rate-limit-read", broken, "memory"}, env(nil))
+		assert.NotNil(t, err, broken)
+	}
+
+	_, _, err = Load([]string{"-rate-limit-store", "postgres", "memory"}, env(nil))
+	assert.NotNil(t, err, "postgres store with memory backend")
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_RATE_LIMIT_STORE": "redis"}))
+	assert.NotNil(t, err)
+}
+
+func TestLoadTracing(t *testing.T) {
+	cfg, _, err := Load([]string{"memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "none", cfg.Tracing.Exporter)
+	}
+
+	cfg, _, err = Load(
+		[]string{"-tracing-exporter", "otlp", "memory"},
+		env(map[string]string{"ANSWER_TRACING_ENDPOINT": "collector:4318"}),
+	)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "otlp", cfg.Tracing.Exporter)
+		assert.Equal(t, "collector:4318", cfg.Tracing.Endpoint)
+	}
+
+	_, _, err = Load([]string{"-tracing-exporter", "jaeger", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-tracing-exporter", "otlp", "-tracing-endpoint", "", "memory"}, env(nil))
+	assert.NotNil(t, err)
+}
+
+func TestLoadShutdown(t *testing.T) {
+	path := writeConfig(t, "backend: memory\nshutdown:\n  drain_delay: 0s\n  timeout: 1m\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load([]string{"-config", path}, env(map[string]string{"ANSWER_SHUTDOWN_TIMEOUT": "45s"}))
+	if assert.Nil(t, err) {
+		assert.Equal(t, time.Duration(0), cfg.Shutdown.DrainDelay)
+		assert.Equal(t, 45*time.Second, cfg.Shutdown.Timeout)
+	}
+
+	_, _, err = Load([]string{"-shutdown-timeout", "0", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-shutdown-drain-delay", "-1s", "memory"}, env(nil))
+	assert.NotNil(t, err)
+}
+
+func TestLoadStats(t *testing.T) {
+	path := writeConfig(t, "backend: memory\nstats:\n  batch_size: 100\n  flush_interval: 250ms\n  retention: 0s\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load([]string{"-config", path, "-stats-overflow", "block"}, env(map[string]string{"ANSWER_STATS_QUEUE_SIZE": "50"}))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 50, cfg.Stats.QueueSize)
+		assert.Equal(t, 100, cfg.Stats.BatchSize)
+		assert.Equal(t, 250*time.Millisecond, cfg.Stats.FlushInterval)
+		assert.Equal(t, "block", cfg.Stats.Overflow)
+		assert.Equal(t, time.Duration(0), cfg.Stats.Retention)
+		assert.Equal(t, 5*time.Minute, cfg.Stats.RollupInterval)
+	}
+
+	_, _, err = Load([]string{"-stats-overflow", "wait", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-stats-retention", "24h", "memory"}, env(nil))
+	if assert.NotNil(t, err, "retention shorter than 7d window") {
+		assert.Contains(t, err.Error(), "stats.retention")
+	}
+	_, _, err = Load([]string{"-stats-retention", "168h", "memory"}, env(nil))
+	assert.Nil(t, err)
+	_, _, err = Load([]string{"-stats-batch-size", "20000", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	cfg, _, err = Load([]string{"-stats-batch-size", strconv.Itoa(65535 / model.StatColumns), "memory"}, env(nil))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 6553, cfg.Stats.BatchSize, "largest batch fits statement parameters")
+	}
+	_, _, err = Load([]string{"-stats-batch-size", strconv.Itoa(65535/model.StatColumns + 1), "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-stats-flush-interval", "0s", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-stats-rollup-interval", "0s", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_STATS_RETENTION": "-1h"}))
+	assert.NotNil(t, err)
+}
+
+func TestLoadOutbox(t *testing.T) {
+	path := writeConfig(t, "backend: memory\noutbox:\n  publisher: none\n  max_backoff: 1m\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load([]string{"-config", path, "-outbox-batch-size", "10"}, env(map[string]string{"ANSWER_OUTBOX_INTERVAL": "5s"}))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "none", cfg.Outbox.Publisher)
+		assert.Equal(t, 10, cfg.Outbox.BatchSize)
+		assert.Equal(t, 5*time.Second, cfg.Outbox.Interval)
+		assert.Equal(t, time.Minute, cfg.Outbox.MaxBackoff)
+	}
+
+	_, _, err = Load([]string{"-outbox-publisher", "kafka", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-outbox-max-backoff", "0s", "memory"}, env(nil))
+	assert.NotNil(t, err)
+}
+
+func TestLoadWebhook(t *testing.T) {
+	path := writeConfig(t, "backend: memory\noutbox:\n  publisher: webhook\nwebhook:\n  timeout: 3s\n  max_attempts: 5\n  allow_private_hosts: true\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load([]string{"-config", path, "-webhook-max-backoff", "10m"}, env(map[string]string{"ANSWER_WEBHOOK_BATCH_SIZE": "20"}))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "webhook", cfg.Outbox.Publisher)
+		assert.Equal(t, 3*time.Second, cfg.Webhook.Timeout)
+		assert.Equal(t, 5, cfg.Webhook.MaxAttempts)
+		assert.Equal(t, 10*time.Minute, cfg.Webhook.MaxBackoff)
+		assert.Equal(t, 20, cfg.Webhook.BatchSize)
+		assert.Equal(t, time.Second, cfg.Webhook.Interval)
+		assert.True(t, cfg.Webhook.AllowPrivateHosts)
+	}
+
+	_, _, err = Load([]string{"-webhook-max-attempts", "0", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-webhook-timeout", "0s", "memory"}, env(nil))
+	assert.NotNil(t, err)
+}
+
+func TestLoadQuestion(t *testing.T) {
+	path := writeConfig(t, "backend: memory\nquestion:\n  url: http://localhost:8082\n  breaker_cooldown: 1m\n")
+	defer os.Remove(path)
+
+	cfg, _, err := Load([]string{"-config", path, "-question-retries", "0", "-question-policy", "fail-open"},
+		env(map[string]string{"ANSWER_QUESTION_TIMEOUT": "500ms", "ANSWER_QUESTION_API_KEY": "ak_secret"}))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "http://localhost:8082", cfg.Question.URL)
+		assert.Equal(t, "ak_secret", cfg.Question.APIKey)
+		assert.Equal(t, 500*time.Millisecond, cfg.Question.Timeout)
+		assert.Equal(t, 0, cfg.Question.Retries)
+		assert.Equal(t, 5, cfg.Question.BreakerThreshold)
+		assert.Equal(t, time.Minute, cfg.Question.BreakerCooldown)
+		assert.Equal(t, "fail-open", cfg.Question.Policy)
+	}
+
+	_, _, err = Load([]string{"-question-url", "localhost:8082", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"-question-policy", "retry", "memory"}, env(nil))
+	assert.NotNil(t, err)
+	_, _, err = Load([]string{"memory"}, env(map[string]string{"ANSWER_QUESTION_BREAKER_THRESHOLD": "0"}))
+	assert.NotNil(t, err)
+}
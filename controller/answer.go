@@ -1,37 +1,95 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/config"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/question"
+	"github.com/RSOI/answer/ui"
 	"github.com/RSOI/answer/utils"
 	"github.com/RSOI/answer/view"
 )
 
-// AnswerPUT new answer
-func AnswerPUT(body []byte) (*model.Answer, error) {
+// AnswerPUT new answer, author is taken from caller token
+func AnswerPUT(ctx context.Context, body []byte, who *auth.Identity) (*model.Answer, error) {
 	var err error
 
 	var NewAnswer model.Answer
 	err = json.Unmarshal(body, &NewAnswer)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Broken body. Error: %s", err.Error()))
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = setAuthor(who, &NewAnswer)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
 		return nil, err
 	}
 
 	err = view.ValidateNewAnswer(NewAnswer)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Validation error: %s", err.Error()))
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = checkQuestion(ctx, NewAnswer.QuestionID)
+	if err != nil {
+		utils.Debug("Question error", utils.Fields{"err": err, "question_id": NewAnswer.QuestionID})
 		return nil, err
 	}
 
-	NewAnswer, err = AnswerModel.AddAnswer(NewAnswer)
+	NewAnswer, err = storage(ctx).AddAnswer(NewAnswer)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Data error: %s", err.Error()))
+		logDataError(err)
 		return nil, err
 	}
 
-	utils.LOG("New answer added successfully")
+	utils.Debug("New answer added successfully")
 	return &NewAnswer, nil
 }
+
+// QuestionSource finds questions of new answers, *question.Client is one
+type QuestionSource interface {
+	GetQuestion(ctx context.Context, id int) (question.Question, error)
+}
+
+var (
+	// Questions checks questions of new answers, nil disables the check
+	Questions QuestionSource
+	// QuestionFailOpen accepts answers unchecked while question service is unavailable
+	QuestionFailOpen bool
+)
+
+// InitQuestions sets up question checks of new answers, nothing is checked while cfg.URL is empty
+func InitQuestions(cfg config.QuestionConfig) {
+	Questions = nil
+	if cfg.URL != "" {
+		utils.Debug("Setup question service client...", utils.Fields{"url": cfg.URL})
+		Questions = question.NewClient(cfg)
+	}
+	QuestionFailOpen = cfg.Policy == "fail-open"
+}
+
+// checkQuestion rejects answers to unknown, deleted and locked questions.
+// While question service is unavailable answers are rejected or, failing open, accepted unchecked.
+func checkQuestion(ctx context.Context, id int) error {
+	if Questions == nil {
+		return nil
+	}
+
+	q, err := Questions.GetQuestion(ctx, id)
+	switch {
+	case err == question.ErrNotFound:
+		return ui.ErrQuestionNotFound
+	case err != nil && QuestionFailOpen:
+		utils.Warn("Answer is accepted without question check", utils.Fields{"question_id": id, "err": err})
+		return nil
+	case err != nil:
+		return ui.ErrQuestionUnavailable
+	}
+	return view.ValidateQuestion(q)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
+)
+
+// CheckAPIKey returns identity of service holding key
+func CheckAPIKey(ctx context.Context, key string) (*auth.Identity, error) {
+	k, err := storage(ctx).GetAPIKeyByHash(auth.HashAPIKey(key))
+	if err == ui.ErrNoResult {
+		utils.Debug("Unknown or revoked API key")
+		return nil, ui.ErrUnauthorized
+	}
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+	return auth.KeyIdentity(k.ID, k.Name, k.Scopes), nil
+}
+
+// CreateAPIKey stores new key and returns it in plain text, it can't be shown later
+func CreateAPIKey(ctx context.Context, name string, scopes []string) (*model.APIKey, string, error) {
+	NewKey := model.APIKey{Name: name, Scopes: scopes}
+	err := view.ValidateAPIKey(NewKey)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, "", err
+	}
+
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	NewKey.Prefix = auth.KeyPrefix(key)
+	NewKey.Hash = hash
+
+	NewKey, err = storage(ctx).AddAPIKey(NewKey)
+	if err != nil {
+		logDataError(err)
+		return nil, "", err
+	}
+	utils.Debug("API key created successfully")
+	return &NewKey, key, nil
+}
+
+// APIKeysGET lists keys with their usage
+func APIKeysGET(ctx context.Context) ([]model.APIKey, error) {
+	data, err := storage(ctx).GetAPIKeys()
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// RevokeAPIKey disables key
+func RevokeAPIKey(ctx context.Context, id string) error {
+	kID, err := strconv.Atoi(id)
+	if err != nil || kID <= 0 {
+		return ui.ErrInvalidParameter
+	}
+
+	err = storage(ctx).RevokeAPIKey(kID)
+	if err != nil {
+		logDataError(err)
+		return err
+	}
+	utils.Debug("API key revoked successfully")
+	return nil
+}
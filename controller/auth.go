@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/question"
+	"github.com/RSOI/answer/ui"
+)
+
+// mayDelete checks that caller is author of answer or moderator.
+// nil caller means authentication is disabled and everything is allowed.
+func mayDelete(ctx context.Context, who *auth.Identity, aID int) error {
+	if who == nil || who.HasRole(auth.RoleModerator, auth.RoleService) {
+		return nil
+	}
+
+	a, err := storage(ctx).GetAnswerByID(aID, false)
+	if err == ui.ErrNoResult {
+		return ui.ErrNoDataToDelete
+	}
+	if err != nil {
+		return err
+	}
+	if who.UserID == 0 || a.AuthorID != who.UserID {
+		return ui.ErrForbidden
+	}
+	return nil
+}
+
+// mayEdit checks that caller is author of answer, moderator or service
+func mayEdit(ctx context.Context, who *auth.Identity, aID int) error {
+	if who == nil || who.HasRole(auth.RoleModerator, auth.RoleService) {
+		return nil
+	}
+
+	a, err := storage(ctx).GetAnswerByID(aID, false)
+	if err == ui.ErrNoResult {
+		return ui.ErrNoDataToUpdate
+	}
+	if err != nil {
+		return err
+	}
+	if who.UserID == 0 || a.AuthorID != who.UserID {
+		return ui.ErrForbidden
+	}
+	return nil
+}
+
+// mayDeleteMany checks that caller is a service, only services remove answers in bulk
+func mayDeleteMany(who *auth.Identity) error {
+	if who == nil || who.HasRole(auth.RoleService) {
+		return nil
+	}
+	return ui.ErrForbidden
+}
+
+// mayMarkBest checks that caller owns question of answer, is moderator or service.
+// Question owner is known from question service only, without it users can't mark answers.
+func mayMarkBest(ctx context.Context, who *auth.Identity, aID int) error {
+	if who == nil || who.HasRole(auth.RoleModerator, auth.RoleService) {
+		return nil
+	}
+	if who.UserID == 0 || Questions == nil {
+		return ui.ErrForbidden
+	}
+
+	a, err := storage(ctx).GetAnswerByID(aID, false)
+	if err == ui.ErrNoResult {
+		return ui.ErrNoDataToUpdate
+	}
+	if err != nil {
+		return err
+	}
+	q, err := Questions.GetQuestion(ctx, a.QuestionID)
+	if err == question.ErrNotFound {
+		return ui.ErrQuestionNotFound
+	}
+	if err != nil {
+		return ui.ErrQuestionUnavailable
+	}
+	if q.AuthorID != who.UserID {
+		return ui.ErrForbidden
+	}
+	return nil
+}
+
+// actAs returns user caller acts as: services act on behalf of user from body,
+// everyone else acts as themselves.
+func actAs(who *auth.Identity, bodyUserID int) (int, error) {
+	if who == nil || who.HasRole(auth.RoleService) {
+		return bodyUserID, nil
+	}
+	if who.UserID == 0 {
+		return 0, ui.ErrForbidden
+	}
+	return who.UserID, nil
+}
+
+// maySeeDeleted reports whether caller may read answers in trash: moderators and admin API keys
+func maySeeDeleted(who *auth.Identity) bool {
+	return who == nil || who.HasRole(auth.RoleModerator) || who.HasScope(auth.ScopeAnswersAdmin)
+}
+
+// setAuthor replaces author of new answer with caller. Services post on behalf of users
+// and keep author from body.
+func setAuthor(who *auth.Identity, a *model.Answer) error {
+	if who == nil || who.HasRole(auth.RoleService) {
+		return nil
+	}
+	if who.UserID == 0 {
+		return ui.ErrForbidden
+	}
+
+	a.AuthorID = who.UserID
+	if who.Nickname != "" {
+		a.AuthorNickname = who.Nickname
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package controller
 
 import (
+	"context"
+
+	"github.com/RSOI/answer/metrics"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/tracing"
+	"github.com/RSOI/answer/ui"
 	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/webhook"
 	"github.com/jackc/pgx"
 )
 
@@ -13,8 +19,33 @@ var (
 
 // Init Init model with pgx connection
 func Init(db *pgx.ConnPool) {
-	utils.LOG("Setup model...")
-	AnswerModel = &model.AService{
+	utils.Debug("Setup model...")
+	AnswerModel = metrics.Instrument(&model.AService{
 		Conn: db,
+	})
+	Webhooks = &webhook.PostgresStore{Conn: db}
+	if err := metrics.RegisterPool(db); err != nil {
+		utils.Warn("Pool metrics are not registered", utils.Fields{"err": err})
+	}
+}
+
+// storage returns model recording spans of storage calls within request traced in ctx
+func storage(ctx context.Context) model.AServiceInterface {
+	return tracing.Instrument(ctx, AnswerModel)
+}
+
+// logDataError logs storage failures at error level, expected errors like missing answers only in debug
+func logDataError(err error) {
+	if status, _ := ui.ErrToResponse(err); status >= 500 {
+		utils.Error("Data error", utils.Fields{"err": err})
+		return
 	}
+	utils.Debug("Data error", utils.Fields{"err": err})
+}
+
+// InitMemory Init model with in-memory storage
+func InitMemory() {
+	utils.Debug("Setup in-memory model...")
+	AnswerModel = metrics.Instrument(&model.AMemoryService{})
+	Webhooks = &webhook.MemoryStore{}
 }
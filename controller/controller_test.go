@@ -1,12 +1,20 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/config"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/question"
+	"github.com/RSOI/answer/ratelimit"
 	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/view"
+	"github.com/RSOI/answer/webhook"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -28,36 +36,117 @@ func (s *MockedAService) DeleteAnswerByID(a model.Answer) error {
 	args := s.Mock.Called(a)
 	return args.Error(0)
 }
-func (s *MockedAService) DeleteAnswerByAuthorID(a model.Answer) error {
+func (s *MockedAService) DeleteAnswerByAuthorID(a model.Answer) (int, error) {
 	args := s.Mock.Called(a)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
-func (s *MockedAService) DeleteAnswerByQuestionID(a model.Answer) error {
+func (s *MockedAService) DeleteAnswerByQuestionID(a model.Answer) (int, error) {
 	args := s.Mock.Called(a)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
-func (s *MockedAService) GetAnswerByID(qID int) (model.Answer, error) {
-	args := s.Mock.Called(qID)
+func (s *MockedAService) RestoreAnswer(a model.Answer) (model.Answer, error) {
+	args := s.Mock.Called(a)
 	return args.Get(0).(model.Answer), args.Error(1)
 }
-func (s *MockedAService) GetAnswersByAuthorID(aAuthorID int, limit int, offset int) ([]model.Answer, error) {
-	args := s.Mock.Called(aAuthorID, limit, offset)
-	return args.Get(0).([]model.Answer), args.Error(1)
+func (s *MockedAService) PurgeDeleted(before time.Time) (int, error) {
+	args := s.Mock.Called(before)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) GetAnswerByID(qID int, includeDeleted bool) (model.Answer, error) {
+	args := s.Mock.Called(qID, includeDeleted)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) GetAnswersByAuthorID(aAuthorID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	args := s.Mock.Called(aAuthorID, q)
+	return args.Get(0).(model.AnswersPage), args.Error(1)
+}
+func (s *MockedAService) GetAnswersByQuestionID(aQuestionID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	args := s.Mock.Called(aQuestionID, q)
+	return args.Get(0).(model.AnswersPage), args.Error(1)
 }
-func (s *MockedAService) GetAnswersByQuestionID(aQuestionID int, limit int, offset int) ([]model.Answer, error) {
-	args := s.Mock.Called(aQuestionID, limit, offset)
-	return args.Get(0).([]model.Answer), args.Error(1)
+func (s *MockedAService) SearchAnswers(q model.SearchQuery) ([]model.SearchResult, error) {
+	args := s.Mock.Called(q)
+	return args.Get(0).([]model.SearchResult), args.Error(1)
 }
-func (s *MockedAService) UpdateAnswer(a model.Answer) (model.Answer, error) {
+func (s *MockedAService) UpdateAnswer(a model.Answer) (model.BestAnswer, error) {
 	args := s.Mock.Called(a)
+	return args.Get(0).(model.BestAnswer), args.Error(1)
+}
+func (s *MockedAService) UnmarkBestAnswer(a model.Answer) (model.Answer, error) {
+	args := s.Mock.Called(a)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) EditAnswer(r model.Revision) (model.Answer, error) {
+	args := s.Mock.Called(r)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) RollbackAnswer(r model.Revision) (model.Answer, error) {
+	args := s.Mock.Called(r)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) GetRevisions(aID int) ([]model.Revision, error) {
+	args := s.Mock.Called(aID)
+	return args.Get(0).([]model.Revision), args.Error(1)
+}
+func (s *MockedAService) GetRevision(aID int, revision int) (model.Revision, error) {
+	args := s.Mock.Called(aID, revision)
+	return args.Get(0).(model.Revision), args.Error(1)
+}
+func (s *MockedAService) VoteAnswer(v model.Vote) (model.Answer, error) {
+	args := s.Mock.Called(v)
 	return args.Get(0).(model.Answer), args.Error(1)
 }
 func (s *MockedAService) GetUsageStatistic(host string) (model.ServiceStatus, error) {
 	args := s.Mock.Called(host)
 	return args.Get(0).(model.ServiceStatus), args.Error(1)
 }
-func (s *MockedAService) LogStat(request []byte, responseStatus int, responseError string) {
+func (s *MockedAService) GetRouteStats(since time.Time) ([]model.RouteStat, error) {
+	args := s.Mock.Called(since)
+	return args.Get(0).([]model.RouteStat), args.Error(1)
+}
+func (s *MockedAService) RollupStats(until time.Time) (int, error) {
+	args := s.Mock.Called(until)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) PurgeStats(before time.Time) (int, error) {
+	args := s.Mock.Called(before)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) ClaimEvents(limit int, lease time.Duration) ([]model.Event, error) {
+	args := s.Mock.Called(limit, lease)
+	return args.Get(0).([]model.Event), args.Error(1)
+}
+func (s *MockedAService) MarkEventsPublished(ids []string) error {
+	args := s.Mock.Called(ids)
+	return args.Error(0)
+}
+func (s *MockedAService) RetryEvent(id string, at time.Time, reason string) error {
+	args := s.Mock.Called(id, at, reason)
+	return args.Error(0)
+}
+func (s *MockedAService) PurgeEvents(before time.Time) (int, error) {
+	args := s.Mock.Called(before)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) AddAPIKey(k model.APIKey) (model.APIKey, error) {
+	args := s.Mock.Called(k)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+func (s *MockedAService) GetAPIKeys() ([]model.APIKey, error) {
+	args := s.Mock.Called()
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+func (s *MockedAService) GetAPIKeyByHash(hash string) (model.APIKey, error) {
+	args := s.Mock.Called(hash)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+func (s *MockedAService) RevokeAPIKey(id int) error {
+	args := s.Mock.Called(id)
+	return args.Error(0)
+}
+func (s *MockedAService) LogStats(stats []model.RequestInfo) error {
 	// nothing interesting here, just store data without affecting main thread
+	return nil
 }
 
 var (
@@ -112,7 +201,7 @@ func TestAskAddCorrectData(t *testing.T) {
 	cMock := getMock()
 	cMock.On("AddAnswer", defaultAnswer).Return(createdAnswer, nil)
 
-	data, err := AnswerPUT(body)
+	data, err := AnswerPUT(context.Background(), body, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 
@@ -128,7 +217,7 @@ func TestAskAddCorrectData(t *testing.T) {
 func TestAnswerMissedField(t *testing.T) {
 	body := []byte("{\"author_id\": 1}")
 
-	data, err := AnswerPUT(body)
+	data, err := AnswerPUT(context.Background(), body, nil)
 	assert.Equal(t, ui.ErrFieldsRequired, err)
 	assert.Nil(t, data)
 }
@@ -136,7 +225,7 @@ func TestAnswerMissedField(t *testing.T) {
 func TestAnswerBrokenBody(t *testing.T) {
 	body := []byte("{author_id: 1}")
 
-	data, err := AnswerPUT(body)
+	data, err := AnswerPUT(context.Background(), body, nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, data)
 }
@@ -149,9 +238,9 @@ TESTS FOR QUESTION ID **********************************************
 
 func TestAnswerGetByIDCorrectData(t *testing.T) {
 	cMock := getMock()
-	cMock.On("GetAnswerByID", 1).Return(createdAnswer, nil)
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
 
-	data, err := AnswerGET("1")
+	data, err := AnswerGET(context.Background(), "1", false, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 
@@ -166,9 +255,9 @@ func TestAnswerGetByIDCorrectData(t *testing.T) {
 
 func TestAnswerGetByIDNotFound(t *testing.T) {
 	cMock := getMock()
-	cMock.On("GetAnswerByID", 0).Return(model.Answer{}, ui.ErrNoResult)
+	cMock.On("GetAnswerByID", 0, false).Return(model.Answer{}, ui.ErrNoResult)
 
-	data, err := AnswerGET("0")
+	data, err := AnswerGET(context.Background(), "0", false, nil)
 	if assert.NotNil(t, err) {
 		cMock.AssertExpectations(t)
 
@@ -183,13 +272,13 @@ func TestAnswerGetByAuthorIDCorrectData(t *testing.T) {
 	createdAnswers := make([]model.Answer, 0)
 	createdAnswers = append(createdAnswers, createdAnswer)
 	createdAnswers = append(createdAnswers, createdAnswer)
-	cMock.On("GetAnswersByAuthorID", 1, -1, -1).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByAuthorID", 1, model.AnswersQuery{Order: model.OrderByID, Page: model.Page{Limit: -1}}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
-	data, err := AnswersGET("1", "author", -1, -1)
+	data, err := AnswersGET(context.Background(), "1", "author", view.AnswersArgs{Limit: "-1"}, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
-		assert.Equal(t, 2, len(data))
-		for _, d := range data {
+		assert.Equal(t, 2, len(data.Answers))
+		for _, d := range data.Answers {
 			assert.Equal(t, createdAnswer.ID, d.ID)
 			assert.Equal(t, createdAnswer.QuestionID, d.QuestionID)
 			assert.Equal(t, *createdAnswer.Content, *d.Content)
@@ -204,14 +293,14 @@ func TestAnswerGetByAuthorIDNotFound(t *testing.T) {
 	cMock := getMock()
 
 	createdAnswers := make([]model.Answer, 0)
-	cMock.On("GetAnswersByAuthorID", 1, -1, -1).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByAuthorID", 1, model.AnswersQuery{Order: model.OrderByID, Page: model.Page{Limit: -1}}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
-	data, err := AnswersGET("1", "author", -1, -1)
+	data, err := AnswersGET(context.Background(), "1", "author", view.AnswersArgs{Limit: "-1"}, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 
-		assert.Equal(t, 0, len(data))
-		assert.Equal(t, make([]model.Answer, 0), data)
+		assert.Equal(t, 0, len(data.Answers))
+		assert.Equal(t, make([]model.Answer, 0), data.Answers)
 	}
 }
 
@@ -221,13 +310,13 @@ func TestAnswerGetByQuestionIDCorrectData(t *testing.T) {
 	createdAnswers := make([]model.Answer, 0)
 	createdAnswers = append(createdAnswers, createdAnswer)
 	createdAnswers = append(createdAnswers, createdAnswer)
-	cMock.On("GetAnswersByQuestionID", 1, -1, -1).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByID, Page: model.Page{Limit: -1}}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
-	data, err := AnswersGET("1", "question", -1, -1)
+	data, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "-1"}, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
-		assert.Equal(t, 2, len(data))
-		for _, d := range data {
+		assert.Equal(t, 2, len(data.Answers))
+		for _, d := range data.Answers {
 			assert.Equal(t, createdAnswer.ID, d.ID)
 			assert.Equal(t, createdAnswer.QuestionID, d.QuestionID)
 			assert.Equal(t, *createdAnswer.Content, *d.Content)
@@ -242,14 +331,14 @@ func TestAnswerGetByQuestionIDNotFound(t *testing.T) {
 	cMock := getMock()
 
 	createdAnswers := make([]model.Answer, 0)
-	cMock.On("GetAnswersByQuestionID", 1, -1, -1).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByID, Page: model.Page{Limit: -1}}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
-	data, err := AnswersGET("1", "question", -1, -1)
+	data, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "-1"}, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 
-		assert.Equal(t, 0, len(data))
-		assert.Equal(t, make([]model.Answer, 0), data)
+		assert.Equal(t, 0, len(data.Answers))
+		assert.Equal(t, make([]model.Answer, 0), data.Answers)
 	}
 }
 
@@ -261,10 +350,10 @@ TESTS FOR UPDATE QUESTION ******************************************
 
 func TestUpdateCorrectData(t *testing.T) {
 	cMock := getMock()
-	cMock.On("UpdateAnswer", updatedAnswer).Return(updatedAnswer, nil)
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{Answer: updatedAnswer}, nil)
 
 	body, _ := json.Marshal(updatedAnswer)
-	response, err := MakeBestPATCH(body)
+	response, err := MakeBestPATCH(context.Background(), body, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 
@@ -275,10 +364,10 @@ func TestUpdateCorrectData(t *testing.T) {
 
 func TestUpdateNotFound(t *testing.T) {
 	cMock := getMock()
-	cMock.On("UpdateAnswer", updatedAnswer).Return(model.Answer{}, ui.ErrNoDataToUpdate)
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{}, ui.ErrNoDataToUpdate)
 
 	body, _ := json.Marshal(updatedAnswer)
-	data, err := MakeBestPATCH(body)
+	data, err := MakeBestPATCH(context.Background(), body, nil)
 	if assert.NotNil(t, err) {
 		cMock.AssertExpectations(t)
 
@@ -290,19 +379,264 @@ func TestUpdateNotFound(t *testing.T) {
 func TestUpdateMissedID(t *testing.T) {
 	body := []byte("{\"has_best\": true, \"content\": \"My New Content\"}")
 
-	response, err := MakeBestPATCH(body)
+	response, err := MakeBestPATCH(context.Background(), body, nil)
 	assert.Equal(t, ui.ErrFieldsRequired, err)
-	assert.Equal(t, (*model.Answer)(nil), response)
+	assert.Equal(t, (*model.BestAnswer)(nil), response)
+}
+
+func TestUpdateReportsPreviousBest(t *testing.T) {
+	cMock := getMock()
+	previousID := 2
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{Answer: updatedAnswer, PreviousBestID: &previousID}, nil)
+
+	body, _ := json.Marshal(updatedAnswer)
+	response, err := MakeBestPATCH(context.Background(), body, nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, previousID, *response.PreviousBestID)
+	}
+}
+
+func TestUnmarkBestCorrectData(t *testing.T) {
+	cMock := getMock()
+	cMock.On("UnmarkBestAnswer", model.Answer{ID: 1}).Return(createdAnswer, nil)
+
+	response, err := UnmarkBestDELETE(context.Background(), []byte("{\"id\": 1}"), nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.False(t, *response.IsBest)
+	}
+}
+
+func TestUnmarkBestMissedID(t *testing.T) {
+	response, err := UnmarkBestDELETE(context.Background(), []byte("{}"), nil)
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	assert.Nil(t, response)
 }
 
 func TestUpdateBrokenBody(t *testing.T) {
-	data, err := MakeBestPATCH([]byte("{id: 1}"))
+	data, err := MakeBestPATCH(context.Background(), []byte("{id: 1}"), nil)
 
 	if assert.NotNil(t, err) {
 		assert.Nil(t, data)
 	}
 }
 
+/*
+********************************************************************
+TESTS FOR REVISIONS ************************************************
+********************************************************************
+*/
+
+func TestEditCorrectData(t *testing.T) {
+	cMock := getMock()
+	content := "My Edited Content"
+	edit := model.Revision{AnswerID: 1, Content: &content, EditorID: 2, Summary: "typo"}
+	edited := createdAnswer
+	edited.Content = &content
+	edited.Revision = 2
+	cMock.On("EditAnswer", edit).Return(edited, nil)
+
+	body, _ := json.Marshal(edit)
+	data, err := EditPATCH(context.Background(), body, nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+
+		assert.Equal(t, content, *data.Content)
+		assert.Equal(t, 2, data.Revision)
+	}
+}
+
+func TestEditMissedContent(t *testing.T) {
+	data, err := EditPATCH(context.Background(), []byte("{\"answer_id\": 1, \"editor_id\": 1}"), nil)
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	assert.Nil(t, data)
+}
+
+func TestEditNotFound(t *testing.T) {
+	cMock := getMock()
+	content := "My Edited Content"
+	edit := model.Revision{AnswerID: 1, Content: &content, EditorID: 2}
+	cMock.On("EditAnswer", edit).Return(model.Answer{}, ui.ErrNoDataToUpdate)
+
+	body, _ := json.Marshal(edit)
+	data, err := EditPATCH(context.Background(), body, nil)
+	if assert.Equal(t, ui.ErrNoDataToUpdate, err) {
+		cMock.AssertExpectations(t)
+		assert.Nil(t, data)
+	}
+}
+
+func TestEditorFromIdentity(t *testing.T) {
+	cMock := getMock()
+	content := "My Edited Content"
+	cMock.On("EditAnswer", model.Revision{AnswerID: 1, Content: &content, EditorID: 5}).Return(createdAnswer, nil)
+	cMock.On("RollbackAnswer", model.Revision{AnswerID: 1, Revision: 1, EditorID: 5}).Return(createdAnswer, nil)
+	cMock.On("EditAnswer", model.Revision{AnswerID: 1, Content: &content, EditorID: 2}).Return(createdAnswer, nil)
+	authored := createdAnswer
+	authored.AuthorID = 5
+	cMock.On("GetAnswerByID", 1, false).Return(authored, nil)
+	user := &auth.Identity{Subject: "5", UserID: 5}
+
+	body, _ := json.Marshal(model.Revision{AnswerID: 1, Content: &content, EditorID: 2})
+	_, err := EditPATCH(context.Background(), body, user)
+	assert.Nil(t, err)
+	_, err = RollbackPATCH(context.Background(), []byte("{\"answer_id\": 1, \"revision\": 1, \"editor_id\": 2}"), user)
+	assert.Nil(t, err)
+	_, err = EditPATCH(context.Background(), body, &auth.Identity{Subject: "question", Roles: []string{auth.RoleService}})
+	assert.Nil(t, err, "service keeps editor of body")
+	cMock.AssertExpectations(t)
+
+	_, err = EditPATCH(context.Background(), body, auth.Anonymous())
+	assert.Equal(t, ui.ErrForbidden, err)
+}
+
+func TestEditByAuthorOnly(t *testing.T) {
+	cMock := getMock()
+	content := "My Edited Content"
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	cMock.On("EditAnswer", model.Revision{AnswerID: 1, Content: &content, EditorID: createdAnswer.AuthorID}).Return(createdAnswer, nil)
+	cMock.On("EditAnswer", model.Revision{AnswerID: 1, Content: &content, EditorID: 7}).Return(createdAnswer, nil)
+	body, _ := json.Marshal(model.Revision{AnswerID: 1, Content: &content})
+	rollback := []byte("{\"answer_id\": 1, \"revision\": 1}")
+
+	_, err := EditPATCH(context.Background(), body, &auth.Identity{Subject: "1", UserID: createdAnswer.AuthorID})
+	assert.Nil(t, err, "author edits answer")
+	_, err = EditPATCH(context.Background(), body, &auth.Identity{Subject: "7", UserID: 7, Roles: []string{auth.RoleModerator}})
+	assert.Nil(t, err, "moderator edits answer")
+
+	stranger := &auth.Identity{Subject: "5", UserID: 5}
+	_, err = EditPATCH(context.Background(), body, stranger)
+	assert.Equal(t, ui.ErrForbidden, err)
+	_, err = RollbackPATCH(context.Background(), rollback, stranger)
+	assert.Equal(t, ui.ErrForbidden, err)
+	cMock.AssertNotCalled(t, "EditAnswer", model.Revision{AnswerID: 1, Content: &content, EditorID: 5})
+	cMock.AssertNotCalled(t, "RollbackAnswer", mock.Anything)
+	cMock.AssertExpectations(t)
+}
+
+func TestEditNotFoundAnswer(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAnswerByID", 1, false).Return(model.Answer{}, ui.ErrNoResult)
+
+	_, err := RollbackPATCH(context.Background(), []byte("{\"answer_id\": 1, \"revision\": 1}"), &auth.Identity{Subject: "5", UserID: 5})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func TestRollbackCorrectData(t *testing.T) {
+	cMock := getMock()
+	rollback := model.Revision{AnswerID: 1, Revision: 1, EditorID: 2}
+	restored := createdAnswer
+	restored.Revision = 3
+	cMock.On("RollbackAnswer", rollback).Return(restored, nil)
+
+	data, err := RollbackPATCH(context.Background(), []byte("{\"answer_id\": 1, \"revision\": 1, \"editor_id\": 2}"), nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 3, data.Revision)
+	}
+}
+
+func TestRollbackMissedRevision(t *testing.T) {
+	data, err := RollbackPATCH(context.Background(), []byte("{\"answer_id\": 1, \"editor_id\": 2}"), nil)
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	assert.Nil(t, data)
+}
+
+func TestRevisionsGetCorrectData(t *testing.T) {
+	cMock := getMock()
+	revisions := []model.Revision{
+		{AnswerID: 1, Revision: 1, Content: &defaultAnswerContent, EditorID: 1},
+	}
+	cMock.On("GetRevisions", 1).Return(revisions, nil)
+
+	data, err := RevisionsGET(context.Background(), "1")
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, revisions, data)
+	}
+}
+
+func TestRevisionGetNotFound(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetRevision", 1, 5).Return(model.Revision{}, ui.ErrNoResult)
+
+	data, err := RevisionGET(context.Background(), "1", "5")
+	if assert.Equal(t, ui.ErrNoResult, err) {
+		cMock.AssertExpectations(t)
+		assert.Nil(t, data)
+	}
+}
+
+func TestAnswerGetByQuestionIDSortByScore(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByScore, Page: model.Page{Limit: 10}}).Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	_, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "10", Sort: "score"}, nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestAnswerGetByQuestionIDUnknownSort(t *testing.T) {
+	data, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "10", Sort: "random"}, nil)
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	assert.Nil(t, data)
+}
+
+/*
+********************************************************************
+TESTS FOR VOTES ****************************************************
+********************************************************************
+*/
+
+func TestVoteCorrectData(t *testing.T) {
+	cMock := getMock()
+	voted := createdAnswer
+	voted.Score = 1
+	cMock.On("VoteAnswer", model.Vote{AnswerID: 1, VoterID: 2, Value: 1}).Return(voted, nil)
+
+	data, err := VotePATCH(context.Background(), []byte("{\"answer_id\": 1, \"voter_id\": 2, \"value\": 1}"), nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 1, data.Score)
+	}
+}
+
+func TestVoteRetract(t *testing.T) {
+	cMock := getMock()
+	cMock.On("VoteAnswer", model.Vote{AnswerID: 1, VoterID: 2, Value: 0}).Return(createdAnswer, nil)
+
+	_, err := VotePATCH(context.Background(), []byte("{\"answer_id\": 1, \"voter_id\": 2}"), nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestVoterFromIdentity(t *testing.T) {
+	cMock := getMock()
+	cMock.On("VoteAnswer", model.Vote{AnswerID: 1, VoterID: 5, Value: 1}).Return(createdAnswer, nil)
+
+	_, err := VotePATCH(context.Background(), []byte("{\"answer_id\": 1, \"voter_id\": 2, \"value\": 1}"), &auth.Identity{Subject: "5", UserID: 5})
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+	_, err = VotePATCH(context.Background(), []byte("{\"answer_id\": 1, \"voter_id\": 2, \"value\": 1}"), auth.Anonymous())
+	assert.Equal(t, ui.ErrForbidden, err)
+}
+
+func TestVoteInvalidValue(t *testing.T) {
+	data, err := VotePATCH(context.Background(), []byte("{\"answer_id\": 1, \"voter_id\": 2, \"value\": 5}"), nil)
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	assert.Nil(t, data)
+}
+
+func TestVoteMissedVoter(t *testing.T) {
+	data, err := VotePATCH(context.Background(), []byte("{\"answer_id\": 1, \"value\": 1}"), nil)
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	assert.Nil(t, data)
+}
+
 /*
 ********************************************************************
 TESTS FOR REMOVE QUESTION ******************************************
@@ -314,16 +648,16 @@ func TestRemoveByIDCorrectData(t *testing.T) {
 	cMock.On("DeleteAnswerByID", answerToRemoveID).Return(nil)
 
 	body := []byte("{\"id\": 1}")
-	err := RemoveDELETE(body)
+	err := RemoveDELETE(context.Background(), body, nil)
 	assert.Nil(t, err)
 }
 
 func TestRemoveByAuthorIDCorrectData(t *testing.T) {
 	cMock := getMock()
-	cMock.On("DeleteAnswerByAuthorID", answerToRemoveAuthorID).Return(nil)
+	cMock.On("DeleteAnswerByAuthorID", answerToRemoveAuthorID).Return(1, nil)
 
 	body := []byte("{\"author_id\": 1}")
-	err := RemoveDELETE(body)
+	err := RemoveDELETE(context.Background(), body, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 	}
@@ -331,10 +665,10 @@ func TestRemoveByAuthorIDCorrectData(t *testing.T) {
 
 func TestRemoveByQuestionIDCorrectData(t *testing.T) {
 	cMock := getMock()
-	cMock.On("DeleteAnswerByQuestionID", answerToRemoveQuestionID).Return(nil)
+	cMock.On("DeleteAnswerByQuestionID", answerToRemoveQuestionID).Return(1, nil)
 
 	body := []byte("{\"question_id\": 1}")
-	err := RemoveDELETE(body)
+	err := RemoveDELETE(context.Background(), body, nil)
 	if assert.Nil(t, err) {
 		cMock.AssertExpectations(t)
 	}
@@ -345,7 +679,7 @@ func TestRemoveByIDNotFound(t *testing.T) {
 	cMock.On("DeleteAnswerByID", answerToRemoveID).Return(ui.ErrNoDataToDelete)
 
 	body := []byte("{\"id\": 1}")
-	err := RemoveDELETE(body)
+	err := RemoveDELETE(context.Background(), body, nil)
 	if assert.Equal(t, ui.ErrNoDataToDelete, err) {
 		cMock.AssertExpectations(t)
 	}
@@ -353,6 +687,640 @@ func TestRemoveByIDNotFound(t *testing.T) {
 
 func TestRemoveMissedIDs(t *testing.T) {
 	body := []byte("{\"has_best\": true}")
-	err := RemoveDELETE(body)
+	err := RemoveDELETE(context.Background(), body, nil)
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+}
+
+/*
+********************************************************************
+TESTS FOR TRASH ****************************************************
+********************************************************************
+*/
+
+func TestAnswerGetByQuestionIDIncludeDeleted(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByID, Page: model.Page{Limit: 10}, IncludeDeleted: true}).Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	_, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "10", IncludeDeleted: true}, nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestAnswersIncludeDeletedForbidden(t *testing.T) {
+	cMock := getMock()
+	user := &auth.Identity{Subject: "5", UserID: 5, Scopes: []string{auth.ScopeAnswersRead, auth.ScopeAnswersWrite}}
+
+	_, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "10", IncludeDeleted: true}, user)
+	assert.Equal(t, ui.ErrForbidden, err)
+	_, err = AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "10", IncludeDeleted: true}, auth.Anonymous())
+	assert.Equal(t, ui.ErrForbidden, err)
+	_, err = AnswerGET(context.Background(), "1", true, user)
+	assert.Equal(t, ui.ErrForbidden, err)
+	cMock.AssertNotCalled(t, "GetAnswersByQuestionID", mock.Anything, mock.Anything)
+	cMock.AssertNotCalled(t, "GetAnswerByID", mock.Anything, mock.Anything)
+
+	moderator := &auth.Identity{Subject: "7", UserID: 7, Roles: []string{auth.RoleModerator}}
+	cMock.On("GetAnswerByID", 1, true).Return(createdAnswer, nil)
+	_, err = AnswerGET(context.Background(), "1", true, moderator)
+	assert.Nil(t, err)
+	cMock.AssertExpectations(t)
+}
+
+func TestRemoveKeepsDeletedBy(t *testing.T) {
+	cMock := getMock()
+	admin := 7
+	cMock.On("DeleteAnswerByID", model.Answer{ID: 1, DeletedBy: &admin}).Return(nil)
+
+	err := RemoveDELETE(context.Background(), []byte("{\"id\": 1, \"deleted_by\": 7}"), nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestRestoreCorrectData(t *testing.T) {
+	cMock := getMock()
+	cMock.On("RestoreAnswer", model.Answer{ID: 1}).Return(createdAnswer, nil)
+
+	data, err := RestorePATCH(context.Background(), []byte("{\"id\": 1}"))
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, createdAnswer.ID, data.ID)
+	}
+}
+
+func TestRestoreMissedID(t *testing.T) {
+	data, err := RestorePATCH(context.Background(), []byte("{}"))
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	assert.Nil(t, data)
+}
+
+func TestPurgeUsesRetention(t *testing.T) {
+	cMock := getMock()
+	before := time.Now().Add(-time.Hour)
+	cMock.On("PurgeDeleted", mock.MatchedBy(func(t time.Time) bool {
+		return !t.Before(before) && t.Before(time.Now().Add(-time.Hour+time.Minute))
+	})).Return(2, nil)
+
+	Purge(time.Hour, 0)
+	cMock.AssertExpectations(t)
+	cMock.AssertNotCalled(t, "PurgeEvents", mock.Anything)
+}
+
+func TestPurgeEventsUsesRetention(t *testing.T) {
+	cMock := getMock()
+	before := time.Now().Add(-24 * time.Hour)
+	cMock.On("PurgeEvents", mock.MatchedBy(func(t time.Time) bool {
+		return !t.Before(before) && t.Before(time.Now().Add(-24*time.Hour+time.Minute))
+	})).Return(3, nil)
+
+	Purge(0, 24*time.Hour)
+	cMock.AssertExpectations(t)
+	cMock.AssertNotCalled(t, "PurgeDeleted", mock.Anything)
+}
+
+func TestPurgeEventsAfterTrashFailed(t *testing.T) {
+	cMock := getMock()
+	cMock.On("PurgeDeleted", mock.Anything).Return(0, ui.ErrUnavailable)
+	cMock.On("PurgeEvents", mock.Anything).Return(0, nil)
+
+	Purge(time.Hour, time.Hour)
+	cMock.AssertExpectations(t)
+}
+
+func TestPurgePrunesRateLimits(t *testing.T) {
+	getMock()
+	store := &ratelimit.MemoryStore{}
+	Limiter = ratelimit.New(store, map[string]ratelimit.Limit{ratelimit.GroupRead: {Burst: 1, Per: time.Millisecond}})
+	defer func() { Limiter = nil }()
+
+	Limiter.Allow(ratelimit.GroupRead, "ip:127.0.0.1")
+	time.Sleep(2 * time.Millisecond)
+	Purge(0, 0)
+
+	n, err := Limiter.Prune()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, n, "full bucket is already pruned")
+	}
+}
+
+func TestStartPurge(t *testing.T) {
+	cMock := getMock()
+	cMock.On("PurgeDeleted", mock.Anything).Return(0, nil)
+
+	stop := StartPurge(time.Hour, 0, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	calls := len(cMock.Calls)
+	assert.True(t, calls > 1)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, calls, len(cMock.Calls), "no purge after stop")
+}
+
+func TestStartStats(t *testing.T) {
+	AnswerModel = &model.AMemoryService{}
+	w := StartStats(config.StatsConfig{QueueSize: 10, BatchSize: 5, FlushInterval: time.Hour, Overflow: "drop"})
+	defer func() { statsWriter = nil }()
+
+	LogStat(context.Background(), model.RequestInfo{Request: "/answer", ResponseStatus: 201, RequestID: "req-1"})
+	LogStat(context.Background(), model.RequestInfo{Request: "/best", ResponseStatus: 404,
+		ResponseErrorText: ui.ErrNoDataToUpdate.Error(), APIKeyID: 3, RequestID: "req-2"})
+	stat, _ := AnswerModel.GetUsageStatistic("localhost")
+	assert.Equal(t, 0, stat.RequestsCount, "stats are queued")
+
+	assert.Nil(t, w.Close(context.Background()))
+	stat, _ = AnswerModel.GetUsageStatistic("localhost")
+	assert.Equal(t, 2, stat.RequestsCount)
+	assert.Equal(t, "/best", stat.LastUsage.Request)
+	assert.Equal(t, 3, stat.LastUsage.APIKeyID)
+	assert.Equal(t, "req-2", stat.LastUsage.RequestID)
+}
+
+func TestRollupStats(t *testing.T) {
+	cMock := getMock()
+	cMock.On("RollupStats", mock.MatchedBy(func(until time.Time) bool {
+		return until.Equal(until.Truncate(time.Hour)) && time.Since(until) >= RollupDelay
+	})).Return(10, nil)
+	cMock.On("PurgeStats", mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) >= 24*time.Hour && time.Since(before) < 24*time.Hour+time.Minute
+	})).Return(7, nil)
+
+	RollupStats(24 * time.Hour)
+	cMock.AssertExpectations(t)
+}
+
+func TestRollupStatsKeepsForever(t *testing.T) {
+	cMock := getMock()
+	cMock.On("RollupStats", mock.Anything).Return(0, nil)
+
+	RollupStats(0)
+	cMock.AssertNotCalled(t, "PurgeStats", mock.Anything)
+}
+
+func TestRollupStatsFailed(t *testing.T) {
+	cMock := getMock()
+	cMock.On("RollupStats", mock.Anything).Return(0, ui.ErrUnavailable)
+
+	RollupStats(time.Hour)
+	cMock.AssertNotCalled(t, "PurgeStats", mock.Anything)
+}
+
+func TestStatsGETDefaultWindow(t *testing.T) {
+	cMock := getMock()
+	routes := []model.RouteStat{{Method: "GET", Route: "/answer/id:id", Requests: 3}}
+	cMock.On("GetRouteStats", mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= time.Hour && time.Since(since) < time.Hour+time.Minute
+	})).Return(routes, nil)
+
+	data, err := StatsGET(context.Background(), "")
+	if assert.Nil(t, err) {
+		assert.Equal(t, DefaultStatsWindow, data.Window)
+		assert.Equal(t, routes, data.Routes)
+	}
+	cMock.AssertExpectations(t)
+}
+
+func TestStatsGETUnknownWindow(t *testing.T) {
+	getMock()
+	data, err := StatsGET(context.Background(), "1y")
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	assert.Nil(t, data)
+}
+
+/*
+********************************************************************
+TESTS FOR ACCESS ***************************************************
+********************************************************************
+*/
+
+var (
+	author    = &auth.Identity{Subject: "1", UserID: 1, Nickname: "Author"}
+	stranger  = &auth.Identity{Subject: "2", UserID: 2, Nickname: "Stranger"}
+	moderator = &auth.Identity{Subject: "3", UserID: 3, Roles: []string{auth.RoleModerator}}
+	service   = &auth.Identity{Subject: "question", Roles: []string{auth.RoleService}}
+)
+
+func TestAnswerAuthorFromToken(t *testing.T) {
+	cMock := getMock()
+	fromToken := defaultAnswer
+	fromToken.AuthorID = stranger.UserID
+	fromToken.AuthorNickname = stranger.Nickname
+	cMock.On("AddAnswer", fromToken).Return(createdAnswer, nil)
+	body, _ := json.Marshal(&defaultAnswer)
+	_, err := AnswerPUT(context.Background(), body, stranger)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestAnswerServiceKeepsAuthor(t *testing.T) {
+	cMock := getMock()
+	cMock.On("AddAnswer", defaultAnswer).Return(createdAnswer, nil)
+	body, _ := json.Marshal(&defaultAnswer)
+	_, err := AnswerPUT(context.Background(), body, service)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestAnswerSubjectNotUser(t *testing.T) {
+	body, _ := json.Marshal(&defaultAnswer)
+	data, err := AnswerPUT(context.Background(), body, &auth.Identity{Subject: "somebody"})
+	assert.Equal(t, ui.ErrForbidden, err)
+	assert.Nil(t, data)
+}
+
+func TestRemoveByAuthor(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	cMock.On("DeleteAnswerByID", model.Answer{ID: 1, DeletedBy: &author.UserID}).Return(nil)
+	err := RemoveDELETE(context.Background(), []byte("{\"id\": 1, \"deleted_by\": 7}"), author)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestRemoveByStranger(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	err := RemoveDELETE(context.Background(), []byte("{\"id\": 1}"), stranger)
+	if assert.Equal(t, ui.ErrForbidden, err) {
+		cMock.AssertExpectations(t)
+		cMock.AssertNotCalled(t, "DeleteAnswerByID", mock.Anything)
+	}
+}
+
+func TestRemoveByStrangerNotFound(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAnswerByID", 1, false).Return(model.Answer{}, ui.ErrNoResult)
+	err := RemoveDELETE(context.Background(), []byte("{\"id\": 1}"), stranger)
+	assert.Equal(t, ui.ErrNoDataToDelete, err)
+}
+
+func TestRemoveByModerator(t *testing.T) {
+	cMock := getMock()
+	cMock.On("DeleteAnswerByID", model.Answer{ID: 1, DeletedBy: &moderator.UserID}).Return(nil)
+	err := RemoveDELETE(context.Background(), []byte("{\"id\": 1}"), moderator)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		cMock.AssertNotCalled(t, "GetAnswerByID", mock.Anything, mock.Anything)
+	}
+}
+
+func TestRemoveManyNeedsService(t *testing.T) {
+	getMock()
+	assert.Equal(t, ui.ErrForbidden, RemoveDELETE(context.Background(), []byte("{\"question_id\": 1}"), moderator))
+	assert.Equal(t, ui.ErrForbidden, RemoveDELETE(context.Background(), []byte("{\"author_id\": 1}"), author))
+
+	cMock := getMock()
+	cMock.On("DeleteAnswerByQuestionID", answerToRemoveQuestionID).Return(1, nil)
+	err := RemoveDELETE(context.Background(), []byte("{\"question_id\": 1}"), service)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAPIKeyByHash", auth.HashAPIKey("ak_key")).
+		Return(model.APIKey{ID: 3, Name: "gateway", Scopes: []string{auth.ScopeAnswersRead}}, nil)
+	who, err := CheckAPIKey(context.Background(), "ak_key")
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 3, who.KeyID)
+		assert.True(t, who.HasScope(auth.ScopeAnswersRead))
+	}
+}
+
+func TestCheckAPIKeyUnknown(t *testing.T) {
+	cMock := getMock()
+	cMock.On("GetAPIKeyByHash", auth.HashAPIKey("ak_key")).Return(model.APIKey{}, ui.ErrNoResult)
+	who, err := CheckAPIKey(context.Background(), "ak_key")
+	assert.Equal(t, ui.ErrUnauthorized, err)
+	assert.Nil(t, who)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	cMock := getMock()
+	var stored model.APIKey
+	cMock.On("AddAPIKey", mock.Anything).Return(model.APIKey{ID: 1}, nil).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(model.APIKey)
+	})
+	_, key, err := CreateAPIKey(context.Background(), "gateway", []string{auth.ScopeAnswersRead})
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, auth.HashAPIKey(key), stored.Hash)
+		assert.Equal(t, auth.KeyPrefix(key), stored.Prefix)
+		assert.Equal(t, "gateway", stored.Name)
+	}
+}
+
+func TestCreateAPIKeyUnknownScope(t *testing.T) {
+	_, _, err := CreateAPIKey(context.Background(), "gateway", []string{"answers:everything"})
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	_, _, err = CreateAPIKey(context.Background(), "", []string{auth.ScopeAnswersRead})
 	assert.Equal(t, ui.ErrFieldsRequired, err)
 }
+
+func TestRevokeAPIKeyBrokenID(t *testing.T) {
+	assert.Equal(t, ui.ErrInvalidParameter, RevokeAPIKey(context.Background(), "gateway"))
+}
+
+/*
+********************************************************************
+TESTS FOR PAGINATION ***********************************************
+********************************************************************
+*/
+
+func TestAnswerGetByQuestionIDCursor(t *testing.T) {
+	cMock := getMock()
+	cursor := model.Cursor{Order: model.OrderByScore, ID: 3, Score: 2}
+	next := model.Cursor{Order: model.OrderByScore, ID: 5, Score: 1}
+	total := 7
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByScore, Page: model.Page{Limit: 2, Cursor: &cursor, WithTotal: true}}).
+		Return(model.AnswersPage{Answers: make([]model.Answer, 0), Next: &next, Total: &total}, nil)
+
+	data, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{Limit: "2", Cursor: cursor.String(), Total: true, Sort: "score"}, nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, next, *data.Next)
+		assert.Equal(t, 7, *data.Total)
+	}
+}
+
+func TestAnswerGetBrokenCursor(t *testing.T) {
+	data, err := AnswersGET(context.Background(), "1", "author", view.AnswersArgs{Limit: "2", Cursor: "not a cursor"}, nil)
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	assert.Nil(t, data)
+}
+
+func TestAnswerGetByQuestionIDFilters(t *testing.T) {
+	cMock := getMock()
+	isBest, author := false, 3
+	from := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{
+		Order:       model.OrderByNewest,
+		IsBest:      &isBest,
+		CreatedFrom: &from,
+		CreatedTo:   &to,
+		AuthorID:    &author,
+	}).Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	_, err := AnswersGET(context.Background(), "1", "question", view.AnswersArgs{
+		Sort:        "newest",
+		IsBest:      "false",
+		CreatedFrom: "2018-10-01T00:00:00Z",
+		CreatedTo:   "2018-11-01T00:00:00Z",
+		Author:      "3",
+	}, nil)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+	}
+}
+
+func TestAnswerGetInvalidFilters(t *testing.T) {
+	invalid := []view.AnswersArgs{
+		{Limit: "ten"},
+		{Limit: "100000000"},
+		{Limit: "101", Total: true},
+		{IsBest: "maybe"},
+		{CreatedFrom: "yesterday"},
+		{CreatedTo: "2018-11-01"},
+		{Author: "-1"},
+	}
+	for _, args := range invalid {
+		data, err := AnswersGET(context.Background(), "1", "question", args, nil)
+		assert.Equal(t, ui.ErrInvalidParameter, err, args)
+		assert.Nil(t, data)
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR SEARCH ***************************************************
+********************************************************************
+*/
+
+func TestSearchCorrectData(t *testing.T) {
+	cMock := getMock()
+	question := 2
+	cMock.On("SearchAnswers", model.SearchQuery{Text: "my answer", QuestionID: &question, Limit: 5}).
+		Return([]model.SearchResult{{Answer: createdAnswer, Rank: 0.5, Snippet: "<b>My</b> <b>Answer</b> Content"}}, nil)
+
+	data, err := SearchGET(context.Background(), " my answer ", "2", "", "5")
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(data)) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, createdAnswer.ID, data[0].ID)
+	}
+}
+
+func TestSearchMissedText(t *testing.T) {
+	data, err := SearchGET(context.Background(), "  ", "", "", "")
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	assert.Nil(t, data)
+}
+
+func TestSearchLimitTooLarge(t *testing.T) {
+	cMock := getMock()
+
+	data, err := SearchGET(context.Background(), "answer", "", "", strconv.Itoa(model.MaxPageSize+1))
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	assert.Nil(t, data)
+	cMock.AssertNotCalled(t, "SearchAnswers", mock.Anything)
+}
+
+func TestSearchInvalidFilter(t *testing.T) {
+	data, err := SearchGET(context.Background(), "answer", "", "someone", "")
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	assert.Nil(t, data)
+}
+
+/*
+********************************************************************
+TESTS FOR WEBHOOKS *************************************************
+********************************************************************
+*/
+
+func TestWebhookPutCorrectData(t *testing.T) {
+	Webhooks = &webhook.MemoryStore{}
+
+	data, err := WebhookPUT([]byte(`{"url":"https://questions.local/hooks","events":["answer.best_marked"],"question_id":3}`))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, data.ID)
+		assert.Equal(t, 64, len(data.Secret))
+		assert.Equal(t, []string{model.EventAnswerBestMarked}, data.EventTypes)
+		assert.Equal(t, 3, *data.QuestionID)
+	}
+
+	list, err := WebhooksGET()
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(list)) {
+		assert.Equal(t, "", list[0].Secret)
+	}
+}
+
+func TestWebhookPutInvalidData(t *testing.T) {
+	Webhooks = &webhook.MemoryStore{}
+
+	_, err := WebhookPUT([]byte(`{"events":["answer.created"]}`))
+	assert.Equal(t, ui.ErrFieldsRequired, err)
+	_, err = WebhookPUT([]byte(`{"url":"ftp://questions.local"}`))
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	_, err = WebhookPUT([]byte(`{"url":"http://questions.local","events":["question.created"]}`))
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+	_, err = WebhookPUT([]byte(`{"url":"http://questions.local","question_id":0}`))
+	assert.Equal(t, ui.ErrInvalidParameter, err)
+}
+
+func TestWebhookDelete(t *testing.T) {
+	Webhooks = &webhook.MemoryStore{}
+	WebhookPUT([]byte(`{"url":"http://questions.local"}`))
+
+	assert.Equal(t, ui.ErrFieldsRequired, WebhookDELETE([]byte(`{}`)))
+	assert.Nil(t, WebhookDELETE([]byte(`{"id":1}`)))
+	assert.Equal(t, ui.ErrNoDataToDelete, WebhookDELETE([]byte(`{"id":1}`)))
+}
+
+func TestRedeliver(t *testing.T) {
+	Webhooks = &webhook.MemoryStore{}
+	WebhookPUT([]byte(`{"url":"http://questions.local"}`))
+	Webhooks.Enqueue(model.Event{ID: "event-1", Type: model.EventAnswerCreated})
+	claimed, _ := Webhooks.ClaimDeliveries(1, time.Minute)
+	Webhooks.KillDelivery(claimed[0].ID, "webhook answered 500")
+
+	dead, err := DeadLettersGET()
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(dead)) {
+		assert.Equal(t, "event-1", dead[0].Event.ID)
+	}
+	assert.Nil(t, RedeliverPATCH([]byte(`{"id":1}`)))
+	assert.Equal(t, ui.ErrNoDataToUpdate, RedeliverPATCH([]byte(`{"id":1}`)))
+	dead, _ = DeadLettersGET()
+	assert.Equal(t, 0, len(dead))
+}
+
+/*
+********************************************************************
+TESTS FOR QUESTION CHECK *******************************************
+********************************************************************
+*/
+
+// questions answers question checks, failing with err for every question when it is set
+type questions struct {
+	found map[int]question.Question
+	err   error
+}
+
+func (q questions) GetQuestion(ctx context.Context, id int) (question.Question, error) {
+	if q.err != nil {
+		return question.Question{}, q.err
+	}
+	found, ok := q.found[id]
+	if !ok {
+		return question.Question{}, question.ErrNotFound
+	}
+	return found, nil
+}
+
+func withQuestions(q QuestionSource, failOpen bool) func() {
+	prevQuestions, prevFailOpen := Questions, QuestionFailOpen
+	Questions, QuestionFailOpen = q, failOpen
+	return func() { Questions, QuestionFailOpen = prevQuestions, prevFailOpen }
+}
+
+func TestAnswerToExistingQuestion(t *testing.T) {
+	defer withQuestions(questions{found: map[int]question.Question{defaultAnswer.QuestionID: {ID: defaultAnswer.QuestionID}}}, false)()
+	body, _ := json.Marshal(&defaultAnswer)
+	cMock := getMock()
+	cMock.On("AddAnswer", defaultAnswer).Return(createdAnswer, nil)
+
+	_, err := AnswerPUT(context.Background(), body, nil)
+	assert.Nil(t, err)
+	cMock.AssertExpectations(t)
+}
+
+func TestAnswerToRejectedQuestion(t *testing.T) {
+	deleted := time.Now()
+	cases := map[int]error{
+		defaultAnswer.QuestionID: ui.ErrQuestionNotFound,
+		100:                      ui.ErrQuestionDeleted,
+		101:                      ui.ErrQuestionLocked,
+	}
+	defer withQuestions(questions{found: map[int]question.Question{
+		100: {ID: 100, DeletedAt: &deleted},
+		101: {ID: 101, Locked: true},
+	}}, false)()
+
+	for questionID, expected := range cases {
+		a := defaultAnswer
+		a.QuestionID = questionID
+		body, _ := json.Marshal(&a)
+		cMock := getMock()
+
+		data, err := AnswerPUT(context.Background(), body, nil)
+		assert.Equal(t, expected, err)
+		assert.Nil(t, data)
+		cMock.AssertNotCalled(t, "AddAnswer", mock.Anything)
+	}
+}
+
+func TestAnswerWhileQuestionsUnavailable(t *testing.T) {
+	body, _ := json.Marshal(&defaultAnswer)
+	unavailable := questions{err: question.ErrUnavailable}
+
+	restore := withQuestions(unavailable, false)
+	cMock := getMock()
+	_, err := AnswerPUT(context.Background(), body, nil)
+	assert.Equal(t, ui.ErrQuestionUnavailable, err, "fail closed rejects answers")
+	cMock.AssertNotCalled(t, "AddAnswer", mock.Anything)
+	restore()
+
+	defer withQuestions(unavailable, true)()
+	cMock = getMock()
+	cMock.On("AddAnswer", defaultAnswer).Return(createdAnswer, nil)
+	_, err = AnswerPUT(context.Background(), body, nil)
+	assert.Nil(t, err, "fail open accepts answers unchecked")
+	cMock.AssertExpectations(t)
+}
+
+func TestMarkBestByOtherUser(t *testing.T) {
+	defer withQuestions(questions{found: map[int]question.Question{createdAnswer.QuestionID: {ID: createdAnswer.QuestionID, AuthorID: 3}}}, false)()
+	cMock := getMock()
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	body := []byte("{\"id\": 1}")
+
+	data, err := MakeBestPATCH(context.Background(), body, &auth.Identity{Subject: "5", UserID: 5})
+	assert.Equal(t, ui.ErrForbidden, err)
+	assert.Nil(t, data)
+	_, err = UnmarkBestDELETE(context.Background(), body, &auth.Identity{Subject: "5", UserID: 5})
+	assert.Equal(t, ui.ErrForbidden, err)
+	cMock.AssertNotCalled(t, "UpdateAnswer", mock.Anything)
+	cMock.AssertNotCalled(t, "UnmarkBestAnswer", mock.Anything)
+
+	cMock.On("UnmarkBestAnswer", model.Answer{ID: 1}).Return(createdAnswer, nil)
+	_, err = UnmarkBestDELETE(context.Background(), body, &auth.Identity{Subject: "3", UserID: 3})
+	assert.Nil(t, err, "question owner unmarks best answer")
+	_, err = UnmarkBestDELETE(context.Background(), body, &auth.Identity{Subject: "7", UserID: 7, Roles: []string{auth.RoleModerator}})
+	assert.Nil(t, err, "moderator unmarks best answer")
+}
+
+func TestMarkBestWithoutQuestions(t *testing.T) {
+	defer withQuestions(nil, false)()
+
+	_, err := MakeBestPATCH(context.Background(), []byte("{\"id\": 1}"), &auth.Identity{Subject: "3", UserID: 3})
+	assert.Equal(t, ui.ErrForbidden, err, "question owner is unknown")
+}
+
+func TestInitQuestions(t *testing.T) {
+	defer withQuestions(nil, false)()
+
+	InitQuestions(config.QuestionConfig{Policy: "fail-open"})
+	assert.Nil(t, Questions, "check is disabled without URL")
+	assert.True(t, QuestionFailOpen)
+
+	cfg := config.Default().Question
+	cfg.URL = "http://localhost:8082"
+	InitQuestions(cfg)
+	assert.IsType(t, &question.Client{}, Questions)
+	assert.False(t, QuestionFailOpen)
+}
@@ -1,47 +1,65 @@
 package controller
 
 import (
-	"fmt"
+	"context"
 	"strconv"
 
+	"github.com/RSOI/answer/auth"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
 	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
 )
 
-// AnswerGET get answer by id
-func AnswerGET(id string) (*model.Answer, error) {
+// AnswerGET get answer by id, answers in trash are found only with includeDeleted by moderators
+func AnswerGET(ctx context.Context, id string, includeDeleted bool, who *auth.Identity) (*model.Answer, error) {
+	if includeDeleted && !maySeeDeleted(who) {
+		utils.Debug("Access error", utils.Fields{"err": ui.ErrForbidden})
+		return nil, ui.ErrForbidden
+	}
 	aID, _ := strconv.Atoi(id)
 
-	data, err := AnswerModel.GetAnswerByID(aID)
+	data, err := storage(ctx).GetAnswerByID(aID, includeDeleted)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Data error: %s", err.Error()))
+		logDataError(err)
 		return nil, err
 	}
 
-	utils.LOG("Answer was found successfully")
+	utils.Debug("Answer was found successfully")
 	return &data, nil
 }
 
-// AnswersGET get answers by author or question
-func AnswersGET(aid string, searchby string, limit int, offset int) ([]model.Answer, error) {
+// AnswersGET get page of answers by author or question, answers in trash are listed only for moderators
+func AnswersGET(ctx context.Context, aid string, searchby string, args view.AnswersArgs, who *auth.Identity) (*model.AnswersPage, error) {
 	var err error
-	var data []model.Answer
+	var data model.AnswersPage
+
+	if args.IncludeDeleted && !maySeeDeleted(who) {
+		utils.Debug("Access error", utils.Fields{"err": ui.ErrForbidden})
+		return nil, ui.ErrForbidden
+	}
+
+	q, err := view.ValidateAnswersQuery(args)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
 
 	aidi, _ := strconv.Atoi(aid)
 	switch searchby {
 	case "author":
-		data, err = AnswerModel.GetAnswersByAuthorID(aidi, limit, offset)
+		data, err = storage(ctx).GetAnswersByAuthorID(aidi, q)
 		break
 	case "question":
-		data, err = AnswerModel.GetAnswersByQuestionID(aidi, limit, offset)
+		data, err = storage(ctx).GetAnswersByQuestionID(aidi, q)
 		break
 	}
 
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Data error: %s", err.Error()))
+		logDataError(err)
 		return nil, err
 	}
 
-	utils.LOG("Answers were found successfully")
-	return data, nil
+	utils.Debug("Answers were found successfully")
+	return &data, nil
 }
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+)
+
+// LogLevel minimal level of logged messages
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// LogLevelGET returns current log level
+func LogLevelGET() *LogLevel {
+	return &LogLevel{Level: utils.GetLevel().String()}
+}
+
+// LogLevelPUT changes log level of running service
+func LogLevelPUT(body []byte) (*LogLevel, error) {
+	var l LogLevel
+	err := json.Unmarshal(body, &l)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+	if l.Level == "" {
+		return nil, ui.ErrFieldsRequired
+	}
+
+	level, err := utils.ParseLevel(l.Level)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, ui.ErrInvalidParameter
+	}
+
+	old := utils.GetLevel()
+	utils.SetLevel(level)
+	utils.Info("Log level changed", utils.Fields{"from": old, "to": level})
+	return LogLevelGET(), nil
+}
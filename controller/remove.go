@@ -1,46 +1,62 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
+	"github.com/RSOI/answer/auth"
 	"github.com/RSOI/answer/model"
 	"github.com/RSOI/answer/utils"
 	"github.com/RSOI/answer/view"
 )
 
-// RemoveDELETE remove answer
-func RemoveDELETE(body []byte) error {
+// RemoveDELETE remove answer. Only author or moderator may remove answer by id,
+// removing by question or author is left to services.
+func RemoveDELETE(ctx context.Context, body []byte, who *auth.Identity) error {
 	var err error
 
 	var AnswerToRemove model.Answer
 	err = json.Unmarshal(body, &AnswerToRemove)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Broken body. Error: %s", err.Error()))
+		utils.Debug("Broken body", utils.Fields{"err": err})
 		return err
 	}
 
 	f, err := view.ValidateDeleteAnswer(AnswerToRemove)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Validation error: %s", err.Error()))
+		utils.Debug("Validation error", utils.Fields{"err": err})
 		return err
 	}
 
-	utils.LOG(fmt.Sprintf("Removing answer by: %s...", f))
+	if f == "id" {
+		err = mayDelete(ctx, who, AnswerToRemove.ID)
+	} else {
+		err = mayDeleteMany(who)
+	}
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return err
+	}
+	if who != nil && !who.HasRole(auth.RoleService) {
+		AnswerToRemove.DeletedBy = &who.UserID
+	}
+
+	utils.Debug("Removing answers", utils.Fields{"by": f})
 
+	removed := 1
 	switch f {
 	case "id":
-		err = AnswerModel.DeleteAnswerByID(AnswerToRemove)
+		err = storage(ctx).DeleteAnswerByID(AnswerToRemove)
 	case "question_id":
-		err = AnswerModel.DeleteAnswerByQuestionID(AnswerToRemove)
+		removed, err = storage(ctx).DeleteAnswerByQuestionID(AnswerToRemove)
 	case "author_id":
-		err = AnswerModel.DeleteAnswerByAuthorID(AnswerToRemove)
+		removed, err = storage(ctx).DeleteAnswerByAuthorID(AnswerToRemove)
 	}
 
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Data error: %s", err.Error()))
+		logDataError(err)
 	} else {
-		utils.LOG("Answer removed successfully")
+		utils.Debug("Answers removed successfully", utils.Fields{"count": removed})
 	}
 
 	return err
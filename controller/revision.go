@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
+)
+
+// EditPATCH change answer content, allowed to author, moderators and services. Editor is taken from caller.
+func EditPATCH(ctx context.Context, body []byte, who *auth.Identity) (*model.Answer, error) {
+	var err error
+
+	var Edit model.Revision
+	err = json.Unmarshal(body, &Edit)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	Edit.EditorID, err = actAs(who, Edit.EditorID)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = view.ValidateEditAnswer(Edit)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = mayEdit(ctx, who, Edit.AnswerID)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	EditedAnswer, err := storage(ctx).EditAnswer(Edit)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Answer edited successfully")
+	return &EditedAnswer, nil
+}
+
+// RollbackPATCH restore previous answer content, allowed to author, moderators and services.
+// Editor is taken from caller.
+func RollbackPATCH(ctx context.Context, body []byte, who *auth.Identity) (*model.Answer, error) {
+	var err error
+
+	var Rollback model.Revision
+	err = json.Unmarshal(body, &Rollback)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	Rollback.EditorID, err = actAs(who, Rollback.EditorID)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = view.ValidateRollbackAnswer(Rollback)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = mayEdit(ctx, who, Rollback.AnswerID)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	RestoredAnswer, err := storage(ctx).RollbackAnswer(Rollback)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Answer rolled back successfully")
+	return &RestoredAnswer, nil
+}
+
+// RevisionsGET get all the versions of answer content
+func RevisionsGET(ctx context.Context, id string) ([]model.Revision, error) {
+	aID, _ := strconv.Atoi(id)
+
+	data, err := storage(ctx).GetRevisions(aID)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Revisions were found successfully")
+	return data, nil
+}
+
+// RevisionGET get one version of answer content
+func RevisionGET(ctx context.Context, id string, revision string) (*model.Revision, error) {
+	aID, _ := strconv.Atoi(id)
+	rev, _ := strconv.Atoi(revision)
+
+	data, err := storage(ctx).GetRevision(aID, rev)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Revision was found successfully")
+	return &data, nil
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
+)
+
+// SearchGET full text search of answers, optionally within question or author answers
+func SearchGET(ctx context.Context, text string, question string, author string, limit string) ([]model.SearchResult, error) {
+	q, err := view.ValidateSearch(text, question, author, limit)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	data, err := storage(ctx).SearchAnswers(q)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Answers were found", utils.Fields{"count": len(data)})
+	return data, nil
+}
@@ -1,26 +1,129 @@
 package controller
 
 import (
-	"fmt"
+	"context"
+	"time"
 
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/metrics"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/stats"
+	"github.com/RSOI/answer/ui"
 	"github.com/RSOI/answer/utils"
 )
 
 // IndexGET returns usage statistic
-func IndexGET(host []byte) (*model.ServiceStatus, error) {
-	data, err := AnswerModel.GetUsageStatistic(string(host))
+func IndexGET(ctx context.Context, host []byte) (*model.ServiceStatus, error) {
+	data, err := storage(ctx).GetUsageStatistic(string(host))
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Data error: %s", err.Error()))
+		logDataError(err)
 		return nil, err
 	}
 
-	utils.LOG("Successfull accessing usage statistic")
+	utils.Debug("Successfull accessing usage statistic")
 	return &data, nil
 }
 
-// LogStat stores service usage
-func LogStat(path []byte, status int, err string) {
-	utils.LOG("Storing usage stat...")
-	AnswerModel.LogStat(path, status, err)
+// statsWriter queues stats of LogStat, they are written synchronously while it is nil
+var statsWriter *stats.Writer
+
+// StartStats makes LogStat queue stats which are written to AnswerModel in batches by background worker.
+// Returned writer is closed on shutdown to write stats left in queue.
+func StartStats(cfg config.StatsConfig) *stats.Writer {
+	statsWriter = stats.New(func(batch []model.RequestInfo) error {
+		return storage(context.Background()).LogStats(batch)
+	}, cfg)
+	if err := metrics.RegisterStats(statsWriter); err != nil {
+		utils.Warn("Stats metrics are not registered", utils.Fields{"err": err})
+	}
+	return statsWriter
+}
+
+// DefaultStatsWindow reported when window is not given
+const DefaultStatsWindow = "1h"
+
+// StatsGET returns per-route counts, error rates and latency percentiles of window
+func StatsGET(ctx context.Context, window string) (*model.WindowStats, error) {
+	if window == "" {
+		window = DefaultStatsWindow
+	}
+	period, ok := model.StatsWindows[window]
+	if !ok {
+		utils.Debug("Unknown stats window", utils.Fields{"window": window})
+		return nil, ui.ErrInvalidParameter
+	}
+
+	since := time.Now().Add(-period)
+	routes, err := storage(ctx).GetRouteStats(since)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+	return &model.WindowStats{Window: window, Since: since, Routes: routes}, nil
+}
+
+// LogStat stores service usage of one request
+func LogStat(ctx context.Context, info model.RequestInfo) {
+	if info.RequestTime.IsZero() {
+		info.RequestTime = time.Now()
+	}
+	if statsWriter != nil {
+		statsWriter.Add(info)
+		return
+	}
+
+	utils.Debug("Storing usage stat...")
+	if err := storage(ctx).LogStats([]model.RequestInfo{info}); err != nil {
+		utils.Error("Error while storing statistic", utils.Fields{"err": err})
+	}
+}
+
+// RollupDelay hour is rolled up a few minutes after it ends, so that stats queued meanwhile are rolled up with it.
+// Stats written later still are added to their hour by the next rollup.
+var RollupDelay = 5 * time.Minute
+
+// RollupStats rolls up usage log of complete hours into hourly aggregates
+// and removes rolled up stats older than retention, zero retention keeps them
+func RollupStats(retention time.Duration) {
+	until := time.Now().Add(-RollupDelay).Truncate(time.Hour)
+	n, err := AnswerModel.RollupStats(until)
+	if err != nil {
+		utils.Error("Stats rollup error", utils.Fields{"err": err})
+		return
+	}
+	utils.Debug("Stats rolled up", utils.Fields{"count": n, "until": until})
+
+	if retention <= 0 {
+		return
+	}
+	n, err = AnswerModel.PurgeStats(time.Now().Add(-retention))
+	if err != nil {
+		utils.Error("Stats purge error", utils.Fields{"err": err})
+		return
+	}
+	utils.Debug("Purged rolled up stats", utils.Fields{"count": n})
+}
+
+// StartRollup runs RollupStats every interval until returned stop function is called
+func StartRollup(retention time.Duration, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			RollupStats(retention)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
 }
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ratelimit"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
+)
+
+// RestorePATCH take answer back from trash
+func RestorePATCH(ctx context.Context, body []byte) (*model.Answer, error) {
+	var err error
+
+	var AnswerToRestore model.Answer
+	err = json.Unmarshal(body, &AnswerToRestore)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = view.ValidateRestore(AnswerToRestore)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	RestoredAnswer, err := storage(ctx).RestoreAnswer(AnswerToRestore)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Answer restored successfully")
+	return &RestoredAnswer, nil
+}
+
+// Limiter rate limit buckets pruned by Purge once they are full again, nil skips pruning
+var Limiter *ratelimit.Limiter
+
+// Purge permanently remove answers which are in trash longer than retention
+// and events published longer than eventRetention ago, zero retention keeps them.
+// Idle rate limit buckets of Limiter are dropped too.
+func Purge(retention, eventRetention time.Duration) {
+	if retention > 0 {
+		n, err := AnswerModel.PurgeDeleted(time.Now().Add(-retention))
+		if err != nil {
+			utils.Error("Purge error", utils.Fields{"err": err})
+		} else {
+			utils.Info("Purged answers from trash", utils.Fields{"count": n})
+		}
+	}
+
+	if eventRetention > 0 {
+		n, err := AnswerModel.PurgeEvents(time.Now().Add(-eventRetention))
+		if err != nil {
+			utils.Error("Purge events error", utils.Fields{"err": err})
+		} else {
+			utils.Info("Purged published events", utils.Fields{"count": n})
+		}
+	}
+
+	if Limiter != nil {
+		n, err := Limiter.Prune()
+		if err != nil {
+			utils.Error("Prune rate limits error", utils.Fields{"err": err})
+		} else {
+			utils.Debug("Pruned idle rate limit buckets", utils.Fields{"count": n})
+		}
+	}
+}
+
+// StartPurge runs Purge every interval until returned stop function is called
+func StartPurge(retention, eventRetention, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			Purge(retention, eventRetention)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
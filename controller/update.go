@@ -1,40 +1,80 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
+	"github.com/RSOI/answer/auth"
 	"github.com/RSOI/answer/model"
 	"github.com/RSOI/answer/utils"
 	"github.com/RSOI/answer/view"
 )
 
-// MakeBestPATCH remove answer
-func MakeBestPATCH(body []byte) (*model.Answer, error) {
+// MakeBestPATCH mark answer as best, allowed to question owner, moderators and services
+func MakeBestPATCH(ctx context.Context, body []byte, who *auth.Identity) (*model.BestAnswer, error) {
 	var err error
 
 	var AnswerToUpdate model.Answer
-	var UpdatedAnswer model.Answer
+	var UpdatedAnswer model.BestAnswer
 	isBest := true
 	err = json.Unmarshal(body, &AnswerToUpdate)
 	AnswerToUpdate.IsBest = &isBest
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Broken body. Error: %s", err.Error()))
+		utils.Debug("Broken body", utils.Fields{"err": err})
 		return nil, err
 	}
 
 	err = view.ValidateMakeBestAnswer(AnswerToUpdate)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Validation error: %s", err.Error()))
+		utils.Debug("Validation error", utils.Fields{"err": err})
 		return nil, err
 	}
 
-	UpdatedAnswer, err = AnswerModel.UpdateAnswer(AnswerToUpdate)
+	err = mayMarkBest(ctx, who, AnswerToUpdate.ID)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Data error: %s", err.Error()))
+		utils.Debug("Access error", utils.Fields{"err": err})
 		return nil, err
 	}
 
-	utils.LOG("Answer marked as best successfully")
+	UpdatedAnswer, err = storage(ctx).UpdateAnswer(AnswerToUpdate)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Answer marked as best successfully")
+	return &UpdatedAnswer, nil
+}
+
+// UnmarkBestDELETE remove best answer flag, allowed to question owner, moderators and services
+func UnmarkBestDELETE(ctx context.Context, body []byte, who *auth.Identity) (*model.Answer, error) {
+	var err error
+
+	var AnswerToUpdate model.Answer
+	err = json.Unmarshal(body, &AnswerToUpdate)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = view.ValidateMakeBestAnswer(AnswerToUpdate)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = mayMarkBest(ctx, who, AnswerToUpdate.ID)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	UpdatedAnswer, err := storage(ctx).UnmarkBestAnswer(AnswerToUpdate)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Answer unmarked as best successfully")
 	return &UpdatedAnswer, nil
 }
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
+)
+
+// VotePATCH set, change or retract vote of caller
+func VotePATCH(ctx context.Context, body []byte, who *auth.Identity) (*model.Answer, error) {
+	var err error
+
+	var NewVote model.Vote
+	err = json.Unmarshal(body, &NewVote)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	NewVote.VoterID, err = actAs(who, NewVote.VoterID)
+	if err != nil {
+		utils.Debug("Access error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = view.ValidateVote(NewVote)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	VotedAnswer, err := storage(ctx).VoteAnswer(NewVote)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+
+	utils.Debug("Vote stored successfully")
+	return &VotedAnswer, nil
+}
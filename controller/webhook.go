@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
+	"github.com/RSOI/answer/webhook"
+)
+
+// Webhooks storage of webhooks and their deliveries
+var Webhooks webhook.Store
+
+// WebhookPUT registers webhook and returns it with secret, it can't be shown later
+func WebhookPUT(body []byte) (*webhook.Webhook, error) {
+	var err error
+
+	var NewWebhook webhook.Webhook
+	err = json.Unmarshal(body, &NewWebhook)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	err = view.ValidateWebhook(NewWebhook)
+	if err != nil {
+		utils.Debug("Validation error", utils.Fields{"err": err})
+		return nil, err
+	}
+
+	NewWebhook.Secret, err = webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	NewWebhook, err = Webhooks.AddWebhook(NewWebhook)
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+	utils.Info("Webhook registered", utils.Fields{"webhook_id": NewWebhook.ID, "url": NewWebhook.URL})
+	return &NewWebhook, nil
+}
+
+// WebhooksGET lists webhooks without their secrets
+func WebhooksGET() ([]webhook.Webhook, error) {
+	data, err := Webhooks.GetWebhooks()
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// WebhookDELETE removes webhook with its pending deliveries and dead letters
+func WebhookDELETE(body []byte) error {
+	var WebhookToRemove webhook.Webhook
+	err := json.Unmarshal(body, &WebhookToRemove)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return err
+	}
+	if WebhookToRemove.ID <= 0 {
+		return ui.ErrFieldsRequired
+	}
+
+	err = Webhooks.RemoveWebhook(WebhookToRemove.ID)
+	if err != nil {
+		logDataError(err)
+		return err
+	}
+	utils.Info("Webhook removed", utils.Fields{"webhook_id": WebhookToRemove.ID})
+	return nil
+}
+
+// DeadLettersGET lists deliveries which failed every attempt
+func DeadLettersGET() ([]webhook.DeadLetter, error) {
+	data, err := Webhooks.GetDeadLetters()
+	if err != nil {
+		logDataError(err)
+		return nil, err
+	}
+	return data, nil
+}
+
+// RedeliverPATCH posts dead letter again
+func RedeliverPATCH(body []byte) error {
+	var DeadLetter webhook.DeadLetter
+	err := json.Unmarshal(body, &DeadLetter)
+	if err != nil {
+		utils.Debug("Broken body", utils.Fields{"err": err})
+		return err
+	}
+	if DeadLetter.ID <= 0 {
+		return ui.ErrFieldsRequired
+	}
+
+	err = Webhooks.Redeliver(DeadLetter.ID)
+	if err != nil {
+		logDataError(err)
+		return err
+	}
+	utils.Info("Dead letter is redelivered", utils.Fields{"delivery_id": DeadLetter.ID})
+	return nil
+}
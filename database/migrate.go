@@ -0,0 +1,320 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/RSOI/answer/utils"
+	"github.com/jackc/pgx"
+)
+
+// migrationLockKey advisory lock id shared by every replica running migrations
+const migrationLockKey = 460918
+
+var (
+	// ErrUnknownMigration - requested migration version is not found
+	ErrUnknownMigration = errors.New("unknown migration version")
+
+	migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+// Migration one numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus migration state in the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to the database
+type Migrator struct {
+	Conn       *pgx.ConnPool
+	Migrations []Migration
+}
+
+// LoadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir
+func LoadMigrations(dir string) ([]Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		m := migrationFile.FindStringSubmatch(f.Name())
+		if f.IsDir() || m == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		sql, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Version == 0 {
+			return nil, fmt.Errorf("migration %s: version must be greater than 0", migration.Name)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// NewMigrator loads migrations from dir
+func NewMigrator(db *pgx.ConnPool, dir string) (*Migrator, error) {
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{Conn: db, Migrations: migrations}, nil
+}
+
+// Latest returns version of the newest known migration
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Status returns every known migration with its state
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.locked(func(conn *pgx.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			at, ok := applied[migration.Version]
+			status = append(status, MigrationStatus{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: at,
+			})
+		}
+		return nil
+	})
+	return status, err
+}
+
+// Pending returns versions of known migrations which are not applied.
+// Unlike Status it does not wait for migrations running in other replicas.
+func (m *Migrator) Pending(ctx context.Context) ([]int, error) {
+	conn, err := m.Conn.AcquireEx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Conn.Release(conn)
+
+	applied, err := appliedMigrations(conn)
+	if err != nil {
+		return nil, err
+	}
+	var pending []int
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration.Version)
+		}
+	}
+	return pending, nil
+}
+
+// Check fails while some of known migrations are not applied
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations are not applied, first is %d", len(pending), pending[0])
+	}
+	return nil
+}
+
+// Up applies all the pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the newest applied migration
+func (m *Migrator) Down() error {
+	return m.locked(func(conn *pgx.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.Migrations[i].Version]; ok {
+				return revert(conn, m.Migrations[i])
+			}
+		}
+		utils.Debug("No migrations to revert")
+		return nil
+	})
+}
+
+// To migrates schema up or down to the passed version. Version 0 reverts everything.
+func (m *Migrator) To(version int) error {
+	if version != 0 && !m.known(version) {
+		return ErrUnknownMigration
+	}
+
+	return m.locked(func(conn *pgx.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			migration := m.Migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				if err = revert(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err = apply(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// locked runs f on a single connection holding the migration advisory lock,
+// so concurrently starting replicas apply migrations one after another
+func (m *Migrator) locked(f func(conn *pgx.Conn) error) error {
+	conn, err := m.Conn.Acquire()
+	if err != nil {
+		return err
+	}
+	defer m.Conn.Release(conn)
+
+	utils.Debug("Waiting for migration lock...")
+	if _, err = conn.Exec(`SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(`SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	_, err = conn.Exec(`
+		CREATE SCHEMA IF NOT EXISTS answer;
+		CREATE TABLE IF NOT EXISTS answer.schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+func appliedMigrations(conn *pgx.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(`SELECT version, applied_at FROM answer.schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func apply(conn *pgx.Conn, migration Migration) error {
+	utils.Info("Applying migration", utils.Fields{"version": migration.Version, "name": migration.Name})
+	return inTx(conn, func(tx *pgx.Tx) error {
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return fmt.Errorf("migration %d_%s up: %s", migration.Version, migration.Name, err.Error())
+		}
+		_, err := tx.Exec(`INSERT INTO answer.schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+		return err
+	})
+}
+
+func revert(conn *pgx.Conn, migration Migration) error {
+	utils.Info("Reverting migration", utils.Fields{"version": migration.Version, "name": migration.Name})
+	return inTx(conn, func(tx *pgx.Tx) error {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down: %s", migration.Version, migration.Name, err.Error())
+		}
+		_, err := tx.Exec(`DELETE FROM answer.schema_migrations WHERE version = $1`, migration.Version)
+		return err
+	})
+}
+
+func inTx(conn *pgx.Conn, f func(tx *pgx.Tx) error) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadMigrationsOrdered(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0002_second.up.sql":   "up 2",
+		"0002_second.down.sql": "down 2",
+		"0001_first.up.sql":    "up 1",
+		"0001_first.down.sql":  "down 1",
+		"README.md":            "ignored",
+	})
+	defer os.RemoveAll(dir)
+
+	migrations, err := LoadMigrations(dir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []Migration{
+			{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+			{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+		}, migrations)
+		assert.Equal(t, 2, (&Migrator{Migrations: migrations}).Latest())
+	}
+}
+
+func TestLoadMigrationsMissedDown(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0001_first.up.sql": "up 1",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := LoadMigrations(dir)
+	assert.NotNil(t, err)
+}
+
+func TestLoadMigrationsNameMismatch(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0001_first.up.sql":   "up 1",
+		"0001_other.down.sql": "down 1",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := LoadMigrations(dir)
+	assert.NotNil(t, err)
+}
+
+func TestLoadMigrationsShipped(t *testing.T) {
+	migrations, err := LoadMigrations("migrations")
+	if assert.Nil(t, err) && assert.NotEmpty(t, migrations) {
+		assert.Equal(t, 1, migrations[0].Version)
+		assert.Equal(t, "init", migrations[0].Name)
+	}
+}
+
+func TestMigrateToUnknownVersion(t *testing.T) {
+	m := &Migrator{Migrations: []Migration{{Version: 1, Name: "first", Up: "up", Down: "down"}}}
+	assert.Equal(t, ErrUnknownMigration, m.To(2))
+}
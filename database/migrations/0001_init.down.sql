@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS answer.services;
+DROP TABLE IF EXISTS answer.answer;
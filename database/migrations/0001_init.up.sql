@@ -1,11 +1,7 @@
-SET SYNCHRONOUS_COMMIT = 'off';
 CREATE EXTENSION IF NOT EXISTS CITEXT;
 CREATE SCHEMA IF NOT EXISTS answer;
 
-DROP TABLE IF EXISTS answer.answer;
-DROP TABLE IF EXISTS answer.services;
-
-CREATE TABLE answer.answer (
+CREATE TABLE IF NOT EXISTS answer.answer (
 	id SERIAL PRIMARY KEY,
 	question_id INTEGER NOT NULL,
 	content CITEXT NULL,
@@ -20,7 +16,7 @@ CREATE INDEX IF NOT EXISTS author_id_index ON answer.answer (author_id);
 CREATE INDEX IF NOT EXISTS is_best_index ON answer.answer (is_best);
 CREATE INDEX IF NOT EXISTS question_id__is_best_index ON answer.answer (question_id, is_best);
 
-CREATE TABLE answer.services (
+CREATE TABLE IF NOT EXISTS answer.services (
 	id SERIAL PRIMARY KEY,
 	request CITEXT NOT NULL,
 	request_time TIMESTAMPTZ DEFAULT NOW(),
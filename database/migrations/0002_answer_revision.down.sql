@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS answer.answer_revision;
+
+ALTER TABLE answer.answer
+	DROP COLUMN IF EXISTS revision,
+	DROP COLUMN IF EXISTS updated;
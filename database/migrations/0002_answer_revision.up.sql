@@ -0,0 +1,19 @@
+ALTER TABLE answer.answer
+	ADD COLUMN updated TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+UPDATE answer.answer SET updated = created WHERE created IS NOT NULL;
+
+CREATE TABLE answer.answer_revision (
+	id SERIAL PRIMARY KEY,
+	answer_id INTEGER NOT NULL REFERENCES answer.answer (id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	content CITEXT NULL,
+	editor_id INTEGER NOT NULL,
+	summary TEXT NOT NULL DEFAULT '',
+	created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (answer_id, revision)
+);
+
+INSERT INTO answer.answer_revision (answer_id, revision, content, editor_id, created)
+	SELECT id, 1, content, author_id, COALESCE(created, NOW()) FROM answer.answer;
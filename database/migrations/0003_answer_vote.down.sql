@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS answer.answer_vote;
+DROP INDEX IF EXISTS answer.question_id__score_index;
+
+ALTER TABLE answer.answer DROP COLUMN IF EXISTS score;
@@ -0,0 +1,11 @@
+ALTER TABLE answer.answer ADD COLUMN score INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX question_id__score_index ON answer.answer (question_id, score DESC, id);
+
+CREATE TABLE answer.answer_vote (
+	answer_id INTEGER NOT NULL REFERENCES answer.answer (id) ON DELETE CASCADE,
+	voter_id INTEGER NOT NULL,
+	value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+	created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (answer_id, voter_id)
+);
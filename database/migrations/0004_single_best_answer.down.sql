@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS answer.question_id__single_best_index;
+
+ALTER TABLE answer.answer ALTER COLUMN is_best DROP NOT NULL;
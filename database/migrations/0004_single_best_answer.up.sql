@@ -0,0 +1,10 @@
+UPDATE answer.answer SET is_best = FALSE WHERE is_best IS NULL;
+ALTER TABLE answer.answer ALTER COLUMN is_best SET NOT NULL;
+
+-- keep only the newest best answer of every question
+UPDATE answer.answer a SET is_best = FALSE
+	WHERE a.is_best AND EXISTS (
+		SELECT 1 FROM answer.answer b WHERE b.question_id = a.question_id AND b.is_best AND b.id > a.id
+	);
+
+CREATE UNIQUE INDEX question_id__single_best_index ON answer.answer (question_id) WHERE is_best;
@@ -0,0 +1,6 @@
+DELETE FROM answer.answer WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS answer.deleted_at_index;
+
+ALTER TABLE answer.answer
+	DROP COLUMN IF EXISTS deleted_by,
+	DROP COLUMN IF EXISTS deleted_at;
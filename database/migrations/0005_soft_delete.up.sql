@@ -0,0 +1,5 @@
+ALTER TABLE answer.answer
+	ADD COLUMN deleted_at TIMESTAMPTZ NULL,
+	ADD COLUMN deleted_by INTEGER NULL;
+
+CREATE INDEX deleted_at_index ON answer.answer (deleted_at) WHERE deleted_at IS NOT NULL;
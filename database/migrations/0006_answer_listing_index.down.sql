@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS answer.author_id__created_index;
+DROP INDEX IF EXISTS answer.question_id__created_index;
@@ -0,0 +1,2 @@
+CREATE INDEX question_id__created_index ON answer.answer (question_id, created, id);
+CREATE INDEX author_id__created_index ON answer.answer (author_id, created, id);
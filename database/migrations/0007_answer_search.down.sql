@@ -0,0 +1 @@
+DROP INDEX IF EXISTS answer.content__search_index;
@@ -0,0 +1,2 @@
+-- expression must stay equal to searchVector in model/search.go, otherwise the index isn't used
+CREATE INDEX content__search_index ON answer.answer USING GIN (to_tsvector('simple', content::text));
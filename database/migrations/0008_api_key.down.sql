@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS answer.api_key_id_index;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS answer.api_key;
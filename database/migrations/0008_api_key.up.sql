@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS answer.api_key (
+	id SERIAL PRIMARY KEY,
+	name CITEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	created TIMESTAMPTZ DEFAULT NOW(),
+	revoked TIMESTAMPTZ NULL
+);
+
+ALTER TABLE answer.services ADD COLUMN api_key_id INTEGER NULL REFERENCES answer.api_key (id);
+CREATE INDEX api_key_id_index ON answer.services (api_key_id);
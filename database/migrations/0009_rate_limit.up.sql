@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS answer.rate_limit (
+	key TEXT PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	updated TIMESTAMPTZ NOT NULL
+);
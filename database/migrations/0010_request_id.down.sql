@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS answer.request_id_index;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS request_id;
@@ -0,0 +1,2 @@
+ALTER TABLE answer.services ADD COLUMN request_id TEXT NULL;
+CREATE INDEX request_id_index ON answer.services (request_id);
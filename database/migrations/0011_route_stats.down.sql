@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS answer.request_time_index;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS client;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS duration_ms;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS route;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS method;
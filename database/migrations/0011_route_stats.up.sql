@@ -0,0 +1,5 @@
+ALTER TABLE answer.services ADD COLUMN method TEXT NULL;
+ALTER TABLE answer.services ADD COLUMN route TEXT NULL;
+ALTER TABLE answer.services ADD COLUMN duration_ms DOUBLE PRECISION NULL;
+ALTER TABLE answer.services ADD COLUMN client TEXT NULL;
+CREATE INDEX request_time_index ON answer.services (request_time);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS answer.services_rollup;
+DROP TABLE IF EXISTS answer.services_hourly;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS answer.services_hourly (
+	hour TIMESTAMPTZ NOT NULL,
+	method TEXT NOT NULL,
+	route TEXT NOT NULL,
+	api_key_id INTEGER NOT NULL,
+	requests INTEGER NOT NULL,
+	client_errors INTEGER NOT NULL,
+	server_errors INTEGER NOT NULL,
+	duration_ms_sum DOUBLE PRECISION NOT NULL,
+	last_request_time TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (hour, method, route, api_key_id)
+);
+CREATE INDEX services_hourly_api_key_id_index ON answer.services_hourly (api_key_id);
+
+CREATE TABLE IF NOT EXISTS answer.services_rollup (
+	id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	rolled_up_to TIMESTAMPTZ NOT NULL,
+	requests BIGINT NOT NULL
+);
+INSERT INTO answer.services_rollup (rolled_up_to, requests) VALUES ('epoch', 0);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS answer.outbox (
+	id BIGSERIAL PRIMARY KEY,
+	event_id TEXT NOT NULL UNIQUE,
+	type TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	answer_id INTEGER NOT NULL,
+	question_id INTEGER NOT NULL,
+	payload JSONB NOT NULL,
+	created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_error TEXT NULL,
+	published TIMESTAMPTZ NULL
+);
+CREATE INDEX outbox_pending_index ON answer.outbox (next_attempt, id) WHERE published IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS answer.webhook_dead_letter;
+DROP TABLE IF EXISTS answer.webhook_delivery;
+DROP TABLE IF EXISTS answer.webhook;
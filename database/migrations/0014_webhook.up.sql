@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS answer.webhook (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	event_types TEXT[] NOT NULL DEFAULT '{}',
+	question_id INTEGER NULL,
+	created TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS answer.webhook_delivery (
+	id BIGSERIAL PRIMARY KEY,
+	webhook_id INTEGER NOT NULL REFERENCES answer.webhook (id) ON DELETE CASCADE,
+	event_id TEXT NOT NULL,
+	event JSONB NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_error TEXT NULL,
+	UNIQUE (webhook_id, event_id)
+);
+CREATE INDEX webhook_delivery_due_index ON answer.webhook_delivery (next_attempt, id);
+CREATE TABLE IF NOT EXISTS answer.webhook_dead_letter (
+	id BIGINT PRIMARY KEY,
+	webhook_id INTEGER NOT NULL REFERENCES answer.webhook (id) ON DELETE CASCADE,
+	event_id TEXT NOT NULL,
+	event JSONB NOT NULL,
+	attempts INTEGER NOT NULL,
+	last_error TEXT NULL,
+	failed TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS answer.services_not_rolled_up_index;
+ALTER TABLE answer.services DROP COLUMN IF EXISTS rolled_up;
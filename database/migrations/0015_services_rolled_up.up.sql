@@ -0,0 +1,3 @@
+ALTER TABLE answer.services ADD COLUMN rolled_up BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE answer.services SET rolled_up = TRUE WHERE request_time < (SELECT rolled_up_to FROM answer.services_rollup);
+CREATE INDEX services_not_rolled_up_index ON answer.services (request_time) WHERE NOT rolled_up;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS answer.outbox_published_index;
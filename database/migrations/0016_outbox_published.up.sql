@@ -0,0 +1 @@
+CREATE INDEX outbox_published_index ON answer.outbox (published) WHERE published IS NOT NULL;
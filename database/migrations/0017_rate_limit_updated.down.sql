@@ -0,0 +1 @@
+DROP INDEX IF EXISTS answer.rate_limit_updated_index;
@@ -0,0 +1 @@
+CREATE INDEX rate_limit_updated_index ON answer.rate_limit (updated);
@@ -0,0 +1,124 @@
+// Package health tells orchestrators whether the service is alive and ready to serve requests
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports why a dependency can not serve requests, nil when it is fine
+type Check func(ctx context.Context) error
+
+// Result of one check
+type Result struct {
+	OK       bool    `json:"ok"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// Report readiness of service with result of every check
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Timeout checks which have not finished in time are failed
+var Timeout = 2 * time.Second
+
+// ErrDraining reported while instance is being drained
+var ErrDraining = errors.New("instance is draining")
+
+// errTimeout reported for checks which have not finished in Timeout
+var errTimeout = errors.New("check timed out")
+
+var (
+	mu       sync.RWMutex
+	checks   = map[string]Check{}
+	draining int32
+)
+
+// Register adds check name to readiness, check registered with the same name is replaced
+func Register(name string, c Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks[name] = c
+}
+
+// SetDraining marks instance not ready, so that balancers stop sending requests before shutdown
+func SetDraining(on bool) {
+	v := int32(0)
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&draining, v)
+}
+
+// Draining reports whether instance is marked not ready
+func Draining() bool {
+	return atomic.LoadInt32(&draining) == 1
+}
+
+// Ready runs every registered check concurrently within Timeout
+func Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	mu.RLock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	funcs := make([]Check, len(names))
+	for i, name := range names {
+		funcs[i] = checks[name]
+	}
+	mu.RUnlock()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i := range funcs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = run(ctx, funcs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report := Report{Ready: !Draining(), Checks: map[string]Result{}}
+	if !report.Ready {
+		report.Checks["draining"] = Result{Error: ErrDraining.Error()}
+	}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		report.Ready = report.Ready && results[i].OK
+	}
+	return report
+}
+
+// run waits for check until ctx is done, check left running is abandoned
+func run(ctx context.Context, c Check) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errTimeout
+	}
+
+	r := Result{OK: err == nil, Duration: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// use replaces registered checks until returned restore is called
+func use(c map[string]Check) (restore func()) {
+	mu.Lock()
+	prev := checks
+	checks = c
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		checks = prev
+		mu.Unlock()
+		SetDraining(false)
+	}
+}
+
+func TestReadyWithoutChecks(t *testing.T) {
+	defer use(map[string]Check{})()
+
+	report := Ready(context.Background())
+	assert.True(t, report.Ready)
+	assert.Equal(t, 0, len(report.Checks))
+}
+
+func TestReadyFailedCheck(t *testing.T) {
+	defer use(map[string]Check{})()
+	Register("database", func(context.Context) error { return nil })
+	Register("migrations", func(context.Context) error { return errors.New("2 migrations are not applied, first is 9") })
+
+	report := Ready(context.Background())
+	assert.False(t, report.Ready)
+	assert.True(t, report.Checks["database"].OK)
+	assert.False(t, report.Checks["migrations"].OK)
+	assert.Equal(t, "2 migrations are not applied, first is 9", report.Checks["migrations"].Error)
+}
+
+func TestReadyTimeout(t *testing.T) {
+	defer use(map[string]Check{})()
+	prev := Timeout
+	Timeout = 10 * time.Millisecond
+	defer func() { Timeout = prev }()
+
+	block := make(chan struct{})
+	defer close(block)
+	Register("stuck", func(context.Context) error { <-block; return nil })
+
+	start := time.Now()
+	report := Ready(context.Background())
+	assert.True(t, time.Since(start) < time.Second)
+	assert.False(t, report.Ready)
+	assert.Equal(t, errTimeout.Error(), report.Checks["stuck"].Error)
+}
+
+func TestDraining(t *testing.T) {
+	defer use(map[string]Check{})()
+	Register("database", func(context.Context) error { return nil })
+
+	SetDraining(true)
+	assert.True(t, Draining())
+	report := Ready(context.Background())
+	assert.False(t, report.Ready)
+	assert.Equal(t, ErrDraining.Error(), report.Checks["draining"].Error)
+
+	SetDraining(false)
+	assert.True(t, Ready(context.Background()).Ready)
+}
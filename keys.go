@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/controller"
+)
+
+var keysUsage = "usage: answer keys create <name> <scope>...|list|revoke <id>\nscopes: " +
+	strings.Join(auth.Scopes, ", ")
+
+// runKeys handles "keys" subcommand managing service API keys
+func runKeys(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(keysUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return errors.New(keysUsage)
+		}
+		k, key, err := controller.CreateAPIKey(context.Background(), args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d %s %s\n%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), key)
+		fmt.Fprintln(out, "The key is shown only once, store it now.")
+	case "list":
+		if len(args) != 1 {
+			return errors.New(keysUsage)
+		}
+		keys, err := controller.APIKeysGET(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			state := "active"
+			if k.Revoked != nil {
+				state = "revoked " + k.Revoked.Format("2006-01-02 15:04:05")
+			}
+			lastUsed := "never used"
+			if k.LastUsed != nil {
+				lastUsed = "last used " + k.LastUsed.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%4d %-20s %-11s %-40s %6d requests, %s, %s\n",
+				k.ID, k.Name, k.Prefix, strings.Join(k.Scopes, ","), k.RequestsCount, lastUsed, state)
+		}
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(keysUsage)
+		}
+		if err := controller.RevokeAPIKey(context.Background(), args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "key %s revoked\n", args[1])
+	default:
+		return errors.New(keysUsage)
+	}
+	return nil
+}
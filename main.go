@@ -3,25 +3,210 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/config"
 	"github.com/RSOI/answer/controller"
 	"github.com/RSOI/answer/database"
+	"github.com/RSOI/answer/health"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/outbox"
+	"github.com/RSOI/answer/ratelimit"
+	"github.com/RSOI/answer/tracing"
 	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/webhook"
+	"github.com/jackc/pgx"
 	"github.com/valyala/fasthttp"
 )
 
-// PORT application port
-const PORT = 8081
-
 func main() {
-	if len(os.Args) > 1 {
-		utils.DEBUG = os.Args[1] == "debug"
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	level, _ := utils.ParseLevel(cfg.LogLevel)
+	utils.SetLevel(level)
+	utils.SetOutput(os.Stderr, cfg.LogFormat)
+	database.DSN = cfg.DB.DSN
+	database.POOLSIZE = cfg.DB.PoolSize
+	database.MIGRATIONS = cfg.DB.Migrations
+	model.PageSize = cfg.PageSize
+	model.MaxPageSize = cfg.MaxPageSize
+	webhook.AllowPrivateHosts = cfg.Webhook.AllowPrivateHosts
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(args[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "keys" {
+		controller.Init(database.Connect())
+		if err := runKeys(args[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+		os.Exit(2)
+	}
+
+	apiKeys = cfg.Auth.APIKeys
+	openAdmin = cfg.Auth.OpenAdmin
+	verifier, err = auth.NewVerifier(cfg.Auth)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := run(cfg); err != nil {
+		utils.Error("Answer service stopped", utils.Fields{"err": err})
+		os.Exit(1)
+	}
+}
+
+// run serves requests until SIGTERM or SIGINT, resources are released in reverse order on return
+func run(cfg config.Config) error {
+	shutdownTracing, err := tracing.Init(cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	utils.Info("Answer service is starting", utils.Fields{"listen": cfg.Listen})
+
+	var db *pgx.ConnPool
+	if cfg.Backend == "memory" {
+		controller.InitMemory()
+	} else {
+		db = database.Connect()
+		defer db.Close()
+		controller.Init(db)
+
+		migrator, err := database.NewMigrator(db, cfg.DB.Migrations)
+		if err != nil {
+			return err
+		}
+		health.Register("database", database.Ping(db))
+		health.Register("migrations", migrator.Check)
+	}
+
+	controller.InitQuestions(cfg.Question)
+
+	var store ratelimit.Store = &ratelimit.MemoryStore{}
+	if cfg.RateLimit.Store == "postgres" {
+		store = &ratelimit.PostgresStore{Conn: db}
+	}
+	limiter = ratelimit.New(store, map[string]ratelimit.Limit{
+		ratelimit.GroupRead:  limitOf(cfg.RateLimit.Read),
+		ratelimit.GroupWrite: limitOf(cfg.RateLimit.Write),
+		ratelimit.GroupAdmin: limitOf(cfg.RateLimit.Admin),
+		ratelimit.GroupKeys:  limitOf(cfg.RateLimit.Keys),
+	})
+	controller.Limiter = limiter
+
+	statsWriter := controller.StartStats(cfg.Stats)
+	health.Register("stats", statsWriter.Check)
+
+	stopRollup := controller.StartRollup(cfg.Stats.Retention, cfg.Stats.RollupInterval)
+	defer stopRollup()
+
+	if pub := publisherOf(cfg.Outbox); pub != nil {
+		stopRelay := outbox.NewRelay(controller.AnswerModel, pub, cfg.Outbox).Start()
+		defer stopRelay()
+	}
+	if cfg.Outbox.Publisher == "webhook" {
+		stopDispatcher := webhook.NewDispatcher(controller.Webhooks, cfg.Webhook).Start()
+		defer stopDispatcher()
+	}
+
+	// memory buckets are swept while taken from, shared ones only by purge
+	if cfg.Trash.Retention > 0 || cfg.Outbox.Retention > 0 || cfg.RateLimit.Store == "postgres" {
+		stopPurge := controller.StartPurge(cfg.Trash.Retention, cfg.Outbox.Retention, cfg.Trash.PurgeInterval)
+		defer stopPurge()
+	}
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return err
 	}
-	utils.LOG("Launched in debug mode...")
-	utils.LOG(fmt.Sprintf("Answer service is starting on localhost: %d", PORT))
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(stop)
+
+	return serve(&fasthttp.Server{Handler: initRoutes().Handler}, ln, stop, cfg.Shutdown, statsWriter.Close)
+}
+
+// serve runs server on ln until stop signal. Then instance is reported not ready for drain delay,
+// stops accepting connections and waits up to timeout for requests being served.
+// flush writes stats of served requests within what is left of timeout, nil skips it.
+func serve(server *fasthttp.Server, ln net.Listener, stop <-chan os.Signal, cfg config.ShutdownConfig,
+	flush func(context.Context) error) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-served:
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		flushStats(ctx, flush)
+		return err
+	case sig := <-stop:
+		utils.Info("Shutting down", utils.Fields{"signal": sig, "drain_delay": cfg.DrainDelay, "timeout": cfg.Timeout})
+	}
+
+	health.SetDraining(true)
+	time.Sleep(cfg.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	err := server.ShutdownWithContext(ctx)
+	flushStats(ctx, flush)
+	if err != nil {
+		return fmt.Errorf("requests were not finished in %s: %s", cfg.Timeout, err.Error())
+	}
+	if err := <-served; err != nil {
+		return err
+	}
+	utils.Info("Answer service stopped")
+	return nil
+}
+
+// flushStats runs flush until ctx is done, lost stats are logged
+func flushStats(ctx context.Context, flush func(context.Context) error) {
+	if flush == nil {
+		return
+	}
+	if err := flush(ctx); err != nil {
+		utils.Error("Stats are lost on shutdown", utils.Fields{"err": err})
+	}
+}
+
+// publisherOf returns publisher of answer events, nil leaves them in outbox
+func publisherOf(cfg config.OutboxConfig) outbox.Publisher {
+	switch cfg.Publisher {
+	case "log":
+		return outbox.LogPublisher{}
+	case "webhook":
+		return webhook.Publisher{Store: controller.Webhooks}
+	}
+	return nil
+}
 
-	controller.Init(database.Connect())
-	fasthttp.ListenAndServe(fmt.Sprintf(":%d", PORT), initRoutes().Handler)
+func limitOf(r config.Rate) ratelimit.Limit {
+	return ratelimit.Limit{Burst: r.Requests, Per: r.Per}
 }
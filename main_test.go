@@ -1,15 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/RSOI/answer/auth"
+	"github.com/RSOI/answer/config"
 	"github.com/RSOI/answer/controller"
+	"github.com/RSOI/answer/health"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/outbox"
+	"github.com/RSOI/answer/ratelimit"
 	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/webhook"
 
+	jwt "github.com/golang-jwt/jwt"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttputil"
 
@@ -29,36 +46,117 @@ func (s *MockedAService) DeleteAnswerByID(a model.Answer) error {
 	args := s.Mock.Called(a)
 	return args.Error(0)
 }
-func (s *MockedAService) DeleteAnswerByAuthorID(a model.Answer) error {
+func (s *MockedAService) DeleteAnswerByAuthorID(a model.Answer) (int, error) {
 	args := s.Mock.Called(a)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
-func (s *MockedAService) DeleteAnswerByQuestionID(a model.Answer) error {
+func (s *MockedAService) DeleteAnswerByQuestionID(a model.Answer) (int, error) {
 	args := s.Mock.Called(a)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) RestoreAnswer(a model.Answer) (model.Answer, error) {
+	args := s.Mock.Called(a)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) PurgeDeleted(before time.Time) (int, error) {
+	args := s.Mock.Called(before)
+	return args.Int(0), args.Error(1)
 }
-func (s *MockedAService) GetAnswerByID(qID int) (model.Answer, error) {
-	args := s.Mock.Called(qID)
+func (s *MockedAService) GetAnswerByID(qID int, includeDeleted bool) (model.Answer, error) {
+	args := s.Mock.Called(qID, includeDeleted)
 	return args.Get(0).(model.Answer), args.Error(1)
 }
-func (s *MockedAService) GetAnswersByAuthorID(aAuthorID int, limit int, offset int) ([]model.Answer, error) {
-	args := s.Mock.Called(aAuthorID, limit, offset)
-	return args.Get(0).([]model.Answer), args.Error(1)
+func (s *MockedAService) GetAnswersByAuthorID(aAuthorID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	args := s.Mock.Called(aAuthorID, q)
+	return args.Get(0).(model.AnswersPage), args.Error(1)
+}
+func (s *MockedAService) GetAnswersByQuestionID(aQuestionID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	args := s.Mock.Called(aQuestionID, q)
+	return args.Get(0).(model.AnswersPage), args.Error(1)
+}
+func (s *MockedAService) SearchAnswers(q model.SearchQuery) ([]model.SearchResult, error) {
+	args := s.Mock.Called(q)
+	return args.Get(0).([]model.SearchResult), args.Error(1)
 }
-func (s *MockedAService) GetAnswersByQuestionID(aQuestionID int, limit int, offset int) ([]model.Answer, error) {
-	args := s.Mock.Called(aQuestionID, limit, offset)
-	return args.Get(0).([]model.Answer), args.Error(1)
+func (s *MockedAService) UpdateAnswer(a model.Answer) (model.BestAnswer, error) {
+	args := s.Mock.Called(a)
+	return args.Get(0).(model.BestAnswer), args.Error(1)
 }
-func (s *MockedAService) UpdateAnswer(a model.Answer) (model.Answer, error) {
+func (s *MockedAService) UnmarkBestAnswer(a model.Answer) (model.Answer, error) {
 	args := s.Mock.Called(a)
 	return args.Get(0).(model.Answer), args.Error(1)
 }
+func (s *MockedAService) EditAnswer(r model.Revision) (model.Answer, error) {
+	args := s.Mock.Called(r)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) RollbackAnswer(r model.Revision) (model.Answer, error) {
+	args := s.Mock.Called(r)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
+func (s *MockedAService) GetRevisions(aID int) ([]model.Revision, error) {
+	args := s.Mock.Called(aID)
+	return args.Get(0).([]model.Revision), args.Error(1)
+}
+func (s *MockedAService) GetRevision(aID int, revision int) (model.Revision, error) {
+	args := s.Mock.Called(aID, revision)
+	return args.Get(0).(model.Revision), args.Error(1)
+}
+func (s *MockedAService) VoteAnswer(v model.Vote) (model.Answer, error) {
+	args := s.Mock.Called(v)
+	return args.Get(0).(model.Answer), args.Error(1)
+}
 func (s *MockedAService) GetUsageStatistic(host string) (model.ServiceStatus, error) {
 	args := s.Mock.Called(host)
 	return args.Get(0).(model.ServiceStatus), args.Error(1)
 }
-func (s *MockedAService) LogStat(request []byte, responseStatus int, responseError string) {
+func (s *MockedAService) GetRouteStats(since time.Time) ([]model.RouteStat, error) {
+	args := s.Mock.Called(since)
+	return args.Get(0).([]model.RouteStat), args.Error(1)
+}
+func (s *MockedAService) RollupStats(until time.Time) (int, error) {
+	args := s.Mock.Called(until)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) PurgeStats(before time.Time) (int, error) {
+	args := s.Mock.Called(before)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) ClaimEvents(limit int, lease time.Duration) ([]model.Event, error) {
+	args := s.Mock.Called(limit, lease)
+	return args.Get(0).([]model.Event), args.Error(1)
+}
+func (s *MockedAService) MarkEventsPublished(ids []string) error {
+	args := s.Mock.Called(ids)
+	return args.Error(0)
+}
+func (s *MockedAService) RetryEvent(id string, at time.Time, reason string) error {
+	args := s.Mock.Called(id, at, reason)
+	return args.Error(0)
+}
+func (s *MockedAService) PurgeEvents(before time.Time) (int, error) {
+	args := s.Mock.Called(before)
+	return args.Int(0), args.Error(1)
+}
+func (s *MockedAService) AddAPIKey(k model.APIKey) (model.APIKey, error) {
+	args := s.Mock.Called(k)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+func (s *MockedAService) GetAPIKeys() ([]model.APIKey, error) {
+	args := s.Mock.Called()
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+func (s *MockedAService) GetAPIKeyByHash(hash string) (model.APIKey, error) {
+	args := s.Mock.Called(hash)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+func (s *MockedAService) RevokeAPIKey(id int) error {
+	args := s.Mock.Called(id)
+	return args.Error(0)
+}
+func (s *MockedAService) LogStats(stats []model.RequestInfo) error {
 	// nothing interesting here, just store data without affecting main thread
+	return nil
 }
 
 var (
@@ -219,7 +317,7 @@ func TestAnswerGetByIDCorrectData(t *testing.T) {
 	req.SetRequestURI(HOST + "/answer/id1")
 	req.Header.SetMethod("GET")
 
-	cMock.On("GetAnswerByID", 1).Return(createdAnswer, nil)
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -246,7 +344,7 @@ func TestAnswerGetByIDNotFound(t *testing.T) {
 	req.SetRequestURI(HOST + "/answer/id0")
 	req.Header.SetMethod("GET")
 
-	cMock.On("GetAnswerByID", 0).Return(model.Answer{}, ui.ErrNoResult)
+	cMock.On("GetAnswerByID", 0, false).Return(model.Answer{}, ui.ErrNoResult)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -276,7 +374,7 @@ func TestAnswerGetByAuthorIDCorrectData(t *testing.T) {
 	createdAnswers := make([]model.Answer, 0)
 	createdAnswers = append(createdAnswers, createdAnswer)
 	createdAnswers = append(createdAnswers, createdAnswer)
-	cMock.On("GetAnswersByAuthorID", 1, 0, 0).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByAuthorID", 1, model.AnswersQuery{Order: model.OrderByID}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -308,7 +406,7 @@ func TestAnswerGetByAuthorIDNotFound(t *testing.T) {
 	req.Header.SetMethod("GET")
 
 	createdAnswers := make([]model.Answer, 0)
-	cMock.On("GetAnswersByAuthorID", 1, 0, 0).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByAuthorID", 1, model.AnswersQuery{Order: model.OrderByID}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -340,7 +438,7 @@ func TestAnswerGetByQuestionIDCorrectData(t *testing.T) {
 	createdAnswers := make([]model.Answer, 0)
 	createdAnswers = append(createdAnswers, createdAnswer)
 	createdAnswers = append(createdAnswers, createdAnswer)
-	cMock.On("GetAnswersByQuestionID", 1, 0, 0).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByID}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -372,7 +470,7 @@ func TestAnswerGetByQuestionIDNotFound(t *testing.T) {
 	req.Header.SetMethod("GET")
 
 	createdAnswers := make([]model.Answer, 0)
-	cMock.On("GetAnswersByQuestionID", 1, 0, 0).Return(createdAnswers, nil)
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByID}).Return(model.AnswersPage{Answers: createdAnswers}, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -389,6 +487,44 @@ func TestAnswerGetByQuestionIDNotFound(t *testing.T) {
 	}
 }
 
+func TestAnswerGetByQuestionIDSortByScore(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answers/question1?sort=score")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByScore}).Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestVoteRouteCorrectData(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/vote")
+	req.Header.SetMethod("PATCH")
+	req.SetBodyString("{\"answer_id\": 1, \"voter_id\": 2, \"value\": -1}")
+
+	voted := createdAnswer
+	voted.Score = -1
+	cMock.On("VoteAnswer", model.Vote{AnswerID: 1, VoterID: 2, Value: -1}).Return(voted, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		responseData := response.Data.(map[string]interface{})
+		assert.Equal(t, -1, int(responseData["score"].(float64)))
+	}
+}
+
 /*
 ********************************************************************
 TESTS FOR UPDATE QUESTION ******************************************
@@ -404,7 +540,7 @@ func TestUpdateCorrectData(t *testing.T) {
 	req.Header.SetMethod("PATCH")
 	req.SetBody(source)
 
-	cMock.On("UpdateAnswer", updatedAnswer).Return(updatedAnswer, nil)
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{Answer: updatedAnswer}, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -421,6 +557,52 @@ func TestUpdateCorrectData(t *testing.T) {
 	}
 }
 
+func TestUpdateReportsPreviousBest(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	source, _ := json.Marshal(updatedAnswer)
+
+	req.SetRequestURI(HOST + "/best")
+	req.Header.SetMethod("PATCH")
+	req.SetBody(source)
+
+	previousID := 2
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{Answer: updatedAnswer, PreviousBestID: &previousID}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		responseData := response.Data.(map[string]interface{})
+		assert.Equal(t, updatedAnswer.ID, int(responseData["id"].(float64)))
+		assert.Equal(t, previousID, int(responseData["previous_best_id"].(float64)))
+	}
+}
+
+func TestUnmarkBestCorrectData(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/best")
+	req.Header.SetMethod("DELETE")
+	req.SetBodyString("{\"id\": 1}")
+
+	cMock.On("UnmarkBestAnswer", model.Answer{ID: 1}).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		responseData := response.Data.(map[string]interface{})
+		assert.Equal(t, false, responseData["is_best"])
+	}
+}
+
 func TestUpdateNotFound(t *testing.T) {
 	client, req, res, cMock := initServer()
 
@@ -430,7 +612,7 @@ func TestUpdateNotFound(t *testing.T) {
 	req.Header.SetMethod("PATCH")
 	req.SetBody(source)
 
-	cMock.On("UpdateAnswer", updatedAnswer).Return(model.Answer{}, ui.ErrNoDataToUpdate)
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{}, ui.ErrNoDataToUpdate)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -483,6 +665,81 @@ func TestUpdateBrokenBody(t *testing.T) {
 	}
 }
 
+/*
+********************************************************************
+TESTS FOR REVISIONS ************************************************
+********************************************************************
+*/
+
+func TestEditRouteCorrectData(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	content := "My Edited Content"
+	edit := model.Revision{AnswerID: 1, Content: &content, EditorID: 2}
+	edited := createdAnswer
+	edited.Content = &content
+	edited.Revision = 2
+	source, _ := json.Marshal(edit)
+
+	req.SetRequestURI(HOST + "/edit")
+	req.Header.SetMethod("PATCH")
+	req.SetBody(source)
+
+	cMock.On("EditAnswer", edit).Return(edited, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		responseData := response.Data.(map[string]interface{})
+		assert.Equal(t, content, responseData["content"])
+		assert.Equal(t, 2, int(responseData["revision"].(float64)))
+	}
+}
+
+func TestRevisionsRouteCorrectData(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1/revisions")
+	req.Header.SetMethod("GET")
+
+	revisions := []model.Revision{
+		{AnswerID: 1, Revision: 1, Content: &defaultAnswerContent, EditorID: 1},
+		{AnswerID: 1, Revision: 2, Content: &defaultAnswerContent, EditorID: 2, Summary: "typo"},
+	}
+	cMock.On("GetRevisions", 1).Return(revisions, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		responseData := response.Data.([]interface{})
+		assert.Equal(t, 2, len(responseData))
+		assert.Equal(t, "typo", responseData[1].(map[string]interface{})["summary"])
+	}
+}
+
+func TestRevisionRouteNotFound(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1/revisions/3")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetRevision", 1, 3).Return(model.Revision{}, ui.ErrNoResult)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 404, res.Header.StatusCode())
+	}
+}
+
 /*
 ********************************************************************
 TESTS FOR REMOVE ANSWER ********************************************
@@ -528,7 +785,7 @@ func TestRemoveByAuthorIDCorrectData(t *testing.T) {
 	req.Header.SetMethod("DELETE")
 	req.SetBody(answerToRemoveJSON)
 
-	cMock.On("DeleteAnswerByAuthorID", answerToRemove).Return(nil)
+	cMock.On("DeleteAnswerByAuthorID", answerToRemove).Return(1, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -555,7 +812,7 @@ func TestRemoveByQuestionIDCorrectData(t *testing.T) {
 	req.Header.SetMethod("DELETE")
 	req.SetBody(answerToRemoveJSON)
 
-	cMock.On("DeleteAnswerByQuestionID", answerToRemove).Return(nil)
+	cMock.On("DeleteAnswerByQuestionID", answerToRemove).Return(1, nil)
 
 	err := client.Do(req, res)
 	if assert.Nil(t, err) {
@@ -618,3 +875,1193 @@ func TestRemoveMissedIDs(t *testing.T) {
 		assert.Equal(t, nil, response.Data)
 	}
 }
+
+/*
+********************************************************************
+TESTS FOR TRASH ****************************************************
+********************************************************************
+*/
+
+func TestAnswerGetIncludeDeleted(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1?include_deleted=true")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswerByID", 1, true).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestIncludeDeletedOnlyForModerators(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, _ := initServer()
+	controller.AnswerModel = &model.AMemoryService{}
+	deleted, _ := controller.AnswerModel.AddAnswer(defaultAnswer)
+	controller.AnswerModel.DeleteAnswerByID(model.Answer{ID: deleted.ID})
+
+	uri := HOST + "/answers/question" + strconv.Itoa(defaultAnswer.QuestionID) + "?include_deleted=true"
+	for _, token := range []string{"", bearer("5")} {
+		req.SetRequestURI(uri)
+		req.Header.SetMethod("GET")
+		req.Header.Del("Authorization")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		if assert.Nil(t, client.Do(req, res)) {
+			assert.Equal(t, 403, res.StatusCode())
+			var response ui.Response
+			json.Unmarshal(res.Body(), &response)
+			assert.Nil(t, response.Data, "deleted answers are not listed")
+		}
+	}
+
+	req.Header.Set("Authorization", bearer("7", "moderator"))
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 200, res.StatusCode())
+		var response struct {
+			Data []model.Answer `json:"data"`
+		}
+		json.Unmarshal(res.Body(), &response)
+		if assert.Equal(t, 1, len(response.Data)) {
+			assert.Equal(t, deleted.ID, response.Data[0].ID)
+		}
+	}
+}
+
+func TestRestoreCorrectData(t *testing.T) {
+	defer withOpenAdmin()()
+	client, req, res, cMock := initServer()
+
+	answerToRestore := model.Answer{
+		ID: 1,
+	}
+	answerToRestoreJSON, _ := json.Marshal(&answerToRestore)
+
+	req.SetRequestURI(HOST + "/restore")
+	req.Header.SetMethod("PATCH")
+	req.SetBody(answerToRestoreJSON)
+
+	cMock.On("RestoreAnswer", answerToRestore).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		assert.Equal(t, "", response.Error)
+		responseData := response.Data.(map[string]interface{})
+		assert.Equal(t, createdAnswer.ID, int(responseData["id"].(float64)))
+		assert.Nil(t, responseData["deleted_at"])
+	}
+}
+
+func TestRestoreNotDeleted(t *testing.T) {
+	defer withOpenAdmin()()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/restore")
+	req.Header.SetMethod("PATCH")
+	req.SetBody([]byte("{\"id\": 1}"))
+
+	cMock.On("RestoreAnswer", model.Answer{ID: 1}).Return(model.Answer{}, ui.ErrNoDataToUpdate)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		assert.Equal(t, ui.ErrNoDataToUpdate.Error(), response.Error)
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR PAGINATION ***********************************************
+********************************************************************
+*/
+
+func TestAnswersPaginationEnvelope(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	cursor := model.Cursor{Order: model.OrderByID, ID: 3}
+	next := model.Cursor{Order: model.OrderByID, ID: 5}
+	prev := model.Cursor{Order: model.OrderByID, ID: 4, Before: true}
+	total := 9
+	req.SetRequestURI(HOST + "/answers/author1?limit=2&total=true&cursor=" + cursor.String())
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswersByAuthorID", 1, model.AnswersQuery{Order: model.OrderByID, Page: model.Page{Limit: 2, Cursor: &cursor, WithTotal: true}}).
+		Return(model.AnswersPage{Answers: []model.Answer{createdAnswer}, Next: &next, Prev: &prev, Total: &total}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		assert.Equal(t, next.String(), response.NextCursor)
+		assert.Equal(t, prev.String(), response.PrevCursor)
+		if assert.NotNil(t, response.Total) {
+			assert.Equal(t, 9, *response.Total)
+		}
+		assert.Equal(t, 1, len(response.Data.([]interface{})))
+
+		link := string(res.Header.Peek("Link"))
+		assert.Contains(t, link, "/answers/author1?limit=2&total=true&cursor="+next.String()+`>; rel="next"`)
+		assert.Contains(t, link, "cursor="+prev.String()+`>; rel="prev"`)
+	}
+}
+
+func TestAnswersLastPageHasNoLink(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answers/question1")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByID}).Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, "", string(res.Header.Peek("Link")))
+		assert.NotContains(t, string(res.Body()), "next_cursor")
+		assert.NotContains(t, string(res.Body()), "total")
+	}
+}
+
+func TestAnswersBrokenLimit(t *testing.T) {
+	client, req, res, _ := initServer()
+
+	req.SetRequestURI(HOST + "/answers/question1?limit=ten")
+	req.Header.SetMethod("GET")
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 400, res.Header.StatusCode())
+	}
+}
+
+func TestAnswersQuestionFilters(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answers/question1?sort=best&is_best=true&author=2&created_from=2018-10-01T00:00:00Z")
+	req.Header.SetMethod("GET")
+
+	isBest, author := true, 2
+	from := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	cMock.On("GetAnswersByQuestionID", 1, model.AnswersQuery{Order: model.OrderByBest, IsBest: &isBest, AuthorID: &author, CreatedFrom: &from}).
+		Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestAnswersAuthorIgnoresAuthorFilter(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answers/author1?author=2&sort=oldest")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswersByAuthorID", 1, model.AnswersQuery{Order: model.OrderByOldest}).Return(model.AnswersPage{Answers: make([]model.Answer, 0)}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR SEARCH ***************************************************
+********************************************************************
+*/
+
+func TestSearchRouteCorrectData(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answers/search?q=content&author=1")
+	req.Header.SetMethod("GET")
+
+	author := 1
+	cMock.On("SearchAnswers", model.SearchQuery{Text: "content", AuthorID: &author}).
+		Return([]model.SearchResult{{Answer: createdAnswer, Rank: 0.1, Snippet: "My Answer <b>Content</b>"}}, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		responseData := response.Data.([]interface{})
+		if assert.Equal(t, 1, len(responseData)) {
+			found := responseData[0].(map[string]interface{})
+			assert.Equal(t, createdAnswer.ID, int(found["id"].(float64)))
+			assert.Equal(t, "My Answer <b>Content</b>", found["snippet"])
+		}
+	}
+}
+
+func TestSearchRouteMissedText(t *testing.T) {
+	client, req, res, _ := initServer()
+
+	req.SetRequestURI(HOST + "/answers/search")
+	req.Header.SetMethod("GET")
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 400, res.Header.StatusCode())
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR AUTHENTICATION *******************************************
+********************************************************************
+*/
+
+func withVerifier(t *testing.T) func() {
+	var err error
+	verifier, err = auth.NewVerifier(config.AuthConfig{HS256Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() { verifier = nil }
+}
+
+func withOpenAdmin() func() {
+	openAdmin = true
+	return func() { openAdmin = false }
+}
+
+func bearer(sub string, roles ...string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":      sub,
+		"nickname": "Token",
+		"roles":    roles,
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	s, _ := token.SignedString([]byte("secret"))
+	return "Bearer " + s
+}
+
+func TestAuthMissedToken(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/delete")
+	req.Header.SetMethod("DELETE")
+	req.SetBody([]byte("{\"id\": 1}"))
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 401, res.Header.StatusCode())
+		assert.Equal(t, "Bearer", string(res.Header.Peek("WWW-Authenticate")))
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		assert.Equal(t, ui.ErrUnauthorized.Error(), response.Error)
+	}
+}
+
+func TestAuthBrokenToken(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, _ := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("Authorization", "Bearer broken")
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 401, res.Header.StatusCode())
+	}
+}
+
+func TestAuthAnonymousRead(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestAuthAnswerAuthorFromToken(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+
+	answerJSON, _ := json.Marshal(&defaultAnswer)
+	req.SetRequestURI(HOST + "/answer")
+	req.Header.SetMethod("PUT")
+	req.Header.Set("Authorization", bearer("5"))
+	req.SetBody(answerJSON)
+
+	fromToken := defaultAnswer
+	fromToken.AuthorID = 5
+	fromToken.AuthorNickname = "Token"
+	cMock.On("AddAnswer", fromToken).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 201, res.Header.StatusCode())
+	}
+}
+
+func TestAuthRemoveOtherAuthor(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/delete")
+	req.Header.SetMethod("DELETE")
+	req.Header.Set("Authorization", bearer("5"))
+	req.SetBody([]byte("{\"id\": 1}"))
+
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 403, res.Header.StatusCode())
+	}
+}
+
+func TestAuthRemoveManyByService(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/delete")
+	req.Header.SetMethod("DELETE")
+	req.Header.Set("Authorization", bearer("question", auth.RoleService))
+	req.SetBody([]byte("{\"question_id\": 1}"))
+
+	cMock.On("DeleteAnswerByQuestionID", model.Answer{QuestionID: 1}).Return(1, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestAuthMarkBestByQuestionOwner(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":200,"error":"","data":{"id":` + strconv.Itoa(createdAnswer.QuestionID) + `,"author_id":3}}`))
+	}))
+	defer stub.Close()
+	cfg := config.Default().Question
+	cfg.URL = stub.URL
+	controller.InitQuestions(cfg)
+	defer controller.InitQuestions(config.QuestionConfig{})
+
+	source, _ := json.Marshal(updatedAnswer)
+	cMock.On("GetAnswerByID", updatedAnswer.ID, false).Return(createdAnswer, nil)
+	cMock.On("UpdateAnswer", updatedAnswer).Return(model.BestAnswer{Answer: updatedAnswer}, nil).Once()
+
+	for sub, expected := range map[string]int{"5": 403, "3": 200} {
+		req.SetRequestURI(HOST + "/best")
+		req.Header.SetMethod("PATCH")
+		req.Header.Set("Authorization", bearer(sub))
+		req.SetBody(source)
+
+		if assert.Nil(t, client.Do(req, res)) {
+			assert.Equal(t, expected, res.Header.StatusCode(), "user "+sub)
+		}
+	}
+	cMock.AssertExpectations(t)
+}
+
+func TestAuthVoterFromToken(t *testing.T) {
+	defer withVerifier(t)()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/vote")
+	req.Header.SetMethod("PATCH")
+	req.Header.Set("Authorization", bearer("5"))
+	req.SetBody([]byte("{\"answer_id\": 1, \"voter_id\": 2, \"value\": 1}"))
+
+	cMock.On("VoteAnswer", model.Vote{AnswerID: 1, VoterID: 5, Value: 1}).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestAdminClosedWithoutAuth(t *testing.T) {
+	client, req, res, _ := initServer()
+	controller.Webhooks = &webhook.MemoryStore{}
+
+	for _, route := range [][2]string{{"PUT", "/admin/webhooks"}, {"GET", "/admin/webhooks/dead-letters"}, {"PUT", "/admin/drain"}, {"GET", "/admin/log-level"}} {
+		req.SetRequestURI(HOST + route[1])
+		req.Header.SetMethod(route[0])
+		req.SetBody([]byte(`{"url":"http://questions.local/hooks"}`))
+
+		if assert.Nil(t, client.Do(req, res)) {
+			assert.Equal(t, 403, res.StatusCode(), route[1])
+		}
+	}
+	list, _ := controller.Webhooks.GetWebhooks()
+	assert.Equal(t, 0, len(list))
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	controller.AnswerModel.(*MockedAService).On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 200, res.StatusCode(), "other routes stay open")
+	}
+}
+
+func withAPIKey(cMock *MockedAService, key string, scopes ...string) {
+	apiKeys = true
+	cMock.On("GetAPIKeyByHash", auth.HashAPIKey(key)).Return(model.APIKey{ID: 3, Name: "gateway", Scopes: scopes}, nil)
+}
+
+func TestAPIKeyScope(t *testing.T) {
+	defer func() { apiKeys = false }()
+	client, req, res, cMock := initServer()
+	withAPIKey(cMock, "ak_key", auth.ScopeAnswersRead)
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-API-Key", "ak_key")
+
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestAPIKeyMissedScope(t *testing.T) {
+	defer func() { apiKeys = false }()
+	client, req, res, cMock := initServer()
+	withAPIKey(cMock, "ak_key", auth.ScopeAnswersRead)
+
+	req.SetRequestURI(HOST + "/delete")
+	req.Header.SetMethod("DELETE")
+	req.Header.Set("X-API-Key", "ak_key")
+	req.SetBody([]byte("{\"question_id\": 1}"))
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 403, res.Header.StatusCode())
+	}
+}
+
+func TestAPIKeyUnknown(t *testing.T) {
+	defer func() { apiKeys = false }()
+	client, req, res, cMock := initServer()
+	apiKeys = true
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-API-Key", "ak_other")
+
+	cMock.On("GetAPIKeyByHash", auth.HashAPIKey("ak_other")).Return(model.APIKey{}, ui.ErrNoResult)
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 401, res.Header.StatusCode())
+	}
+}
+
+func TestAPIKeysStatsNeedScope(t *testing.T) {
+	defer func() { apiKeys = false }()
+	client, req, res, _ := initServer()
+	apiKeys = true
+
+	req.SetRequestURI(HOST + "/")
+	req.Header.SetMethod("GET")
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 401, res.Header.StatusCode())
+	}
+}
+
+func TestRunKeys(t *testing.T) {
+	controller.InitMemory()
+
+	var out bytes.Buffer
+	err := runKeys([]string{"create", "gateway", auth.ScopeAnswersRead, auth.ScopeAnswersWrite}, &out)
+	if assert.Nil(t, err) {
+		lines := strings.Split(out.String(), "\n")
+		assert.Equal(t, "1 gateway answers:read,answers:write", lines[0])
+		who, err := controller.CheckAPIKey(context.Background(), lines[1])
+		if assert.Nil(t, err) {
+			assert.True(t, who.HasScope(auth.ScopeAnswersWrite))
+		}
+	}
+
+	out.Reset()
+	assert.Nil(t, runKeys([]string{"revoke", "1"}, &out))
+	assert.NotNil(t, runKeys([]string{"revoke", "1"}, &out))
+
+	out.Reset()
+	if assert.Nil(t, runKeys([]string{"list"}, &out)) {
+		assert.Contains(t, out.String(), "gateway")
+		assert.Contains(t, out.String(), "revoked")
+	}
+
+	assert.NotNil(t, runKeys([]string{"create", "gateway", "answers:everything"}, &out))
+	assert.NotNil(t, runKeys([]string{"create", "gateway"}, &out))
+	assert.NotNil(t, runKeys(nil, &out))
+}
+
+/*
+********************************************************************
+TESTS FOR RATE LIMIT ***********************************************
+********************************************************************
+*/
+
+func TestRateLimit(t *testing.T) {
+	limiter = ratelimit.New(&ratelimit.MemoryStore{}, map[string]ratelimit.Limit{
+		ratelimit.GroupRead: {Burst: 1, Per: time.Minute},
+	})
+	defer func() { limiter = nil }()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil).Once()
+
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.Header.StatusCode())
+		assert.Equal(t, "1", string(res.Header.Peek("RateLimit-Limit")))
+		assert.Equal(t, "0", string(res.Header.Peek("RateLimit-Remaining")))
+		assert.Equal(t, "60", string(res.Header.Peek("RateLimit-Reset")))
+	}
+
+	err = client.Do(req, res)
+	if assert.Nil(t, err) {
+		cMock.AssertExpectations(t)
+		assert.Equal(t, 429, res.Header.StatusCode())
+		assert.Equal(t, "60", string(res.Header.Peek("Retry-After")))
+
+		var response ui.Response
+		json.Unmarshal(res.Body(), &response)
+		assert.Equal(t, ui.ErrTooManyRequests.Error(), response.Error)
+	}
+}
+
+func TestRateLimitOtherGroup(t *testing.T) {
+	limiter = ratelimit.New(&ratelimit.MemoryStore{}, map[string]ratelimit.Limit{
+		ratelimit.GroupWrite: {Burst: 1, Per: time.Minute},
+	})
+	defer func() { limiter = nil }()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+
+	for i := 0; i < 3; i++ {
+		err := client.Do(req, res)
+		if assert.Nil(t, err) {
+			assert.Equal(t, 200, res.Header.StatusCode())
+			assert.Equal(t, "", string(res.Header.Peek("RateLimit-Limit")))
+		}
+	}
+}
+
+func TestRateLimitRejectedCredentials(t *testing.T) {
+	defer withVerifier(t)()
+	limiter = ratelimit.New(&ratelimit.MemoryStore{}, map[string]ratelimit.Limit{
+		ratelimit.GroupWrite: {Burst: 2, Per: time.Minute},
+	})
+	defer func() { limiter = nil }()
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/delete")
+	req.Header.SetMethod("DELETE")
+	req.Header.Set("Authorization", "Bearer broken")
+	req.SetBody([]byte("{\"id\": 1}"))
+
+	for _, expected := range []int{401, 401, 429} {
+		err := client.Do(req, res)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expected, res.Header.StatusCode())
+		}
+	}
+	assert.Equal(t, "30", string(res.Header.Peek("Retry-After")))
+	cMock.AssertNotCalled(t, "DeleteAnswerByID", mock.Anything)
+}
+
+func TestRateLimitKeyLookups(t *testing.T) {
+	limiter = ratelimit.New(&ratelimit.MemoryStore{}, map[string]ratelimit.Limit{
+		ratelimit.GroupKeys: {Burst: 2, Per: time.Minute},
+	})
+	defer func() { limiter = nil }()
+	client, req, res, cMock := initServer()
+	apiKeys = true
+	defer func() { apiKeys = false }()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-API-Key", "guessed")
+
+	cMock.On("GetAPIKeyByHash", auth.HashAPIKey("guessed")).Return(model.APIKey{}, ui.ErrNoResult).Times(2)
+
+	for _, expected := range []int{401, 401, 429} {
+		err := client.Do(req, res)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expected, res.Header.StatusCode())
+		}
+	}
+	assert.Equal(t, "30", string(res.Header.Peek("Retry-After")))
+	cMock.AssertExpectations(t)
+}
+
+/*
+********************************************************************
+TESTS FOR METRICS **************************************************
+********************************************************************
+*/
+
+func TestMetricsRoute(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	if err := client.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetRequestURI(HOST + "/metrics")
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.Header.StatusCode())
+		assert.Contains(t, string(res.Body()), `answer_http_requests_total{code="200",method="GET",route="/answer/id:id"}`)
+		assert.Contains(t, string(res.Body()), "answer_http_requests_in_flight")
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR LOGGING **************************************************
+********************************************************************
+*/
+
+func TestLogLevelRoute(t *testing.T) {
+	defer withOpenAdmin()()
+	client, req, res, _ := initServer()
+	defer utils.SetLevel(utils.GetLevel())
+
+	req.SetRequestURI(HOST + "/admin/log-level")
+	req.Header.SetMethod("PUT")
+	req.SetBody([]byte(`{"level":"debug"}`))
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.Header.StatusCode())
+		assert.Equal(t, utils.LevelDebug, utils.GetLevel())
+	}
+
+	req.Reset()
+	req.SetRequestURI(HOST + "/admin/log-level")
+	req.Header.SetMethod("GET")
+	err = client.Do(req, res)
+	if assert.Nil(t, err) {
+		var r struct{ Data controller.LogLevel }
+		json.Unmarshal(res.Body(), &r)
+		assert.Equal(t, "debug", r.Data.Level)
+	}
+}
+
+func TestLogLevelRouteUnknownLevel(t *testing.T) {
+	defer withOpenAdmin()()
+	client, req, res, _ := initServer()
+	defer utils.SetLevel(utils.GetLevel())
+
+	req.SetRequestURI(HOST + "/admin/log-level")
+	req.Header.SetMethod("PUT")
+	req.SetBody([]byte(`{"level":"verbose"}`))
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 400, res.Header.StatusCode())
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	client, req, res, cMock := initServer()
+	var out bytes.Buffer
+	utils.SetOutput(&out, "json")
+	defer utils.SetOutput(os.Stderr, "logfmt")
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-Request-ID", "req-1")
+	cMock.On("GetAnswerByID", 1, false).Return(model.Answer{}, ui.ErrNoResult)
+	if err := client.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		json.Unmarshal([]byte(l), &line)
+		if line["msg"] == "Request served" {
+			break
+		}
+	}
+	assert.Equal(t, "Request served", line["msg"])
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "/answer/id:id", line["route"])
+	assert.Equal(t, float64(404), line["status"])
+	assert.Contains(t, line["client"], "ip:")
+	assert.NotEmpty(t, line["latency"])
+}
+
+/*
+********************************************************************
+TESTS FOR REQUEST ID ***********************************************
+********************************************************************
+*/
+
+func TestRequestIDEchoed(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-Request-ID", "gateway-42")
+	cMock.On("GetAnswerByID", 1, false).Return(model.Answer{}, ui.ErrNoResult)
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gateway-42", string(res.Header.Peek("X-Request-ID")))
+
+		var r ui.Response
+		json.Unmarshal(res.Body(), &r)
+		assert.Equal(t, "gateway-42", r.RequestID)
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	client, req, res, cMock := initServer()
+
+	req.SetRequestURI(HOST + "/answer/id1")
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-Request-ID", strings.Repeat("x", 200))
+	cMock.On("GetAnswerByID", 1, false).Return(createdAnswer, nil)
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		id := string(res.Header.Peek("X-Request-ID"))
+		assert.Equal(t, 32, len(id), "too long id is replaced")
+
+		var r ui.Response
+		json.Unmarshal(res.Body(), &r)
+		assert.Equal(t, id, r.RequestID)
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR USAGE STATS **********************************************
+********************************************************************
+*/
+
+func TestStatsByRoute(t *testing.T) {
+	client, req, res, _ := initServer()
+	controller.AnswerModel = &model.AMemoryService{}
+
+	for _, uri := range []string{"/answer/id1", "/answer/id2"} {
+		req.SetRequestURI(HOST + uri)
+		req.Header.SetMethod("GET")
+		assert.Nil(t, client.Do(req, res))
+	}
+
+	stat, _ := controller.AnswerModel.GetUsageStatistic("localhost")
+	assert.Equal(t, 2, stat.RequestsCount)
+	assert.Equal(t, "/answer/id2", stat.LastUsage.Request)
+	assert.Equal(t, "GET", stat.LastUsage.Method)
+	assert.Equal(t, "/answer/id:id", stat.LastUsage.Route)
+	assert.True(t, strings.HasPrefix(stat.LastUsage.Client, "ip:"))
+
+	req.SetRequestURI(HOST + "/stats?window=24h")
+	req.Header.SetMethod("GET")
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		var r struct {
+			Status int               `json:"status"`
+			Data   model.WindowStats `json:"data"`
+		}
+		json.Unmarshal(res.Body(), &r)
+		assert.Equal(t, 200, r.Status)
+		assert.Equal(t, "24h", r.Data.Window)
+		if assert.Equal(t, 1, len(r.Data.Routes)) {
+			assert.Equal(t, "/answer/id:id", r.Data.Routes[0].Route)
+			assert.Equal(t, 2, r.Data.Routes[0].Requests)
+			assert.Equal(t, 2, r.Data.Routes[0].ClientErrors)
+		}
+	}
+
+	stat, _ = controller.AnswerModel.GetUsageStatistic("localhost")
+	assert.Equal(t, 2, stat.RequestsCount, "reading stats is not counted")
+}
+
+func TestStatsUnknownWindow(t *testing.T) {
+	client, req, res, _ := initServer()
+
+	req.SetRequestURI(HOST + "/stats?window=2d")
+	req.Header.SetMethod("GET")
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		status, _ := ui.ErrToResponse(ui.ErrInvalidParameter)
+		assert.Equal(t, status, res.StatusCode())
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR QUESTION CHECK *******************************************
+********************************************************************
+*/
+
+func TestAnswerQuestionCheck(t *testing.T) {
+	client, req, res, cMock := initServer()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/question/id" + strconv.Itoa(defaultAnswer.QuestionID):
+			w.Write([]byte(`{"status":200,"error":"","data":{"id":` + strconv.Itoa(defaultAnswer.QuestionID) + `,"locked":true}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer stub.Close()
+	cfg := config.Default().Question
+	cfg.URL = stub.URL
+	controller.InitQuestions(cfg)
+	defer controller.InitQuestions(config.QuestionConfig{})
+
+	a := defaultAnswer
+	for questionID, expected := range map[int]error{defaultAnswer.QuestionID: ui.ErrQuestionLocked, 404: ui.ErrQuestionNotFound} {
+		a.QuestionID = questionID
+		body, _ := json.Marshal(&a)
+		req.SetRequestURI(HOST + "/answer")
+		req.Header.SetMethod("PUT")
+		req.SetBody(body)
+
+		if assert.Nil(t, client.Do(req, res)) {
+			status, text := ui.ErrToResponse(expected)
+			assert.Equal(t, status, res.StatusCode())
+			var response ui.Response
+			json.Unmarshal(res.Body(), &response)
+			assert.Equal(t, text, response.Error)
+		}
+	}
+	cMock.AssertNotCalled(t, "AddAnswer", mock.Anything)
+}
+
+/*
+********************************************************************
+TESTS FOR WEBHOOKS *************************************************
+********************************************************************
+*/
+
+func TestWebhookReceivesSignedEvents(t *testing.T) {
+	defer withOpenAdmin()()
+	webhook.AllowPrivateHosts = true
+	defer func() { webhook.AllowPrivateHosts = false }()
+	client, req, res, _ := initServer()
+	controller.AnswerModel = &model.AMemoryService{}
+	controller.Webhooks = &webhook.MemoryStore{}
+
+	received := make(chan model.Event, 1)
+	var secret string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Answer-Timestamp"), 10, 64)
+		if !webhook.Verify(secret, timestamp, body, r.Header.Get("X-Answer-Signature")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var e model.Event
+		json.Unmarshal(body, &e)
+		received <- e
+	}))
+	defer receiver.Close()
+
+	req.SetRequestURI(HOST + "/admin/webhooks")
+	req.Header.SetMethod("PUT")
+	req.SetBody([]byte(`{"url":"` + receiver.URL + `","events":["answer.created"]}`))
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 201, res.StatusCode())
+		var r struct {
+			Data webhook.Webhook `json:"data"`
+		}
+		json.Unmarshal(res.Body(), &r)
+		secret = r.Data.Secret
+	}
+
+	a, _ := json.Marshal(&defaultAnswer)
+	req.SetRequestURI(HOST + "/answer")
+	req.Header.SetMethod("PUT")
+	req.SetBody(a)
+	assert.Nil(t, client.Do(req, res))
+	assert.Equal(t, 201, res.StatusCode())
+
+	cfg := config.Default()
+	cfg.Outbox.Publisher = "webhook"
+	_, err := outbox.NewRelay(controller.AnswerModel, publisherOf(cfg.Outbox), cfg.Outbox).Once(context.Background())
+	assert.Nil(t, err)
+	n, err := webhook.NewDispatcher(controller.Webhooks, cfg.Webhook).Once()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	select {
+	case e := <-received:
+		assert.Equal(t, model.EventAnswerCreated, e.Type)
+		assert.Equal(t, defaultAnswer.QuestionID, e.QuestionID)
+	default:
+		t.Fatal("event is not received")
+	}
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	defer withOpenAdmin()()
+	client, req, res, _ := initServer()
+	controller.AnswerModel = &model.AMemoryService{}
+	controller.Webhooks = &webhook.MemoryStore{}
+
+	req.SetRequestURI(HOST + "/admin/webhooks")
+	req.Header.SetMethod("PUT")
+	req.SetBody([]byte(`{"url":"not a url"}`))
+	if assert.Nil(t, client.Do(req, res)) {
+		status, _ := ui.ErrToResponse(ui.ErrInvalidParameter)
+		assert.Equal(t, status, res.StatusCode())
+	}
+
+	for _, url := range []string{"http://localhost:8080/hooks", "http://127.0.0.1/hooks", "http://[::1]/hooks", "http://169.254.169.254/latest/meta-data"} {
+		req.SetBody([]byte(`{"url":"` + url + `"}`))
+		if assert.Nil(t, client.Do(req, res)) {
+			assert.Equal(t, 400, res.StatusCode(), url)
+		}
+	}
+
+	req.SetBody([]byte(`{"url":"http://questions.local/hooks"}`))
+	assert.Nil(t, client.Do(req, res))
+
+	req.Header.SetMethod("GET")
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Contains(t, string(res.Body()), "http://questions.local/hooks")
+		assert.NotContains(t, string(res.Body()), "secret")
+	}
+
+	req.SetRequestURI(HOST + "/admin/webhooks/dead-letters")
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 200, res.StatusCode())
+	}
+
+	req.SetRequestURI(HOST + "/admin/webhooks/redeliver")
+	req.Header.SetMethod("PATCH")
+	req.SetBody([]byte(`{"id":1}`))
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 404, res.StatusCode())
+	}
+
+	req.SetRequestURI(HOST + "/admin/webhooks")
+	req.Header.SetMethod("DELETE")
+	req.SetBody([]byte(`{"id":1}`))
+	if assert.Nil(t, client.Do(req, res)) {
+		assert.Equal(t, 200, res.StatusCode())
+	}
+}
+
+/*
+********************************************************************
+TESTS FOR HEALTH ***************************************************
+********************************************************************
+*/
+
+func TestHealthz(t *testing.T) {
+	client, req, res, _ := initServer()
+
+	req.SetRequestURI(HOST + "/healthz")
+	req.Header.SetMethod("GET")
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+}
+
+func TestReadyzDraining(t *testing.T) {
+	defer withOpenAdmin()()
+	client, req, res, _ := initServer()
+	defer health.SetDraining(false)
+
+	req.SetRequestURI(HOST + "/readyz")
+	req.Header.SetMethod("GET")
+	err := client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.Header.StatusCode())
+	}
+
+	req.SetRequestURI(HOST + "/admin/drain")
+	req.Header.SetMethod("PUT")
+	if err = client.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetRequestURI(HOST + "/readyz")
+	req.Header.SetMethod("GET")
+	err = client.Do(req, res)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 503, res.Header.StatusCode())
+
+		var r struct{ Data health.Report }
+		json.Unmarshal(res.Body(), &r)
+		assert.False(t, r.Data.Ready)
+		assert.Equal(t, health.ErrDraining.Error(), r.Data.Checks["draining"].Error)
+	}
+
+	req.SetRequestURI(HOST + "/admin/drain")
+	req.Header.SetMethod("DELETE")
+	if err = client.Do(req, res); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, health.Draining())
+}
+
+/*
+********************************************************************
+TESTS FOR SHUTDOWN *************************************************
+********************************************************************
+*/
+
+func TestServeWaitsForRequests(t *testing.T) {
+	defer health.SetDraining(false)
+	listener := fasthttputil.NewInmemoryListener()
+	started := make(chan struct{})
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		ctx.SetStatusCode(200)
+	}}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(server, listener, stop, config.ShutdownConfig{Timeout: time.Second}, nil)
+	}()
+
+	client := &fasthttp.Client{Dial: func(addr string) (net.Conn, error) { return listener.Dial() }}
+	status := make(chan int, 1)
+	go func() {
+		code, _, _ := client.Get(nil, HOST+"/")
+		status <- code
+	}()
+	<-started
+	stop <- os.Interrupt
+
+	assert.Equal(t, 200, <-status, "request in flight is finished")
+	assert.Nil(t, <-served)
+	assert.True(t, health.Draining())
+}
+
+func TestServeFlushesWithinTimeout(t *testing.T) {
+	defer health.SetDraining(false)
+	listener := fasthttputil.NewInmemoryListener()
+	started := make(chan struct{})
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		ctx.SetStatusCode(200)
+	}}
+	var left time.Duration
+	flush := func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		left = time.Until(deadline)
+		return nil
+	}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(server, listener, stop, config.ShutdownConfig{Timeout: time.Second}, flush)
+	}()
+
+	client := &fasthttp.Client{Dial: func(addr string) (net.Conn, error) { return listener.Dial() }}
+	go client.Get(nil, HOST+"/")
+	<-started
+	stop <- os.Interrupt
+
+	assert.Nil(t, <-served)
+	assert.True(t, left > 0 && left < 950*time.Millisecond, "flush gets time left after requests are served")
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	defer health.SetDraining(false)
+	listener := fasthttputil.NewInmemoryListener()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		<-release
+	}}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(server, listener, stop, config.ShutdownConfig{Timeout: 50 * time.Millisecond}, nil)
+	}()
+
+	client := &fasthttp.Client{Dial: func(addr string) (net.Conn, error) { return listener.Dial() }}
+	go client.Get(nil, HOST+"/")
+	<-started
+	stop <- os.Interrupt
+
+	assert.NotNil(t, <-served)
+}
+
+// brokenListener fails to accept connections
+type brokenListener struct {
+	net.Listener
+}
+
+func (brokenListener) Accept() (net.Conn, error) {
+	return nil, errors.New("too many open files")
+}
+
+func TestServeListenFailure(t *testing.T) {
+	listener := brokenListener{fasthttputil.NewInmemoryListener()}
+	defer listener.Close()
+
+	err := serve(&fasthttp.Server{Logger: nopLogger{}}, listener, make(chan os.Signal), config.ShutdownConfig{Timeout: time.Second}, nil)
+	assert.NotNil(t, err)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/stats"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware("/answer/id:id", func(ctx *fasthttp.RequestCtx) {
+		assert.Equal(t, 1.0, testutil.ToFloat64(inFlight))
+		ctx.SetStatusCode(404)
+	})
+
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod("GET")
+	before := testutil.ToFloat64(requests.WithLabelValues("/answer/id:id", "GET", "404"))
+	h(&ctx)
+
+	assert.Equal(t, before+1, testutil.ToFloat64(requests.WithLabelValues("/answer/id:id", "GET", "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(inFlight))
+	assert.Equal(t, 1, testutil.CollectAndCount(requestDuration, namespace+"_http_request_duration_seconds"))
+}
+
+func TestInstrument(t *testing.T) {
+	s := Instrument(&model.AMemoryService{})
+	created, deleted, best := testutil.ToFloat64(answersCreated), testutil.ToFloat64(answersDeleted), testutil.ToFloat64(answersBest)
+
+	content := "content"
+	isBest := true
+	for i := 0; i < 3; i++ {
+		if _, err := s.AddAnswer(model.Answer{QuestionID: 1, AuthorID: 1, AuthorNickname: "Test", Content: &content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.UpdateAnswer(model.Answer{ID: 1, IsBest: &isBest})
+	s.DeleteAnswerByID(model.Answer{ID: 1})
+	s.DeleteAnswerByID(model.Answer{ID: 1})
+	s.DeleteAnswerByQuestionID(model.Answer{QuestionID: 1})
+
+	assert.Equal(t, created+3, testutil.ToFloat64(answersCreated))
+	assert.Equal(t, best+1, testutil.ToFloat64(answersBest))
+	assert.Equal(t, deleted+3, testutil.ToFloat64(answersDeleted), "failed delete is not counted")
+
+	assert.True(t, testutil.CollectAndCount(queryDuration) >= 3, "latency by method")
+}
+
+func TestRegisterStats(t *testing.T) {
+	prev := Registry
+	Registry = prometheus.NewRegistry()
+	defer func() { Registry = prev }()
+
+	w := stats.New(func([]model.RequestInfo) error { return nil }, config.StatsConfig{
+		QueueSize: 10, BatchSize: 5, FlushInterval: time.Hour, Overflow: "drop",
+	})
+	w.Close(context.Background())
+	w.Add(model.RequestInfo{Request: "/answer"})
+
+	if assert.Nil(t, RegisterStats(w)) {
+		expected := `
+# HELP answer_stats_dropped_total Usage stats which were not stored by reason: overflow of queue or failed write.
+# TYPE answer_stats_dropped_total counter
+answer_stats_dropped_total{reason="failed"} 0
+answer_stats_dropped_total{reason="overflow"} 1
+# HELP answer_stats_queue_capacity Usage stats queue holds.
+# TYPE answer_stats_queue_capacity gauge
+answer_stats_queue_capacity 10
+`
+		assert.Nil(t, testutil.GatherAndCompare(Registry, strings.NewReader(expected),
+			"answer_stats_dropped_total", "answer_stats_queue_capacity"))
+	}
+}
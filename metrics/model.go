@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/RSOI/answer/model"
+)
+
+// service measures every call of wrapped model and counts answers created, marked as best and deleted
+type service struct {
+	next model.AServiceInterface
+}
+
+// Instrument returns model measuring latency of s methods
+func Instrument(s model.AServiceInterface) model.AServiceInterface {
+	return &service{next: s}
+}
+
+// observe records latency of method started at start, use as defer observe(...)
+func observe(method string, start time.Time) {
+	queryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (s *service) AddAnswer(a model.Answer) (model.Answer, error) {
+	defer observe("AddAnswer", time.Now())
+	a, err := s.next.AddAnswer(a)
+	if err == nil {
+		answersCreated.Inc()
+	}
+	return a, err
+}
+
+func (s *service) DeleteAnswerByID(a model.Answer) error {
+	defer observe("DeleteAnswerByID", time.Now())
+	err := s.next.DeleteAnswerByID(a)
+	if err == nil {
+		answersDeleted.Inc()
+	}
+	return err
+}
+
+func (s *service) DeleteAnswerByAuthorID(a model.Answer) (int, error) {
+	defer observe("DeleteAnswerByAuthorID", time.Now())
+	n, err := s.next.DeleteAnswerByAuthorID(a)
+	answersDeleted.Add(float64(n))
+	return n, err
+}
+
+func (s *service) DeleteAnswerByQuestionID(a model.Answer) (int, error) {
+	defer observe("DeleteAnswerByQuestionID", time.Now())
+	n, err := s.next.DeleteAnswerByQuestionID(a)
+	answersDeleted.Add(float64(n))
+	return n, err
+}
+
+func (s *service) RestoreAnswer(a model.Answer) (model.Answer, error) {
+	defer observe("RestoreAnswer", time.Now())
+	return s.next.RestoreAnswer(a)
+}
+
+func (s *service) PurgeDeleted(before time.Time) (int, error) {
+	defer observe("PurgeDeleted", time.Now())
+	return s.next.PurgeDeleted(before)
+}
+
+func (s *service) GetAnswerByID(aID int, includeDeleted bool) (model.Answer, error) {
+	defer observe("GetAnswerByID", time.Now())
+	return s.next.GetAnswerByID(aID, includeDeleted)
+}
+
+func (s *service) GetAnswersByAuthorID(aAuthorID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	defer observe("GetAnswersByAuthorID", time.Now())
+	return s.next.GetAnswersByAuthorID(aAuthorID, q)
+}
+
+func (s *service) GetAnswersByQuestionID(aQuestionID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	defer observe("GetAnswersByQuestionID", time.Now())
+	return s.next.GetAnswersByQuestionID(aQuestionID, q)
+}
+
+func (s *service) SearchAnswers(q model.SearchQuery) ([]model.SearchResult, error) {
+	defer observe("SearchAnswers", time.Now())
+	return s.next.SearchAnswers(q)
+}
+
+func (s *service) UpdateAnswer(a model.Answer) (model.BestAnswer, error) {
+	defer observe("UpdateAnswer", time.Now())
+	best, err := s.next.UpdateAnswer(a)
+	if err == nil {
+		answersBest.Inc()
+	}
+	return best, err
+}
+
+func (s *service) UnmarkBestAnswer(a model.Answer) (model.Answer, error) {
+	defer observe("UnmarkBestAnswer", time.Now())
+	return s.next.UnmarkBestAnswer(a)
+}
+
+func (s *service) EditAnswer(r model.Revision) (model.Answer, error) {
+	defer observe("EditAnswer", time.Now())
+	return s.next.EditAnswer(r)
+}
+
+func (s *service) RollbackAnswer(r model.Revision) (model.Answer, error) {
+	defer observe("RollbackAnswer", time.Now())
+	return s.next.RollbackAnswer(r)
+}
+
+func (s *service) GetRevisions(aID int) ([]model.Revision, error) {
+	defer observe("GetRevisions", time.Now())
+	return s.next.GetRevisions(aID)
+}
+
+func (s *service) GetRevision(aID int, revision int) (model.Revision, error) {
+	defer observe("GetRevision", time.Now())
+	return s.next.GetRevision(aID, revision)
+}
+
+func (s *service) VoteAnswer(v model.Vote) (model.Answer, error) {
+	defer observe("VoteAnswer", time.Now())
+	return s.next.VoteAnswer(v)
+}
+
+func (s *service) AddAPIKey(k model.APIKey) (model.APIKey, error) {
+	defer observe("AddAPIKey", time.Now())
+	return s.next.AddAPIKey(k)
+}
+
+func (s *service) GetAPIKeys() ([]model.APIKey, error) {
+	defer observe("GetAPIKeys", time.Now())
+	return s.next.GetAPIKeys()
+}
+
+func (s *service) GetAPIKeyByHash(hash string) (model.APIKey, error) {
+	defer observe("GetAPIKeyByHash", time.Now())
+	return s.next.GetAPIKeyByHash(hash)
+}
+
+func (s *service) RevokeAPIKey(id int) error {
+	defer observe("RevokeAPIKey", time.Now())
+	return s.next.RevokeAPIKey(id)
+}
+
+func (s *service) GetUsageStatistic(host string) (model.ServiceStatus, error) {
+	defer observe("GetUsageStatistic", time.Now())
+	return s.next.GetUsageStatistic(host)
+}
+
+func (s *service) GetRouteStats(since time.Time) ([]model.RouteStat, error) {
+	defer observe("GetRouteStats", time.Now())
+	return s.next.GetRouteStats(since)
+}
+
+func (s *service) RollupStats(until time.Time) (int, error) {
+	defer observe("RollupStats", time.Now())
+	return s.next.RollupStats(until)
+}
+
+func (s *service) PurgeStats(before time.Time) (int, error) {
+	defer observe("PurgeStats", time.Now())
+	return s.next.PurgeStats(before)
+}
+
+func (s *service) ClaimEvents(limit int, lease time.Duration) ([]model.Event, error) {
+	defer observe("ClaimEvents", time.Now())
+	return s.next.ClaimEvents(limit, lease)
+}
+
+func (s *service) MarkEventsPublished(ids []string) error {
+	defer observe("MarkEventsPublished", time.Now())
+	return s.next.MarkEventsPublished(ids)
+}
+
+func (s *service) RetryEvent(id string, at time.Time, reason string) error {
+	defer observe("RetryEvent", time.Now())
+	return s.next.RetryEvent(id, at, reason)
+}
+
+func (s *service) PurgeEvents(before time.Time) (int, error) {
+	defer observe("PurgeEvents", time.Now())
+	return s.next.PurgeEvents(before)
+}
+
+func (s *service) LogStats(stats []model.RequestInfo) error {
+	defer observe("LogStats", time.Now())
+	return s.next.LogStats(stats)
+}
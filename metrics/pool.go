@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads pgx pool stats on every scrape
+type poolCollector struct {
+	pool     *pgx.ConnPool
+	acquired *prometheus.Desc
+	idle     *prometheus.Desc
+	total    *prometheus.Desc
+	max      *prometheus.Desc
+}
+
+// RegisterPool adds connection stats of pool to Registry
+func RegisterPool(pool *pgx.ConnPool) error {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return Registry.Register(&poolCollector{
+		pool:     pool,
+		acquired: desc("acquired_connections", "Connections in use."),
+		idle:     desc("idle_connections", "Connections ready to be acquired."),
+		total:    desc("total_connections", "Open connections."),
+		max:      desc("max_connections", "Connections pool may open."),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+}
+
+// Collect implements prometheus.Collector
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.CurrentConnections-stat.AvailableConnections))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.AvailableConnections))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.CurrentConnections))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConnections))
+}
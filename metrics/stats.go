@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/RSOI/answer/stats"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statsCollector reads usage stats queue of writer on every scrape
+type statsCollector struct {
+	writer   *stats.Writer
+	dropped  *prometheus.Desc
+	queued   *prometheus.Desc
+	capacity *prometheus.Desc
+}
+
+// RegisterStats adds queue length and dropped stats of w to Registry
+func RegisterStats(w *stats.Writer) error {
+	return Registry.Register(&statsCollector{
+		writer: w,
+		dropped: prometheus.NewDesc(prometheus.BuildFQName(namespace, "stats", "dropped_total"),
+			"Usage stats which were not stored by reason: overflow of queue or failed write.", []string{"reason"}, nil),
+		queued: prometheus.NewDesc(prometheus.BuildFQName(namespace, "stats", "queue_length"),
+			"Usage stats waiting to be written.", nil, nil),
+		capacity: prometheus.NewDesc(prometheus.BuildFQName(namespace, "stats", "queue_capacity"),
+			"Usage stats queue holds.", nil, nil),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.dropped
+	ch <- c.queued
+	ch <- c.capacity
+}
+
+// Collect implements prometheus.Collector
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	drops := c.writer.Dropped()
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(drops.Overflow), "overflow")
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(drops.Failed), "failed")
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(c.writer.Pending()))
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(c.writer.Capacity()))
+}
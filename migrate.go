@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/RSOI/answer/database"
+)
+
+const migrateUsage = "usage: answer migrate status|up|down|to <version>"
+
+// runMigrate handles "migrate" subcommand
+func runMigrate(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	version := 0
+	switch args[0] {
+	case "status", "up", "down":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		var err error
+		version, err = strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid migration version: %s", args[1])
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	db := database.Open()
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, database.MIGRATIONS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "to":
+		err = migrator.To(version)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		applied := "pending"
+		if s.Applied {
+			applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(out, "%04d %-32s %s\n", s.Version, s.Name, applied)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx"
 
@@ -9,141 +10,324 @@ import (
 	"github.com/RSOI/answer/utils"
 )
 
+// answerColumns columns order expected by scanAnswer
+const answerColumns = `id, question_id, content, author_id, author_nickname, is_best, created, updated, revision, score, deleted_at, deleted_by`
+
+// scanner is implemented by pgx.Row and pgx.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAnswer reads answerColumns followed by extra columns
+func scanAnswer(row scanner, extra ...interface{}) (Answer, error) {
+	var a Answer
+	dest := []interface{}{
+		&a.ID,
+		&a.QuestionID,
+		&a.Content,
+		&a.AuthorID,
+		&a.AuthorNickname,
+		&a.IsBest,
+		&a.Created,
+		&a.Updated,
+		&a.Revision,
+		&a.Score,
+		&a.DeletedAt,
+		&a.DeletedBy,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return a, err
+}
+
 // AddAnswer add new answer
 func (service *AService) AddAnswer(a Answer) (Answer, error) {
-	var err error
+	utils.Debug("Accessing database...")
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return a, err
+	}
+	defer tx.Rollback()
 
-	utils.LOG("Accessing database...")
-	row := service.Conn.QueryRow(`
+	row := tx.QueryRow(`
 		INSERT INTO answer.answer
 			(question_id, content, author_id, author_nickname) VALUES ($1, $2, $3, $4)
-			RETURNING id, created, is_best
-	`, a.QuestionID, a.Content, a.AuthorID, a.AuthorNickname)
+			RETURNING `+answerColumns,
+		a.QuestionID, a.Content, a.AuthorID, a.AuthorNickname)
+	created, err := scanAnswer(row)
+	if err != nil {
+		return a, err
+	}
 
-	err = row.Scan(&a.ID, &a.Created, &a.IsBest)
-	return a, err
+	err = addRevision(tx, Revision{
+		AnswerID: created.ID,
+		Revision: created.Revision,
+		Content:  created.Content,
+		EditorID: created.AuthorID,
+		Created:  created.Created,
+	})
+	if err == nil {
+		err = addEvent(tx, EventAnswerCreated, created, created)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	return created, err
+}
+
+// notDeleted condition hiding soft deleted answers unless includeDeleted is set
+func notDeleted(includeDeleted bool) string {
+	if includeDeleted {
+		return ``
+	}
+	return ` AND deleted_at IS NULL`
+}
+
+// deleteWhere moves answers having column equal to value to trash and writes event of every moved one,
+// column is never user input
+func (service *AService) deleteWhere(column string, value int, deletedBy *int) (int, error) {
+	utils.Debug("Accessing database...")
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		UPDATE answer.answer SET deleted_at = NOW(), deleted_by = $2 WHERE `+column+` = $1 AND deleted_at IS NULL
+			RETURNING `+answerColumns, value, deletedBy)
+	if err != nil {
+		return 0, err
+	}
+	deleted := make([]Answer, 0)
+	for rows.Next() {
+		a, err := scanAnswer(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		deleted = append(deleted, a)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, a := range deleted {
+		if err = addEvent(tx, EventAnswerDeleted, a, a); err != nil {
+			return 0, err
+		}
+	}
+	return len(deleted), tx.Commit()
 }
 
-// DeleteAnswerByID delete answer by id
+// DeleteAnswerByID move answer to trash
 func (service *AService) DeleteAnswerByID(a Answer) error {
-	utils.LOG("Accessing database...")
-	res, err := service.Conn.Exec(`DELETE FROM answer.answer WHERE id = $1`, a.ID)
-	if err == nil && res.RowsAffected() != 1 {
+	n, err := service.deleteWhere(`id`, a.ID, a.DeletedBy)
+	if err == nil && n != 1 {
 		err = ui.ErrNoDataToDelete
 	}
 	return err
 }
 
-// DeleteAnswerByAuthorID delete answer by author id
-func (service *AService) DeleteAnswerByAuthorID(a Answer) error {
-	utils.LOG("Accessing database...")
-	res, err := service.Conn.Exec(`DELETE FROM answer.answer WHERE author_id = $1`, a.AuthorID)
-	if err == nil && res.RowsAffected() != 1 {
-		err = nil
+// DeleteAnswerByAuthorID move all the author answers to trash
+func (service *AService) DeleteAnswerByAuthorID(a Answer) (int, error) {
+	return service.deleteWhere(`author_id`, a.AuthorID, a.DeletedBy)
+}
+
+// DeleteAnswerByQuestionID move all the question answers to trash
+func (service *AService) DeleteAnswerByQuestionID(a Answer) (int, error) {
+	return service.deleteWhere(`question_id`, a.QuestionID, a.DeletedBy)
+}
+
+// RestoreAnswer take answer back from trash
+func (service *AService) RestoreAnswer(a Answer) (Answer, error) {
+	utils.Debug("Accessing database...")
+	row := service.Conn.QueryRow(`
+		UPDATE answer.answer SET deleted_at = NULL, deleted_by = NULL WHERE id = $1 AND deleted_at IS NOT NULL
+			RETURNING `+answerColumns, a.ID)
+
+	restored, err := scanAnswer(row)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoDataToUpdate
 	}
-	return err
+	return restored, err
 }
 
-// DeleteAnswerByQuestionID delete answer by author id
-func (service *AService) DeleteAnswerByQuestionID(a Answer) error {
-	utils.LOG("Accessing database...")
-	res, err := service.Conn.Exec(`DELETE FROM answer.answer WHERE question_id = $1`, a.QuestionID)
-	if err == nil && res.RowsAffected() != 1 {
-		err = nil
+// PurgeDeleted permanently remove answers moved to trash before passed time
+func (service *AService) PurgeDeleted(before time.Time) (int, error) {
+	utils.Debug("Accessing database...")
+	res, err := service.Conn.Exec(`DELETE FROM answer.answer WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
 	}
-	return err
+	return int(res.RowsAffected()), nil
 }
 
 // GetAnswerByID get answer data by it's id
-func (service *AService) GetAnswerByID(aID int) (Answer, error) {
-	var err error
-	var a Answer
-
-	utils.LOG("Accessing database...")
-	row := service.Conn.QueryRow(`SELECT * FROM answer.answer WHERE id = $1`, aID)
+func (service *AService) GetAnswerByID(aID int, includeDeleted bool) (Answer, error) {
+	utils.Debug("Accessing database...")
+	row := service.Conn.QueryRow(`SELECT `+answerColumns+` FROM answer.answer WHERE id = $1`+notDeleted(includeDeleted), aID)
 
-	err = row.Scan(
-		&a.ID,
-		&a.QuestionID,
-		&a.Content,
-		&a.AuthorID,
-		&a.AuthorNickname,
-		&a.IsBest,
-		&a.Created)
+	a, err := scanAnswer(row)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoResult
+	}
 
 	return a, err
 }
 
-func (service *AService) getAnswers(query string, id int, limit int, offset int) ([]Answer, error) {
-	var err error
-	a := make([]Answer, 0)
+// filterConditions returns conditions of set query filters, their values are appended to args
+func filterConditions(q AnswersQuery, args []interface{}) (string, []interface{}) {
+	conditions := notDeleted(q.IncludeDeleted)
+	filters := []struct {
+		condition string
+		set       bool
+		value     func() interface{}
+	}{
+		{`is_best = $%d`, q.IsBest != nil, func() interface{} { return *q.IsBest }},
+		{`created >= $%d`, q.CreatedFrom != nil, func() interface{} { return *q.CreatedFrom }},
+		{`created < $%d`, q.CreatedTo != nil, func() interface{} { return *q.CreatedTo }},
+		{`author_id = $%d`, q.AuthorID != nil, func() interface{} { return *q.AuthorID }},
+	}
+	for _, f := range filters {
+		if f.set {
+			args = append(args, f.value())
+			conditions += ` AND ` + fmt.Sprintf(f.condition, len(args))
+		}
+	}
+	return conditions, args
+}
 
-	utils.LOG("Accessing database...")
-	rows, err := service.Conn.Query(query, id, limit, offset)
+// getAnswers returns page of answers having column equal to id, column is never user input
+func (service *AService) getAnswers(column string, id int, q AnswersQuery) (AnswersPage, error) {
+	order, keys := keysOf(q.Order)
+	page, err := normalizePage(q.Page, order)
 	if err != nil {
-		return a, err
+		return AnswersPage{Answers: make([]Answer, 0)}, err
+	}
+
+	filters, args := filterConditions(q, []interface{}{id})
+	where := column + ` = $1` + filters
+
+	var total *int
+	if page.WithTotal {
+		var count int
+		utils.Debug("Accessing database...")
+		err = service.Conn.QueryRow(`SELECT COUNT(*) FROM answer.answer WHERE `+where, args...).Scan(&count)
+		if err != nil {
+			return AnswersPage{Answers: make([]Answer, 0)}, err
+		}
+		total = &count
+	}
+
+	reverse := false
+	if page.Cursor != nil {
+		var condition string
+		condition, args = keysetCondition(keys, *page.Cursor, args)
+		where += ` AND (` + condition + `)`
+		reverse = page.Cursor.Before
+	}
+	args = append(args, page.Limit+1)
+	query := `SELECT ` + answerColumns + ` FROM answer.answer WHERE ` + where +
+		` ORDER BY ` + orderClause(keys, reverse) + fmt.Sprintf(` LIMIT $%d`, len(args))
+
+	a := make([]Answer, 0)
+	rows, err := service.Conn.Query(query, args...)
+	if err != nil {
+		return AnswersPage{Answers: a}, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var ta Answer
-		err = rows.Scan(
-			&ta.ID,
-			&ta.QuestionID,
-			&ta.Content,
-			&ta.AuthorID,
-			&ta.AuthorNickname,
-			&ta.IsBest,
-			&ta.Created)
-
+		ta, err = scanAnswer(rows)
 		if err != nil {
-			return a, err
+			return AnswersPage{Answers: make([]Answer, 0)}, err
 		}
 
 		a = append(a, ta)
 	}
+	if err = rows.Err(); err != nil {
+		return AnswersPage{Answers: make([]Answer, 0)}, err
+	}
 
-	return a, err
+	p := pageOf(order, page, a)
+	p.Total = total
+	return p, nil
 }
 
-// GetAnswersByAuthorID get answer data by it's id
-func (service *AService) GetAnswersByAuthorID(aAuthorID int, limit int, offset int) ([]Answer, error) {
-	if offset < 0 {
-		offset = 0
-	}
-	if limit <= 0 {
-		limit = 20 // default
-	}
-	utils.LOG(fmt.Sprintf(`SELECT * FROM answer.answer WHERE author_id = %d ORDER BY id ASC LIMIT %d OFFSET %d`, aAuthorID, limit, offset))
-	return service.getAnswers(`SELECT * FROM answer.answer WHERE author_id = $1 ORDER BY id ASC LIMIT $2 OFFSET $3`, aAuthorID, limit, offset)
+// GetAnswersByAuthorID get page of author answers
+func (service *AService) GetAnswersByAuthorID(aAuthorID int, q AnswersQuery) (AnswersPage, error) {
+	return service.getAnswers(`author_id`, aAuthorID, q)
+}
+
+// GetAnswersByQuestionID get page of question answers
+func (service *AService) GetAnswersByQuestionID(aQuestionID int, q AnswersQuery) (AnswersPage, error) {
+	return service.getAnswers(`question_id`, aQuestionID, q)
 }
 
-// GetAnswersByQuestionID get answer data by it's id
-func (service *AService) GetAnswersByQuestionID(aQuestionID int, limit int, offset int) ([]Answer, error) {
-	if offset < 0 {
-		offset = 0
+// UpdateAnswer Mark answer as best, previous best answer of the question loses the flag
+func (service *AService) UpdateAnswer(a Answer) (BestAnswer, error) {
+	var best BestAnswer
+
+	utils.Debug("Accessing database...")
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return best, err
 	}
-	if limit <= 0 {
-		limit = 20 // default
+	defer tx.Rollback()
+
+	var questionID int
+	err = tx.QueryRow(`SELECT question_id FROM answer.answer WHERE id = $1 AND deleted_at IS NULL`, a.ID).Scan(&questionID)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoDataToUpdate
+	}
+	if err != nil {
+		return best, err
 	}
-	utils.LOG(fmt.Sprintf(`SELECT * FROM answer.answer WHERE question_id = %d ORDER BY id ASC LIMIT %d OFFSET %d`, aQuestionID, limit, offset))
-	return service.getAnswers(`SELECT * FROM answer.answer WHERE question_id = $1 ORDER BY id ASC LIMIT $2 OFFSET $3`, aQuestionID, limit, offset)
-}
 
-// UpdateAnswer Mark answer as best
-func (service *AService) UpdateAnswer(a Answer) (Answer, error) {
-	currentAnswerData, err := service.GetAnswerByID(a.ID)
+	// concurrent best answer changes of one question wait for each other here
+	rows, err := tx.Query(`SELECT id FROM answer.answer WHERE question_id = $1 ORDER BY id FOR UPDATE`, questionID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			err = ui.ErrNoDataToUpdate
-		}
-		return a, err
+		return best, err
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return best, err
 	}
 
-	utils.LOG("Accessing database...")
-	res, err := service.Conn.Exec(`UPDATE answer.answer SET is_best = true WHERE id = $1`, a.ID)
-	if err == nil && res.RowsAffected() != 1 {
+	var previousID int
+	err = tx.QueryRow(`
+		UPDATE answer.answer SET is_best = FALSE WHERE question_id = $1 AND is_best AND id <> $2 RETURNING id
+	`, questionID, a.ID).Scan(&previousID)
+	if err == nil {
+		best.PreviousBestID = &previousID
+	} else if err != pgx.ErrNoRows {
+		return best, err
+	}
+
+	row := tx.QueryRow(`UPDATE answer.answer SET is_best = TRUE WHERE id = $1 RETURNING `+answerColumns, a.ID)
+	best.Answer, err = scanAnswer(row)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoDataToUpdate
+	}
+	if err == nil {
+		err = addEvent(tx, EventAnswerBestMarked, best.Answer, best)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	return best, err
+}
+
+// UnmarkBestAnswer remove best answer flag
+func (service *AService) UnmarkBestAnswer(a Answer) (Answer, error) {
+	utils.Debug("Accessing database...")
+	row := service.Conn.QueryRow(`UPDATE answer.answer SET is_best = FALSE WHERE id = $1 AND deleted_at IS NULL RETURNING `+answerColumns, a.ID)
+
+	updated, err := scanAnswer(row)
+	if err == pgx.ErrNoRows {
 		err = ui.ErrNoDataToUpdate
 	}
-	*currentAnswerData.IsBest = true
-	return currentAnswerData, err
+	return updated, err
 }
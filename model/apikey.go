@@ -0,0 +1,95 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jackc/pgx"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+)
+
+// apiKeyColumns columns scanned by scanAPIKey, table is aliased as k
+const apiKeyColumns = `k.id, k.name, k.prefix, k.hash, k.scopes, k.created, k.revoked`
+
+// scanAPIKey reads key selected with apiKeyColumns followed by extra columns
+func scanAPIKey(row scanner, extra ...interface{}) (APIKey, error) {
+	var k APIKey
+	dest := []interface{}{&k.ID, &k.Name, &k.Prefix, &k.Hash, &k.Scopes, &k.Created, &k.Revoked}
+	err := row.Scan(append(dest, extra...)...)
+	return k, err
+}
+
+// AddAPIKey stores new key
+func (service *AService) AddAPIKey(k APIKey) (APIKey, error) {
+	utils.Debug("Accessing database...")
+	row := service.Conn.QueryRow(`
+		INSERT INTO answer.api_key AS k (name, prefix, hash, scopes) VALUES ($1, $2, $3, $4)
+		RETURNING `+apiKeyColumns,
+		k.Name, k.Prefix, k.Hash, k.Scopes)
+	return scanAPIKey(row)
+}
+
+// GetAPIKeys lists all the keys with their usage, rolled up and logged after rollup
+func (service *AService) GetAPIKeys() ([]APIKey, error) {
+	utils.Debug("Accessing database...")
+	rows, err := service.Conn.Query(`
+		SELECT ` + apiKeyColumns + `, COALESCE(h.requests, 0) + COALESCE(s.requests, 0), GREATEST(h.last_used, s.last_used)
+			FROM answer.api_key k
+			LEFT JOIN (
+				SELECT api_key_id, sum(requests) AS requests, max(last_request_time) AS last_used
+					FROM answer.services_hourly GROUP BY api_key_id
+			) h ON h.api_key_id = k.id
+			LEFT JOIN (
+				SELECT api_key_id, count(*) AS requests, max(request_time) AS last_used
+					FROM answer.services WHERE NOT rolled_up
+					GROUP BY api_key_id
+			) s ON s.api_key_id = k.id
+			ORDER BY k.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		var count int64
+		var lastUsed *time.Time
+		k, err := scanAPIKey(rows, &count, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		k.RequestsCount = int(count)
+		k.LastUsed = lastUsed
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash finds key which is not revoked
+func (service *AService) GetAPIKeyByHash(hash string) (APIKey, error) {
+	row := service.Conn.QueryRow(`
+		SELECT `+apiKeyColumns+` FROM answer.api_key k WHERE k.hash = $1 AND k.revoked IS NULL
+	`, hash)
+	k, err := scanAPIKey(row)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoResult
+	}
+	return k, err
+}
+
+// RevokeAPIKey disables key, it stays in listings
+func (service *AService) RevokeAPIKey(id int) error {
+	utils.Debug("Accessing database...")
+	res, err := service.Conn.Exec(`
+		UPDATE answer.api_key SET revoked = NOW() WHERE id = $1 AND revoked IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ui.ErrNoDataToUpdate
+	}
+	return nil
+}
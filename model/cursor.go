@@ -0,0 +1,206 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RSOI/answer/ui"
+)
+
+// String returns opaque cursor representation
+func (c Cursor) String() string {
+	content, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// ParseCursor reads cursor returned by Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	content, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(content, &c) != nil || c.ID <= 0 {
+		return c, ui.ErrInvalidParameter
+	}
+	if _, ok := sortKeys[c.Order]; !ok {
+		return c, ui.ErrInvalidParameter
+	}
+	if (c.Order == OrderByNewest || c.Order == OrderByOldest) && c.Created == nil {
+		return c, ui.ErrInvalidParameter
+	}
+	return c, nil
+}
+
+// sortKey one column of listing order
+type sortKey struct {
+	column string
+	desc   bool
+	value  func(a Answer) interface{}
+}
+
+func answerID(a Answer) interface{}      { return a.ID }
+func answerScore(a Answer) interface{}   { return a.Score }
+func answerCreated(a Answer) interface{} { return a.Created }
+func answerIsBest(a Answer) interface{}  { return a.IsBest != nil && *a.IsBest }
+
+// sortKeys known listing orders, every order ends with id so that answer position is unique
+var sortKeys = map[AnswersOrder][]sortKey{
+	OrderByID:     {{"id", false, answerID}},
+	OrderByScore:  {{"score", true, answerScore}, {"id", false, answerID}},
+	OrderByBest:   {{"is_best", true, answerIsBest}, {"id", false, answerID}},
+	OrderByNewest: {{"created", true, answerCreated}, {"id", true, answerID}},
+	OrderByOldest: {{"created", false, answerCreated}, {"id", false, answerID}},
+}
+
+// keysOf returns sort keys of order, anything unknown is ordered by id
+func keysOf(order AnswersOrder) (AnswersOrder, []sortKey) {
+	keys, ok := sortKeys[order]
+	if !ok {
+		return OrderByID, sortKeys[OrderByID]
+	}
+	return order, keys
+}
+
+// cursorAt returns cursor pointing to passed answer
+func cursorAt(order AnswersOrder, a Answer, before bool) *Cursor {
+	c := &Cursor{Order: order, ID: a.ID, Before: before}
+	switch order {
+	case OrderByScore:
+		c.Score = a.Score
+	case OrderByBest:
+		c.IsBest = answerIsBest(a).(bool)
+	case OrderByNewest, OrderByOldest:
+		created := a.Created
+		c.Created = &created
+	}
+	return c
+}
+
+// answer returns answer with the cursor sort key values
+func (c Cursor) answer() Answer {
+	a := Answer{ID: c.ID, Score: c.Score, IsBest: &c.IsBest}
+	if c.Created != nil {
+		a.Created = *c.Created
+	}
+	return a
+}
+
+// normalizePage applies default limit and checks that cursor belongs to the listing order
+func normalizePage(page Page, order AnswersOrder) (Page, error) {
+	if page.Limit <= 0 {
+		page.Limit = PageSize
+	}
+	if page.Cursor != nil && page.Cursor.Order != order {
+		return page, ui.ErrInvalidParameter
+	}
+	return page, nil
+}
+
+func compareValues(a interface{}, b interface{}) int {
+	switch av := a.(type) {
+	case int:
+		bv := b.(int)
+		if av < bv {
+			return -1
+		} else if av > bv {
+			return 1
+		}
+	case bool:
+		bv := b.(bool)
+		if !av && bv {
+			return -1
+		} else if av && !bv {
+			return 1
+		}
+	case time.Time:
+		bv := b.(time.Time)
+		if av.Before(bv) {
+			return -1
+		} else if av.After(bv) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareAnswers returns negative value if a goes before b in listing order
+func compareAnswers(keys []sortKey, a Answer, b Answer) int {
+	for _, k := range keys {
+		if c := compareValues(k.value(a), k.value(b)); c != 0 {
+			if k.desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// orderClause returns ORDER BY expression, reversed one is used to read pages before cursor
+func orderClause(keys []sortKey, reverse bool) string {
+	columns := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k.desc != reverse {
+			columns = append(columns, k.column+" DESC")
+		} else {
+			columns = append(columns, k.column+" ASC")
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// keysetCondition returns condition selecting answers after (or before) cursor, its values are appended to args
+func keysetCondition(keys []sortKey, c Cursor, args []interface{}) (string, []interface{}) {
+	at := c.answer()
+	alternatives := make([]string, 0, len(keys))
+	for i, k := range keys {
+		conditions := make([]string, 0, i+1)
+		for _, eq := range keys[:i] {
+			args = append(args, eq.value(at))
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", eq.column, len(args)))
+		}
+		op := ">"
+		if k.desc != c.Before {
+			op = "<"
+		}
+		args = append(args, k.value(at))
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", k.column, op, len(args)))
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+	return strings.Join(alternatives, " OR "), args
+}
+
+// pageOf builds page from up to page.Limit+1 answers read in the cursor direction
+func pageOf(order AnswersOrder, page Page, rows []Answer) AnswersPage {
+	more := len(rows) > page.Limit
+	if more {
+		rows = rows[:page.Limit]
+	}
+	before := page.Cursor != nil && page.Cursor.Before
+	if before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	p := AnswersPage{Answers: rows}
+	if len(rows) == 0 {
+		return p
+	}
+	last := len(rows) - 1
+	if before {
+		if more {
+			p.Prev = cursorAt(order, rows[0], true)
+		}
+		p.Next = cursorAt(order, rows[last], false)
+	} else {
+		if more {
+			p.Next = cursorAt(order, rows[last], false)
+		}
+		if page.Cursor != nil {
+			p.Prev = cursorAt(order, rows[0], true)
+		}
+	}
+	return p
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{Order: OrderByScore, ID: 12, Score: -3, Before: true}
+
+	parsed, err := ParseCursor(c.String())
+	if assert.Nil(t, err) {
+		assert.Equal(t, c, parsed)
+	}
+}
+
+func TestParseBrokenCursor(t *testing.T) {
+	for _, s := range []string{"", "!!!", "e30", Cursor{Order: "random", ID: 1}.String(), Cursor{Order: OrderByID}.String(), Cursor{Order: OrderByNewest, ID: 1}.String()} {
+		_, err := ParseCursor(s)
+		assert.Equal(t, ui.ErrInvalidParameter, err, s)
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	c := Cursor{Order: OrderByScore, ID: 12, Score: 3}
+
+	condition, args := keysetCondition(sortKeys[OrderByScore], c, []interface{}{1})
+	assert.Equal(t, "(score < $2) OR (score = $3 AND id > $4)", condition)
+	assert.Equal(t, []interface{}{1, 3, 3, 12}, args)
+
+	c.Before = true
+	condition, _ = keysetCondition(sortKeys[OrderByScore], c, nil)
+	assert.Equal(t, "(score > $1) OR (score = $2 AND id < $3)", condition)
+	assert.Equal(t, "score ASC, id DESC", orderClause(sortKeys[OrderByScore], true))
+}
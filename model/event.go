@@ -0,0 +1,141 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+
+	"github.com/RSOI/answer/ui"
+)
+
+// Types of domain events written to outbox with answer changes
+const (
+	// EventAnswerCreated payload is the created Answer
+	EventAnswerCreated = "answer.created"
+	// EventAnswerBestMarked payload is BestAnswer with id of answer which lost the flag
+	EventAnswerBestMarked = "answer.best_marked"
+	// EventAnswerDeleted payload is the Answer moved to trash
+	EventAnswerDeleted = "answer.deleted"
+)
+
+// EventTypes every type of event written to outbox
+var EventTypes = []string{EventAnswerCreated, EventAnswerBestMarked, EventAnswerDeleted}
+
+// EventVersion version of payload schema, it is changed along with incompatible payload changes
+const EventVersion = 1
+
+// Event domain event of answer change. ID is unique, so that consumers drop events delivered twice.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	AnswerID   int             `json:"answer_id"`
+	QuestionID int             `json:"question_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Created    time.Time       `json:"created"`
+	// Attempts failed deliveries of event
+	Attempts int `json:"-"`
+}
+
+// newEventID returns random UUID
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newEvent returns event of change of answer a described by payload
+func newEvent(eventType string, a Answer, payload interface{}) (Event, error) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         newEventID(),
+		Type:       eventType,
+		Version:    EventVersion,
+		AnswerID:   a.ID,
+		QuestionID: a.QuestionID,
+		Payload:    content,
+		Created:    time.Now(),
+	}, nil
+}
+
+// addEvent writes event to outbox within tx of the change
+func addEvent(tx *pgx.Tx, eventType string, a Answer, payload interface{}) error {
+	e, err := newEvent(eventType, a, payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO answer.outbox
+			(event_id, type, version, answer_id, question_id, payload, created) VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7)
+	`, e.ID, e.Type, e.Version, e.AnswerID, e.QuestionID, string(e.Payload), e.Created)
+	return err
+}
+
+// ClaimEvents returns up to limit oldest undelivered events which are due and hides them from other
+// relays for lease. Events which are neither published nor retried are claimed again after lease.
+func (service *AService) ClaimEvents(limit int, lease time.Duration) ([]Event, error) {
+	rows, err := service.Conn.Query(`
+		WITH claimed AS (
+			UPDATE answer.outbox SET next_attempt = NOW() + $2 * INTERVAL '1 millisecond'
+				WHERE id IN (
+					SELECT id FROM answer.outbox WHERE published IS NULL AND next_attempt <= NOW()
+						ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, event_id, type, version, answer_id, question_id, payload::text, created, attempts
+		)
+		SELECT event_id, type, version, answer_id, question_id, payload, created, attempts FROM claimed ORDER BY id
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+	for rows.Next() {
+		var e Event
+		var payload string
+		if err = rows.Scan(&e.ID, &e.Type, &e.Version, &e.AnswerID, &e.QuestionID, &payload, &e.Created, &e.Attempts); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// MarkEventsPublished stops delivery of events
+func (service *AService) MarkEventsPublished(ids []string) error {
+	_, err := service.Conn.Exec(`
+		UPDATE answer.outbox SET published = NOW() WHERE event_id = ANY($1) AND published IS NULL
+	`, ids)
+	return err
+}
+
+// PurgeEvents removes events published before given time, pending ones are kept
+func (service *AService) PurgeEvents(before time.Time) (int, error) {
+	res, err := service.Conn.Exec(`DELETE FROM answer.outbox WHERE published < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.RowsAffected()), nil
+}
+
+// RetryEvent counts failed delivery of event and postpones next one until at
+func (service *AService) RetryEvent(id string, at time.Time, reason string) error {
+	res, err := service.Conn.Exec(`
+		UPDATE answer.outbox SET attempts = attempts + 1, next_attempt = $2, last_error = $3
+			WHERE event_id = $1 AND published IS NULL
+	`, id, at, reason)
+	if err == nil && res.RowsAffected() != 1 {
+		err = ui.ErrNoDataToUpdate
+	}
+	return err
+}
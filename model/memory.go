@@ -0,0 +1,774 @@
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+)
+
+// AMemoryService in-memory answer storage. Zero value is ready to use.
+type AMemoryService struct {
+	mu        sync.RWMutex
+	lastID    int
+	answers   []Answer
+	revisions map[int][]Revision
+	votes     map[int]map[int]int
+	stats     []loggedStat
+	keys      []APIKey
+	// hourly aggregates of rolled up stats, rolledUp of them in total
+	hourly   map[hourlyKey]*hourlyStat
+	rolledUp int
+	outbox   []outboxEvent
+}
+
+// loggedStat request with its rollup state, mirrors answer.services row
+type loggedStat struct {
+	RequestInfo
+	rolledUp bool
+}
+
+// outboxEvent event with its delivery state, mirrors answer.outbox row
+type outboxEvent struct {
+	Event
+	nextAttempt time.Time
+	lastError   string
+	// published time of publishing, zero while event is pending
+	published time.Time
+}
+
+// addEvent appends event to outbox, mu must be held
+func (service *AMemoryService) addEvent(eventType string, a Answer, payload interface{}) {
+	e, err := newEvent(eventType, a, payload)
+	if err != nil {
+		utils.Error("Event is not stored", utils.Fields{"type": eventType, "answer_id": a.ID, "err": err})
+		return
+	}
+	service.outbox = append(service.outbox, outboxEvent{Event: e, nextAttempt: e.Created})
+}
+
+// hourlyKey requests of one hour are rolled up by method, route and API key
+type hourlyKey struct {
+	hour     time.Time
+	method   string
+	route    string
+	apiKeyID int
+}
+
+// hourlyStat rolled up requests, mirrors answer.services_hourly row
+type hourlyStat struct {
+	requests     int
+	clientErrors int
+	serverErrors int
+	durationSum  float64
+	lastRequest  time.Time
+}
+
+// copyRevision returns revision which doesn't share pointers with the source
+func copyRevision(r Revision) Revision {
+	if r.Content != nil {
+		content := *r.Content
+		r.Content = &content
+	}
+	return r
+}
+
+// copyAnswer returns answer which doesn't share pointers with the source
+func copyAnswer(a Answer) Answer {
+	if a.Content != nil {
+		content := *a.Content
+		a.Content = &content
+	}
+	if a.IsBest != nil {
+		isBest := *a.IsBest
+		a.IsBest = &isBest
+	}
+	if a.DeletedAt != nil {
+		deletedAt := *a.DeletedAt
+		a.DeletedAt = &deletedAt
+	}
+	if a.DeletedBy != nil {
+		deletedBy := *a.DeletedBy
+		a.DeletedBy = &deletedBy
+	}
+	return a
+}
+
+// find returns index of answer with passed id or -1, answers in trash are skipped unless includeDeleted is set
+func (service *AMemoryService) find(aID int, includeDeleted bool) int {
+	for i := range service.answers {
+		if service.answers[i].ID == aID && (includeDeleted || service.answers[i].DeletedAt == nil) {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddAnswer add new answer
+func (service *AMemoryService) AddAnswer(a Answer) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	isBest := false
+	service.lastID++
+	a.ID = service.lastID
+	a.IsBest = &isBest
+	a.Created = time.Now()
+	a.Updated = a.Created
+	a.Revision = 1
+	a = copyAnswer(a)
+	service.answers = append(service.answers, a)
+	service.addRevision(Revision{
+		AnswerID: a.ID,
+		Revision: a.Revision,
+		Content:  a.Content,
+		EditorID: a.AuthorID,
+		Created:  a.Created,
+	})
+	service.addEvent(EventAnswerCreated, a, a)
+
+	return copyAnswer(a), nil
+}
+
+// deleteWhere moves all the answers matching f to trash and returns moved count
+func (service *AMemoryService) deleteWhere(f func(a Answer) bool, deletedBy *int) int {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	now := time.Now()
+	moved := 0
+	for i := range service.answers {
+		ta := &service.answers[i]
+		if ta.DeletedAt == nil && f(*ta) {
+			ta.DeletedAt = &now
+			ta.DeletedBy = deletedBy
+			*ta = copyAnswer(*ta)
+			service.addEvent(EventAnswerDeleted, *ta, *ta)
+			moved++
+		}
+	}
+	return moved
+}
+
+// DeleteAnswerByID move answer to trash
+func (service *AMemoryService) DeleteAnswerByID(a Answer) error {
+	utils.Debug("Accessing memory storage...")
+	if service.deleteWhere(func(ta Answer) bool { return ta.ID == a.ID }, a.DeletedBy) != 1 {
+		return ui.ErrNoDataToDelete
+	}
+	return nil
+}
+
+// DeleteAnswerByAuthorID move all the author answers to trash
+func (service *AMemoryService) DeleteAnswerByAuthorID(a Answer) (int, error) {
+	utils.Debug("Accessing memory storage...")
+	return service.deleteWhere(func(ta Answer) bool { return ta.AuthorID == a.AuthorID }, a.DeletedBy), nil
+}
+
+// DeleteAnswerByQuestionID move all the question answers to trash
+func (service *AMemoryService) DeleteAnswerByQuestionID(a Answer) (int, error) {
+	utils.Debug("Accessing memory storage...")
+	return service.deleteWhere(func(ta Answer) bool { return ta.QuestionID == a.QuestionID }, a.DeletedBy), nil
+}
+
+// RestoreAnswer take answer back from trash
+func (service *AMemoryService) RestoreAnswer(a Answer) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	i := service.find(a.ID, true)
+	if i < 0 || service.answers[i].DeletedAt == nil {
+		return a, ui.ErrNoDataToUpdate
+	}
+	service.answers[i].DeletedAt = nil
+	service.answers[i].DeletedBy = nil
+	return copyAnswer(service.answers[i]), nil
+}
+
+// PurgeDeleted permanently remove answers moved to trash before passed time
+func (service *AMemoryService) PurgeDeleted(before time.Time) (int, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	kept := service.answers[:0]
+	for _, a := range service.answers {
+		if a.DeletedAt == nil || !a.DeletedAt.Before(before) {
+			kept = append(kept, a)
+		} else {
+			delete(service.revisions, a.ID)
+			delete(service.votes, a.ID)
+		}
+	}
+	removed := len(service.answers) - len(kept)
+	service.answers = kept
+	return removed, nil
+}
+
+// GetAnswerByID get answer data by it's id
+func (service *AMemoryService) GetAnswerByID(aID int, includeDeleted bool) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	i := service.find(aID, includeDeleted)
+	if i < 0 {
+		return Answer{}, ui.ErrNoResult
+	}
+	return copyAnswer(service.answers[i]), nil
+}
+
+// matches reports whether answer passes query filters
+func matches(q AnswersQuery, a Answer) bool {
+	return (q.IncludeDeleted || a.DeletedAt == nil) &&
+		(q.IsBest == nil || *q.IsBest == *a.IsBest) &&
+		(q.CreatedFrom == nil || !a.Created.Before(*q.CreatedFrom)) &&
+		(q.CreatedTo == nil || a.Created.Before(*q.CreatedTo)) &&
+		(q.AuthorID == nil || *q.AuthorID == a.AuthorID)
+}
+
+// getAnswers returns page of answers matching f and query filters
+func (service *AMemoryService) getAnswers(f func(a Answer) bool, q AnswersQuery) (AnswersPage, error) {
+	order, keys := keysOf(q.Order)
+	page, err := normalizePage(q.Page, order)
+	if err != nil {
+		return AnswersPage{Answers: make([]Answer, 0)}, err
+	}
+
+	utils.Debug("Accessing memory storage...")
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	matched := make([]Answer, 0)
+	for _, ta := range service.answers {
+		if f(ta) && matches(q, ta) {
+			matched = append(matched, ta)
+		}
+	}
+	total := len(matched)
+
+	reverse := page.Cursor != nil && page.Cursor.Before
+	sort.Slice(matched, func(i, j int) bool {
+		c := compareAnswers(keys, matched[i], matched[j])
+		if reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	a := make([]Answer, 0)
+	for _, ta := range matched {
+		if len(a) > page.Limit {
+			break
+		}
+		if page.Cursor != nil {
+			c := compareAnswers(keys, ta, page.Cursor.answer())
+			if (reverse && c >= 0) || (!reverse && c <= 0) {
+				continue
+			}
+		}
+		a = append(a, copyAnswer(ta))
+	}
+
+	p := pageOf(order, page, a)
+	if page.WithTotal {
+		p.Total = &total
+	}
+	return p, nil
+}
+
+// GetAnswersByAuthorID get page of author answers
+func (service *AMemoryService) GetAnswersByAuthorID(aAuthorID int, q AnswersQuery) (AnswersPage, error) {
+	return service.getAnswers(func(a Answer) bool { return a.AuthorID == aAuthorID }, q)
+}
+
+// GetAnswersByQuestionID get page of question answers
+func (service *AMemoryService) GetAnswersByQuestionID(aQuestionID int, q AnswersQuery) (AnswersPage, error) {
+	return service.getAnswers(func(a Answer) bool { return a.QuestionID == aQuestionID }, q)
+}
+
+// SearchAnswers find answers containing all the words of q.Text, most relevant first
+func (service *AMemoryService) SearchAnswers(q SearchQuery) ([]SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = PageSize
+	}
+	terms := searchTerms(q.Text)
+
+	utils.Debug("Accessing memory storage...")
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	r := make([]SearchResult, 0)
+	for _, ta := range service.answers {
+		if ta.DeletedAt != nil || ta.Content == nil ||
+			(q.QuestionID != nil && *q.QuestionID != ta.QuestionID) ||
+			(q.AuthorID != nil && *q.AuthorID != ta.AuthorID) {
+			continue
+		}
+		if rank, snippet, ok := matchContent(*ta.Content, terms); ok {
+			r = append(r, SearchResult{Answer: copyAnswer(ta), Rank: rank, Snippet: snippet})
+		}
+	}
+
+	sortResults(r)
+	if len(r) > q.Limit {
+		r = r[:q.Limit]
+	}
+	return r, nil
+}
+
+// UpdateAnswer Mark answer as best, previous best answer of the question loses the flag
+func (service *AMemoryService) UpdateAnswer(a Answer) (BestAnswer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	var best BestAnswer
+	i := service.find(a.ID, false)
+	if i < 0 {
+		return best, ui.ErrNoDataToUpdate
+	}
+
+	for j := range service.answers {
+		ta := &service.answers[j]
+		if j != i && ta.QuestionID == service.answers[i].QuestionID && *ta.IsBest {
+			*ta.IsBest = false
+			previousID := ta.ID
+			best.PreviousBestID = &previousID
+		}
+	}
+
+	*service.answers[i].IsBest = true
+	best.Answer = copyAnswer(service.answers[i])
+	service.addEvent(EventAnswerBestMarked, best.Answer, best)
+	return best, nil
+}
+
+// UnmarkBestAnswer remove best answer flag
+func (service *AMemoryService) UnmarkBestAnswer(a Answer) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	i := service.find(a.ID, false)
+	if i < 0 {
+		return a, ui.ErrNoDataToUpdate
+	}
+	*service.answers[i].IsBest = false
+	return copyAnswer(service.answers[i]), nil
+}
+
+// GetUsageStatistic provides access to logs
+func (service *AMemoryService) GetUsageStatistic(host string) (ServiceStatus, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	ServiceResponse := ServiceStatus{
+		Address:       host,
+		RequestsCount: service.rolledUp,
+	}
+	for i := range service.stats {
+		if !service.stats[i].rolledUp {
+			ServiceResponse.RequestsCount++
+		}
+	}
+	if len(service.stats) == 0 {
+		ServiceResponse.LastUsage.Request = NotUsedYet
+	} else {
+		ServiceResponse.LastUsage = service.stats[len(service.stats)-1].RequestInfo
+	}
+
+	return ServiceResponse, nil
+}
+
+// LogStats appends batch of requests to log storage
+func (service *AMemoryService) LogStats(stats []RequestInfo) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for _, st := range stats {
+		service.stats = append(service.stats, loggedStat{RequestInfo: st})
+	}
+	utils.Debug("Statistic stored successfully", utils.Fields{"count": len(stats)})
+	return nil
+}
+
+// GetRouteStats provides per-route usage of requests logged since given time
+func (service *AMemoryService) GetRouteStats(since time.Time) ([]RouteStat, error) {
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	type key struct{ method, route string }
+	durations := map[key][]float64{}
+	routes := map[key]*RouteStat{}
+	for _, s := range service.stats {
+		if s.Route == "" || s.RequestTime.Before(since) {
+			continue
+		}
+		k := key{s.Method, s.Route}
+		r, ok := routes[k]
+		if !ok {
+			r = &RouteStat{Method: s.Method, Route: s.Route}
+			routes[k] = r
+		}
+		r.Requests++
+		if s.ResponseStatus >= 500 {
+			r.ServerErrors++
+		} else if s.ResponseStatus >= 400 {
+			r.ClientErrors++
+		}
+		durations[k] = append(durations[k], s.Duration)
+	}
+
+	res := make([]RouteStat, 0, len(routes))
+	for k, r := range routes {
+		d := durations[k]
+		sort.Float64s(d)
+		r.P50, r.P95, r.P99 = percentile(d, 0.5), percentile(d, 0.95), percentile(d, 0.99)
+		r.ErrorRate = float64(r.ServerErrors) / float64(r.Requests)
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Route != res[j].Route {
+			return res[i].Route < res[j].Route
+		}
+		return res[i].Method < res[j].Method
+	})
+	return res, nil
+}
+
+// RollupStats adds requests logged before given time which are not rolled up yet to hourly aggregates
+func (service *AMemoryService) RollupStats(until time.Time) (int, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if service.hourly == nil {
+		service.hourly = map[hourlyKey]*hourlyStat{}
+	}
+
+	n := 0
+	for i := range service.stats {
+		st := &service.stats[i]
+		if st.rolledUp || !st.RequestTime.Before(until) {
+			continue
+		}
+		st.rolledUp = true
+		k := hourlyKey{st.RequestTime.Truncate(time.Hour), st.Method, st.Route, st.APIKeyID}
+		h, ok := service.hourly[k]
+		if !ok {
+			h = &hourlyStat{}
+			service.hourly[k] = h
+		}
+		h.requests++
+		if st.ResponseStatus >= 500 {
+			h.serverErrors++
+		} else if st.ResponseStatus >= 400 {
+			h.clientErrors++
+		}
+		h.durationSum += st.Duration
+		if st.RequestTime.After(h.lastRequest) {
+			h.lastRequest = st.RequestTime
+		}
+		n++
+	}
+	service.rolledUp += n
+	return n, nil
+}
+
+// PurgeStats removes requests logged before given time, requests which are not rolled up yet are kept
+func (service *AMemoryService) PurgeStats(before time.Time) (int, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	kept := service.stats[:0]
+	for _, st := range service.stats {
+		if !st.rolledUp || !st.RequestTime.Before(before) {
+			kept = append(kept, st)
+		}
+	}
+	n := len(service.stats) - len(kept)
+	service.stats = kept
+	return n, nil
+}
+
+// ClaimEvents returns up to limit oldest undelivered events which are due and hides them for lease
+func (service *AMemoryService) ClaimEvents(limit int, lease time.Duration) ([]Event, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	now := time.Now()
+	events := make([]Event, 0)
+	for i := range service.outbox {
+		e := &service.outbox[i]
+		if len(events) == limit {
+			break
+		}
+		if !e.published.IsZero() || e.nextAttempt.After(now) {
+			continue
+		}
+		e.nextAttempt = now.Add(lease)
+		ev := e.Event
+		ev.Payload = append([]byte(nil), e.Payload...)
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// MarkEventsPublished stops delivery of events
+func (service *AMemoryService) MarkEventsPublished(ids []string) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	published := map[string]bool{}
+	for _, id := range ids {
+		published[id] = true
+	}
+	now := time.Now()
+	for i := range service.outbox {
+		if published[service.outbox[i].ID] && service.outbox[i].published.IsZero() {
+			service.outbox[i].published = now
+		}
+	}
+	return nil
+}
+
+// PurgeEvents removes events published before given time, pending ones are kept
+func (service *AMemoryService) PurgeEvents(before time.Time) (int, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	kept := service.outbox[:0]
+	for _, e := range service.outbox {
+		if e.published.IsZero() || !e.published.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(service.outbox) - len(kept)
+	service.outbox = kept
+	return n, nil
+}
+
+// RetryEvent counts failed delivery of event and postpones next one until at
+func (service *AMemoryService) RetryEvent(id string, at time.Time, reason string) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for i := range service.outbox {
+		e := &service.outbox[i]
+		if e.ID == id && e.published.IsZero() {
+			e.Attempts++
+			e.nextAttempt = at
+			e.lastError = reason
+			return nil
+		}
+	}
+	return ui.ErrNoDataToUpdate
+}
+
+// percentile interpolates between closest sorted values, the way percentile_cont does
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// AddAPIKey stores new key
+func (service *AMemoryService) AddAPIKey(k APIKey) (APIKey, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	k.ID = len(service.keys) + 1
+	k.Created = time.Now()
+	k.Scopes = append([]string(nil), k.Scopes...)
+	service.keys = append(service.keys, k)
+	return k, nil
+}
+
+// GetAPIKeys lists all the keys with their usage
+func (service *AMemoryService) GetAPIKeys() ([]APIKey, error) {
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(service.keys))
+	for _, k := range service.keys {
+		k.Scopes = append([]string(nil), k.Scopes...)
+		var lastUsed time.Time
+		for hk, h := range service.hourly {
+			if hk.apiKeyID == k.ID {
+				k.RequestsCount += h.requests
+				if h.lastRequest.After(lastUsed) {
+					lastUsed = h.lastRequest
+				}
+			}
+		}
+		for i := range service.stats {
+			st := service.stats[i]
+			if st.APIKeyID == k.ID && !st.rolledUp {
+				k.RequestsCount++
+				if st.RequestTime.After(lastUsed) {
+					lastUsed = st.RequestTime
+				}
+			}
+		}
+		if !lastUsed.IsZero() {
+			k.LastUsed = &lastUsed
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByHash finds key which is not revoked
+func (service *AMemoryService) GetAPIKeyByHash(hash string) (APIKey, error) {
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	for _, k := range service.keys {
+		if k.Hash == hash && k.Revoked == nil {
+			k.Scopes = append([]string(nil), k.Scopes...)
+			return k, nil
+		}
+	}
+	return APIKey{}, ui.ErrNoResult
+}
+
+// RevokeAPIKey disables key, it stays in listings
+func (service *AMemoryService) RevokeAPIKey(id int) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for i := range service.keys {
+		if service.keys[i].ID == id && service.keys[i].Revoked == nil {
+			now := time.Now()
+			service.keys[i].Revoked = &now
+			return nil
+		}
+	}
+	return ui.ErrNoDataToUpdate
+}
+
+func (service *AMemoryService) addRevision(r Revision) {
+	if service.revisions == nil {
+		service.revisions = make(map[int][]Revision)
+	}
+	service.revisions[r.AnswerID] = append(service.revisions[r.AnswerID], copyRevision(r))
+}
+
+// editAnswer stores new content as the next revision, lock must be held
+func (service *AMemoryService) editAnswer(r Revision) (Answer, error) {
+	i := service.find(r.AnswerID, false)
+	if i < 0 {
+		return Answer{}, ui.ErrNoDataToUpdate
+	}
+
+	a := &service.answers[i]
+	a.Content = copyRevision(r).Content
+	a.Revision++
+	a.Updated = time.Now()
+
+	r.Revision = a.Revision
+	r.Created = a.Updated
+	service.addRevision(r)
+	return copyAnswer(*a), nil
+}
+
+// EditAnswer change answer content keeping previous versions
+func (service *AMemoryService) EditAnswer(r Revision) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	return service.editAnswer(r)
+}
+
+// RollbackAnswer restore content of r.Revision as the new revision
+func (service *AMemoryService) RollbackAnswer(r Revision) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	revisions := service.revisions[r.AnswerID]
+	if r.Revision <= 0 || r.Revision > len(revisions) {
+		return Answer{}, ui.ErrNoResult
+	}
+
+	r.Content = revisions[r.Revision-1].Content
+	if r.Summary == "" {
+		r.Summary = rollbackSummary(r.Revision)
+	}
+	return service.editAnswer(r)
+}
+
+// GetRevisions get all the versions of answer content, oldest first
+func (service *AMemoryService) GetRevisions(aID int) ([]Revision, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	r := make([]Revision, 0, len(service.revisions[aID]))
+	if service.find(aID, false) < 0 {
+		return r, ui.ErrNoResult
+	}
+	for _, tr := range service.revisions[aID] {
+		r = append(r, copyRevision(tr))
+	}
+	if len(r) == 0 {
+		return r, ui.ErrNoResult
+	}
+	return r, nil
+}
+
+// GetRevision get one version of answer content
+func (service *AMemoryService) GetRevision(aID int, revision int) (Revision, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	revisions := service.revisions[aID]
+	if service.find(aID, false) < 0 || revision <= 0 || revision > len(revisions) {
+		return Revision{}, ui.ErrNoResult
+	}
+	return copyRevision(revisions[revision-1]), nil
+}
+
+// VoteAnswer set, change or retract (Value 0) user vote and recalculate answer score
+func (service *AMemoryService) VoteAnswer(v Vote) (Answer, error) {
+	utils.Debug("Accessing memory storage...")
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	i := service.find(v.AnswerID, false)
+	if i < 0 {
+		return Answer{}, ui.ErrNoDataToUpdate
+	}
+
+	if service.votes == nil {
+		service.votes = make(map[int]map[int]int)
+	}
+	votes := service.votes[v.AnswerID]
+	if votes == nil {
+		votes = make(map[int]int)
+		service.votes[v.AnswerID] = votes
+	}
+
+	a := &service.answers[i]
+	a.Score -= votes[v.VoterID]
+	if v.Value == 0 {
+		delete(votes, v.VoterID)
+	} else {
+		votes[v.VoterID] = v.Value
+		a.Score += v.Value
+	}
+	return copyAnswer(*a), nil
+}
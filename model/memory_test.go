@@ -0,0 +1,72 @@
+package model
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryAddConcurrentUniqueIDs(t *testing.T) {
+	service := &AMemoryService{}
+	content := "My Answer Content"
+
+	var wg sync.WaitGroup
+	ids := make(chan int, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, err := service.AddAnswer(Answer{QuestionID: 1, AuthorID: 1, AuthorNickname: "Test", Content: &content})
+			assert.Nil(t, err)
+			ids <- a.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		assert.False(t, seen[id])
+		seen[id] = true
+	}
+	assert.Equal(t, 100, len(seen))
+}
+
+func TestMemoryReturnedAnswerIsACopy(t *testing.T) {
+	service := &AMemoryService{}
+	content := "My Answer Content"
+
+	created, _ := service.AddAnswer(Answer{QuestionID: 1, AuthorID: 1, AuthorNickname: "Test", Content: &content})
+	content = "Changed outside"
+	*created.IsBest = true
+	*created.Content = "Changed by caller"
+
+	stored, err := service.GetAnswerByID(created.ID, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "My Answer Content", *stored.Content)
+		assert.False(t, *stored.IsBest)
+	}
+}
+
+func TestMemoryConcurrentVotes(t *testing.T) {
+	service := &AMemoryService{}
+	content := "My Answer Content"
+	created, _ := service.AddAnswer(Answer{QuestionID: 1, AuthorID: 1, AuthorNickname: "Test", Content: &content})
+
+	var wg sync.WaitGroup
+	for voter := 1; voter <= 50; voter++ {
+		wg.Add(1)
+		go func(voter int) {
+			defer wg.Done()
+			service.VoteAnswer(Vote{AnswerID: created.ID, VoterID: voter, Value: -1})
+			service.VoteAnswer(Vote{AnswerID: created.ID, VoterID: voter, Value: 1})
+		}(voter)
+	}
+	wg.Wait()
+
+	stored, err := service.GetAnswerByID(created.ID, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 50, stored.Score)
+	}
+}
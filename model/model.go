@@ -6,15 +6,132 @@ import (
 	"github.com/jackc/pgx"
 )
 
+// PageSize answers count returned by listings when limit isn't passed
+var PageSize = 20
+
+// MaxPageSize largest limit accepted by listings and search
+var MaxPageSize = 100
+
 // Answer interface
 type Answer struct {
-	ID             int       `json:"id"`
-	QuestionID     int       `json:"question_id"`
-	Content        *string   `json:"content"`
-	AuthorID       int       `json:"author_id"`
-	AuthorNickname string    `json:"author_nickname"`
-	IsBest         *bool     `json:"is_best"`
-	Created        time.Time `json:"created"`
+	ID             int        `json:"id"`
+	QuestionID     int        `json:"question_id"`
+	Content        *string    `json:"content"`
+	AuthorID       int        `json:"author_id"`
+	AuthorNickname string     `json:"author_nickname"`
+	IsBest         *bool      `json:"is_best"`
+	Created        time.Time  `json:"created"`
+	Updated        time.Time  `json:"updated"`
+	Revision       int        `json:"revision"`
+	Score          int        `json:"score"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy      *int       `json:"deleted_by,omitempty"`
+}
+
+// Vote one user vote for answer. Value is 1 (up), -1 (down) or 0 (retract)
+type Vote struct {
+	AnswerID int `json:"answer_id"`
+	VoterID  int `json:"voter_id"`
+	Value    int `json:"value"`
+}
+
+// APIKey service-to-service key. Only Hash of the key is stored, Prefix tells keys apart in listings.
+// RequestsCount and LastUsed are taken from usage statistic.
+type APIKey struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Prefix        string     `json:"prefix"`
+	Hash          string     `json:"-"`
+	Scopes        []string   `json:"scopes"`
+	Created       time.Time  `json:"created"`
+	Revoked       *time.Time `json:"revoked,omitempty"`
+	RequestsCount int        `json:"requests_count"`
+	LastUsed      *time.Time `json:"last_used,omitempty"`
+}
+
+// AnswersOrder listing order
+type AnswersOrder string
+
+const (
+	// OrderByID answers in creation order
+	OrderByID AnswersOrder = "id"
+	// OrderByScore highest score first, oldest first among equal scores
+	OrderByScore AnswersOrder = "score"
+	// OrderByBest best answer first, others in creation order
+	OrderByBest AnswersOrder = "best"
+	// OrderByNewest newest answers first
+	OrderByNewest AnswersOrder = "newest"
+	// OrderByOldest oldest answers first
+	OrderByOldest AnswersOrder = "oldest"
+)
+
+// Cursor position in answers listing. Clients get it as an opaque string, see Cursor.String
+type Cursor struct {
+	Order   AnswersOrder `json:"o"`
+	ID      int          `json:"id"`
+	Score   int          `json:"s,omitempty"`
+	IsBest  bool         `json:"bst,omitempty"`
+	Created *time.Time   `json:"c,omitempty"`
+	// Before cursor points to the page preceding the answer instead of the following one
+	Before bool `json:"b,omitempty"`
+}
+
+// Page listing page request, first page is requested without cursor
+type Page struct {
+	Limit     int
+	Cursor    *Cursor
+	WithTotal bool
+}
+
+// AnswersQuery listing options, nil filters are not applied
+type AnswersQuery struct {
+	Order  AnswersOrder
+	IsBest *bool
+	// CreatedFrom inclusive, CreatedTo exclusive bound of creation time
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	AuthorID       *int
+	IncludeDeleted bool
+	Page           Page
+}
+
+// AnswersPage one page of answers listing with cursors of its neighbours
+type AnswersPage struct {
+	Answers []Answer
+	Next    *Cursor
+	Prev    *Cursor
+	Total   *int
+}
+
+// SearchQuery full text search request, nil filters are not applied
+type SearchQuery struct {
+	Text       string
+	QuestionID *int
+	AuthorID   *int
+	Limit      int
+}
+
+// SearchResult found answer with its relevance and content fragment, matched words are wrapped in <b></b>
+type SearchResult struct {
+	Answer
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// BestAnswer answer marked as best
+type BestAnswer struct {
+	Answer
+	PreviousBestID *int `json:"previous_best_id"`
+}
+
+// Revision one version of answer content
+type Revision struct {
+	AnswerID int       `json:"answer_id"`
+	Revision int       `json:"revision"`
+	Content  *string   `json:"content"`
+	EditorID int       `json:"editor_id"`
+	Summary  string    `json:"summary"`
+	Created  time.Time `json:"created"`
 }
 
 // AService connection holder
@@ -26,12 +143,32 @@ type AService struct {
 type AServiceInterface interface {
 	AddAnswer(a Answer) (Answer, error)
 	DeleteAnswerByID(a Answer) error
-	DeleteAnswerByAuthorID(a Answer) error
-	DeleteAnswerByQuestionID(a Answer) error
-	GetAnswerByID(aID int) (Answer, error)
-	GetAnswersByAuthorID(aAuthorID int, limit int, offset int) ([]Answer, error)
-	GetAnswersByQuestionID(aQuestionID int, limit int, offset int) ([]Answer, error)
-	UpdateAnswer(a Answer) (Answer, error)
+	DeleteAnswerByAuthorID(a Answer) (int, error)
+	DeleteAnswerByQuestionID(a Answer) (int, error)
+	RestoreAnswer(a Answer) (Answer, error)
+	PurgeDeleted(before time.Time) (int, error)
+	GetAnswerByID(aID int, includeDeleted bool) (Answer, error)
+	GetAnswersByAuthorID(aAuthorID int, q AnswersQuery) (AnswersPage, error)
+	GetAnswersByQuestionID(aQuestionID int, q AnswersQuery) (AnswersPage, error)
+	SearchAnswers(q SearchQuery) ([]SearchResult, error)
+	UpdateAnswer(a Answer) (BestAnswer, error)
+	UnmarkBestAnswer(a Answer) (Answer, error)
+	EditAnswer(r Revision) (Answer, error)
+	RollbackAnswer(r Revision) (Answer, error)
+	GetRevisions(aID int) ([]Revision, error)
+	GetRevision(aID int, revision int) (Revision, error)
+	VoteAnswer(v Vote) (Answer, error)
+	AddAPIKey(k APIKey) (APIKey, error)
+	GetAPIKeys() ([]APIKey, error)
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	RevokeAPIKey(id int) error
 	GetUsageStatistic(host string) (ServiceStatus, error)
-	LogStat(request []byte, responseStatus int, responseError string)
+	LogStats(stats []RequestInfo) error
+	GetRouteStats(since time.Time) ([]RouteStat, error)
+	RollupStats(until time.Time) (int, error)
+	PurgeStats(before time.Time) (int, error)
+	ClaimEvents(limit int, lease time.Duration) ([]Event, error)
+	MarkEventsPublished(ids []string) error
+	RetryEvent(id string, at time.Time, reason string) error
+	PurgeEvents(before time.Time) (int, error)
 }
@@ -0,0 +1,1118 @@
+// Package modeltest provides conformance tests for model.AServiceInterface implementations
+package modeltest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+// Factory returns new empty service for every test case
+type Factory func(t *testing.T) model.AServiceInterface
+
+var suite = []struct {
+	name string
+	test func(t *testing.T, s model.AServiceInterface)
+}{
+	{"AddAnswer", testAddAnswer},
+	{"AddAnswerSequence", testAddAnswerSequence},
+	{"GetAnswerByID", testGetAnswerByID},
+	{"GetAnswerByIDNotFound", testGetAnswerByIDNotFound},
+	{"GetAnswersOrder", testGetAnswersOrder},
+	{"GetAnswersEmpty", testGetAnswersEmpty},
+	{"GetAnswersCursor", testGetAnswersCursor},
+	{"GetAnswersCursorStable", testGetAnswersCursorStable},
+	{"GetAnswersCursorOtherOrder", testGetAnswersCursorOtherOrder},
+	{"GetAnswersNegativeLimit", testGetAnswersNegativeLimit},
+	{"GetAnswersOrders", testGetAnswersOrders},
+	{"GetAnswersFilters", testGetAnswersFilters},
+	{"DeleteAnswerByID", testDeleteAnswerByID},
+	{"DeleteAnswerByIDNotFound", testDeleteAnswerByIDNotFound},
+	{"DeleteAnswerByAuthorID", testDeleteAnswerByAuthorID},
+	{"DeleteAnswerByQuestionID", testDeleteAnswerByQuestionID},
+	{"DeleteAnswerByAuthorIDNoMatch", testDeleteAnswerByAuthorIDNoMatch},
+	{"DeleteAnswerByQuestionIDNoMatch", testDeleteAnswerByQuestionIDNoMatch},
+	{"DeleteAnswerIncludeDeleted", testDeleteAnswerIncludeDeleted},
+	{"DeleteAnswerTwice", testDeleteAnswerTwice},
+	{"DeletedAnswerIsReadOnly", testDeletedAnswerIsReadOnly},
+	{"RestoreAnswer", testRestoreAnswer},
+	{"RestoreAnswerNotDeleted", testRestoreAnswerNotDeleted},
+	{"PurgeDeleted", testPurgeDeleted},
+	{"UpdateAnswer", testUpdateAnswer},
+	{"UpdateAnswerNotFound", testUpdateAnswerNotFound},
+	{"UpdateAnswerSingleBest", testUpdateAnswerSingleBest},
+	{"UnmarkBestAnswer", testUnmarkBestAnswer},
+	{"UnmarkBestAnswerNotFound", testUnmarkBestAnswerNotFound},
+	{"EditAnswer", testEditAnswer},
+	{"EditAnswerNotFound", testEditAnswerNotFound},
+	{"RollbackAnswer", testRollbackAnswer},
+	{"RollbackAnswerUnknownRevision", testRollbackAnswerUnknownRevision},
+	{"RevisionsNotFound", testRevisionsNotFound},
+	{"RevisionsHiddenWithAnswer", testRevisionsHiddenWithAnswer},
+	{"VoteAnswer", testVoteAnswer},
+	{"VoteAnswerNotFound", testVoteAnswerNotFound},
+	{"GetAnswersOrderByScore", testGetAnswersOrderByScore},
+	{"SearchAnswers", testSearchAnswers},
+	{"SearchAnswersFilters", testSearchAnswersFilters},
+	{"UsageStatisticEmpty", testUsageStatisticEmpty},
+	{"UsageStatistic", testUsageStatistic},
+	{"RouteStats", testRouteStats},
+	{"APIKeys", testAPIKeys},
+	{"RevokeAPIKey", testRevokeAPIKey},
+	{"APIKeyUsage", testAPIKeyUsage},
+	{"StatsRollup", testStatsRollup},
+	{"Events", testEvents},
+	{"EventsRetry", testEventsRetry},
+	{"PurgeEvents", testPurgeEvents},
+}
+
+// Run runs whole conformance suite against services built by factory
+func Run(t *testing.T, factory Factory) {
+	for _, tc := range suite {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, factory(t))
+		})
+	}
+}
+
+func newAnswer(questionID int, authorID int, content string) model.Answer {
+	return model.Answer{
+		QuestionID:     questionID,
+		AuthorID:       authorID,
+		AuthorNickname: "Test",
+		Content:        &content,
+	}
+}
+
+func mustAdd(t *testing.T, s model.AServiceInterface, a model.Answer) model.Answer {
+	created, err := s.AddAnswer(a)
+	if err != nil {
+		t.Fatalf("unable to add answer: %s", err.Error())
+	}
+	return created
+}
+
+func ids(answers []model.Answer) []int {
+	res := make([]int, 0, len(answers))
+	for _, a := range answers {
+		res = append(res, a.ID)
+	}
+	return res
+}
+
+func testAddAnswer(t *testing.T, s model.AServiceInterface) {
+	before := time.Now().Add(-time.Minute)
+	created, err := s.AddAnswer(newAnswer(1, 2, "My Answer Content"))
+	if assert.Nil(t, err) {
+		assert.True(t, created.ID > 0)
+		assert.Equal(t, 1, created.QuestionID)
+		assert.Equal(t, 2, created.AuthorID)
+		assert.Equal(t, "Test", created.AuthorNickname)
+		assert.Equal(t, "My Answer Content", *created.Content)
+		if assert.NotNil(t, created.IsBest) {
+			assert.False(t, *created.IsBest)
+		}
+		assert.True(t, created.Created.After(before))
+	}
+}
+
+func testAddAnswerSequence(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "first"))
+	second := mustAdd(t, s, newAnswer(1, 1, "second"))
+	assert.True(t, second.ID > first.ID)
+}
+
+func testGetAnswerByID(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 2, "My Answer Content"))
+
+	a, err := s.GetAnswerByID(created.ID, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, created.ID, a.ID)
+		assert.Equal(t, created.QuestionID, a.QuestionID)
+		assert.Equal(t, created.AuthorID, a.AuthorID)
+		assert.Equal(t, created.AuthorNickname, a.AuthorNickname)
+		assert.Equal(t, *created.Content, *a.Content)
+		assert.Equal(t, *created.IsBest, *a.IsBest)
+		assert.True(t, created.Created.Equal(a.Created))
+	}
+}
+
+func testGetAnswerByIDNotFound(t *testing.T, s model.AServiceInterface) {
+	_, err := s.GetAnswerByID(1, false)
+	assert.Equal(t, ui.ErrNoResult, err)
+}
+
+func testGetAnswersOrder(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "first"))
+	other := mustAdd(t, s, newAnswer(2, 2, "other"))
+	second := mustAdd(t, s, newAnswer(1, 1, "second"))
+
+	byQuestion, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{first.ID, second.ID}, ids(byQuestion.Answers))
+	}
+
+	byAuthor, err := s.GetAnswersByAuthorID(2, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{other.ID}, ids(byAuthor.Answers))
+	}
+}
+
+func testGetAnswersEmpty(t *testing.T, s model.AServiceInterface) {
+	byQuestion, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, make([]model.Answer, 0), byQuestion.Answers)
+	}
+
+	byAuthor, err := s.GetAnswersByAuthorID(1, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, make([]model.Answer, 0), byAuthor.Answers)
+	}
+}
+
+// walk follows next cursors from the first page and returns ids of all the visited pages
+func walk(t *testing.T, list func(page model.Page) (model.AnswersPage, error), limit int) [][]int {
+	pages := make([][]int, 0)
+	page := model.Page{Limit: limit}
+	for {
+		p, err := list(page)
+		if err != nil {
+			t.Fatalf("unable to get answers: %s", err.Error())
+		}
+		pages = append(pages, ids(p.Answers))
+		if p.Next == nil {
+			return pages
+		}
+		if len(pages) > 100 {
+			t.Fatal("pages never end")
+		}
+		page.Cursor = p.Next
+	}
+}
+
+func testGetAnswersCursor(t *testing.T, s model.AServiceInterface) {
+	created := make([]int, 0)
+	for i := 0; i < 25; i++ {
+		created = append(created, mustAdd(t, s, newAnswer(1, 1, "content")).ID)
+	}
+	byQuestion := func(page model.Page) (model.AnswersPage, error) {
+		return s.GetAnswersByQuestionID(1, model.AnswersQuery{Page: page})
+	}
+	byAuthor := func(page model.Page) (model.AnswersPage, error) {
+		return s.GetAnswersByAuthorID(1, model.AnswersQuery{Page: page})
+	}
+
+	assert.Equal(t, [][]int{created[:20], created[20:]}, walk(t, byQuestion, 0), "default limit is 20")
+	assert.Equal(t, [][]int{created[:10], created[10:20], created[20:]}, walk(t, byAuthor, 10))
+	assert.Equal(t, [][]int{created[:25]}, walk(t, byAuthor, 25), "no next page when everything fits")
+
+	first, err := byQuestion(model.Page{Limit: 10})
+	if assert.Nil(t, err) {
+		assert.Nil(t, first.Prev, "first page has no previous one")
+		assert.Nil(t, first.Total, "total is counted on request only")
+	}
+
+	second, err := byQuestion(model.Page{Limit: 10, Cursor: first.Next, WithTotal: true})
+	if assert.Nil(t, err) && assert.NotNil(t, second.Prev) && assert.NotNil(t, second.Total) {
+		assert.Equal(t, created[10:20], ids(second.Answers))
+		assert.Equal(t, 25, *second.Total)
+
+		back, err := byQuestion(model.Page{Limit: 10, Cursor: second.Prev})
+		if assert.Nil(t, err) {
+			assert.Equal(t, created[:10], ids(back.Answers))
+			assert.Nil(t, back.Prev)
+			if assert.NotNil(t, back.Next) {
+				assert.Equal(t, *first.Next, *back.Next)
+			}
+		}
+	}
+}
+
+func testGetAnswersCursorStable(t *testing.T, s model.AServiceInterface) {
+	created := make([]int, 0)
+	for i := 0; i < 6; i++ {
+		created = append(created, mustAdd(t, s, newAnswer(1, 1, "content")).ID)
+	}
+
+	first, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{Page: model.Page{Limit: 3}})
+	if !assert.Nil(t, err) || !assert.NotNil(t, first.Next) {
+		return
+	}
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created[0]}))
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+
+	second, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{Page: model.Page{Limit: 3, Cursor: first.Next}})
+	if assert.Nil(t, err) {
+		assert.Equal(t, created[3:6], ids(second.Answers), "changes before cursor don't shift the page")
+	}
+}
+
+func testGetAnswersCursorOtherOrder(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+
+	first, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{Page: model.Page{Limit: 1}})
+	if assert.Nil(t, err) && assert.NotNil(t, first.Next) {
+		_, err = s.GetAnswersByQuestionID(1, model.AnswersQuery{Order: model.OrderByScore, Page: model.Page{Limit: 1, Cursor: first.Next}})
+		assert.Equal(t, ui.ErrInvalidParameter, err)
+	}
+}
+
+func testGetAnswersNegativeLimit(t *testing.T, s model.AServiceInterface) {
+	created := make([]int, 0)
+	for i := 0; i < 21; i++ {
+		created = append(created, mustAdd(t, s, newAnswer(1, 1, "content")).ID)
+	}
+
+	page, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{Page: model.Page{Limit: -1}})
+	if assert.Nil(t, err) {
+		assert.Equal(t, created[:20], ids(page.Answers), "negative limit is default")
+	}
+
+	page, err = s.GetAnswersByAuthorID(1, model.AnswersQuery{Page: model.Page{Limit: -5}})
+	if assert.Nil(t, err) {
+		assert.Equal(t, created[:20], ids(page.Answers), "negative limit is default")
+	}
+}
+
+func testGetAnswersOrders(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "first")).ID
+	second := mustAdd(t, s, newAnswer(1, 1, "second")).ID
+	third := mustAdd(t, s, newAnswer(1, 1, "third")).ID
+	isBest := true
+	if _, err := s.UpdateAnswer(model.Answer{ID: second, IsBest: &isBest}); err != nil {
+		t.Fatalf("unable to mark best answer: %s", err.Error())
+	}
+
+	orders := map[model.AnswersOrder][]int{
+		model.OrderByBest:   {second, first, third},
+		model.OrderByNewest: {third, second, first},
+		model.OrderByOldest: {first, second, third},
+	}
+	for order, expected := range orders {
+		list := func(page model.Page) (model.AnswersPage, error) {
+			return s.GetAnswersByQuestionID(1, model.AnswersQuery{Order: order, Page: page})
+		}
+		assert.Equal(t, [][]int{expected}, walk(t, list, 0), string(order))
+		assert.Equal(t, [][]int{expected[:1], expected[1:2], expected[2:]}, walk(t, list, 1), string(order))
+
+		byAuthor := func(page model.Page) (model.AnswersPage, error) {
+			return s.GetAnswersByAuthorID(1, model.AnswersQuery{Order: order, Page: page})
+		}
+		assert.Equal(t, [][]int{expected[:2], expected[2:]}, walk(t, byAuthor, 2), string(order))
+	}
+}
+
+func testGetAnswersFilters(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "first"))
+	second := mustAdd(t, s, newAnswer(1, 2, "second"))
+	third := mustAdd(t, s, newAnswer(1, 1, "third"))
+	mustAdd(t, s, newAnswer(2, 1, "other question"))
+	isBest, notBest, author := true, false, 1
+	if _, err := s.UpdateAnswer(model.Answer{ID: third.ID, IsBest: &isBest}); err != nil {
+		t.Fatalf("unable to mark best answer: %s", err.Error())
+	}
+
+	queries := []struct {
+		name     string
+		q        model.AnswersQuery
+		expected []int
+	}{
+		{"best", model.AnswersQuery{IsBest: &isBest}, []int{third.ID}},
+		{"not best", model.AnswersQuery{IsBest: &notBest}, []int{first.ID, second.ID}},
+		{"author", model.AnswersQuery{AuthorID: &author}, []int{first.ID, third.ID}},
+		{"created from", model.AnswersQuery{CreatedFrom: &second.Created}, []int{second.ID, third.ID}},
+		{"created to", model.AnswersQuery{CreatedTo: &second.Created}, []int{first.ID}},
+		{"created range", model.AnswersQuery{CreatedFrom: &second.Created, CreatedTo: &third.Created}, []int{second.ID}},
+		{"combined", model.AnswersQuery{AuthorID: &author, IsBest: &notBest, Order: model.OrderByNewest}, []int{first.ID}},
+	}
+	for _, tc := range queries {
+		page, err := s.GetAnswersByQuestionID(1, tc.q)
+		if assert.Nil(t, err, tc.name) {
+			assert.Equal(t, tc.expected, ids(page.Answers), tc.name)
+		}
+	}
+
+	page, err := s.GetAnswersByAuthorID(1, model.AnswersQuery{IsBest: &notBest, Page: model.Page{WithTotal: true}})
+	if assert.Nil(t, err) && assert.NotNil(t, page.Total) {
+		assert.Equal(t, 2, *page.Total, "total counts filtered answers")
+	}
+}
+
+func testDeleteAnswerByID(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	kept := mustAdd(t, s, newAnswer(1, 1, "content"))
+
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created.ID}))
+	_, err := s.GetAnswerByID(created.ID, false)
+	assert.Equal(t, ui.ErrNoResult, err)
+	_, err = s.GetAnswerByID(kept.ID, false)
+	assert.Nil(t, err)
+}
+
+func testDeleteAnswerByIDNotFound(t *testing.T, s model.AServiceInterface) {
+	assert.Equal(t, ui.ErrNoDataToDelete, s.DeleteAnswerByID(model.Answer{ID: 1}))
+}
+
+func testDeleteAnswerByAuthorID(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+	mustAdd(t, s, newAnswer(2, 1, "content"))
+	kept := mustAdd(t, s, newAnswer(1, 2, "content"))
+
+	n, err := s.DeleteAnswerByAuthorID(model.Answer{AuthorID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	left, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{kept.ID}, ids(left.Answers))
+	}
+}
+
+func testDeleteAnswerByQuestionID(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+	mustAdd(t, s, newAnswer(1, 2, "content"))
+	kept := mustAdd(t, s, newAnswer(2, 1, "content"))
+
+	n, err := s.DeleteAnswerByQuestionID(model.Answer{QuestionID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	left, err := s.GetAnswersByAuthorID(1, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{kept.ID}, ids(left.Answers))
+	}
+}
+
+func testDeleteAnswerByAuthorIDNoMatch(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+	n, err := s.DeleteAnswerByAuthorID(model.Answer{AuthorID: 2})
+	assert.Nil(t, err, "bulk delete of nothing is not an error")
+	assert.Equal(t, 0, n)
+}
+
+func testDeleteAnswerByQuestionIDNoMatch(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "content"))
+	n, err := s.DeleteAnswerByQuestionID(model.Answer{QuestionID: 2})
+	assert.Nil(t, err, "bulk delete of nothing is not an error")
+	assert.Equal(t, 0, n)
+}
+
+func testDeleteAnswerIncludeDeleted(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	kept := mustAdd(t, s, newAnswer(1, 1, "content"))
+	admin := 7
+
+	before := time.Now().Add(-time.Minute)
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created.ID, DeletedBy: &admin}))
+
+	a, err := s.GetAnswerByID(created.ID, true)
+	if assert.Nil(t, err) && assert.NotNil(t, a.DeletedAt) && assert.NotNil(t, a.DeletedBy) {
+		assert.True(t, a.DeletedAt.After(before))
+		assert.Equal(t, admin, *a.DeletedBy)
+	}
+
+	byQuestion, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{IncludeDeleted: true})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{created.ID, kept.ID}, ids(byQuestion.Answers))
+	}
+	byAuthor, err := s.GetAnswersByAuthorID(1, model.AnswersQuery{IncludeDeleted: true})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{created.ID, kept.ID}, ids(byAuthor.Answers))
+	}
+	byAuthor, err = s.GetAnswersByAuthorID(1, model.AnswersQuery{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{kept.ID}, ids(byAuthor.Answers))
+	}
+}
+
+func testDeleteAnswerTwice(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	first, second := 1, 2
+
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created.ID, DeletedBy: &first}))
+	assert.Equal(t, ui.ErrNoDataToDelete, s.DeleteAnswerByID(model.Answer{ID: created.ID, DeletedBy: &second}))
+	n, err := s.DeleteAnswerByQuestionID(model.Answer{QuestionID: 1, DeletedBy: &second})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n, "answers already in trash are not counted")
+
+	a, err := s.GetAnswerByID(created.ID, true)
+	if assert.Nil(t, err) && assert.NotNil(t, a.DeletedBy) {
+		assert.Equal(t, first, *a.DeletedBy, "answer already in trash keeps who deleted it")
+	}
+}
+
+func testDeletedAnswerIsReadOnly(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created.ID}))
+	isBest := true
+
+	_, err := s.UpdateAnswer(model.Answer{ID: created.ID, IsBest: &isBest})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+	_, err = s.UnmarkBestAnswer(model.Answer{ID: created.ID})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+	_, err = s.EditAnswer(edit(created.ID, "changed", 1, ""))
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+	_, err = s.VoteAnswer(model.Vote{AnswerID: created.ID, VoterID: 2, Value: 1})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func testRestoreAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 2, Value: 1})
+	admin := 7
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created.ID, DeletedBy: &admin}))
+
+	restored, err := s.RestoreAnswer(model.Answer{ID: created.ID})
+	if assert.Nil(t, err) {
+		assert.Equal(t, created.ID, restored.ID)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Nil(t, restored.DeletedBy)
+		assert.Equal(t, 1, restored.Score, "votes survive trash")
+	}
+
+	_, err = s.GetAnswerByID(created.ID, false)
+	assert.Nil(t, err)
+	revisions, err := s.GetRevisions(created.ID)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, len(revisions))
+	}
+}
+
+func testRestoreAnswerNotDeleted(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+
+	_, err := s.RestoreAnswer(model.Answer{ID: created.ID})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+	_, err = s.RestoreAnswer(model.Answer{ID: created.ID + 1})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func testPurgeDeleted(t *testing.T, s model.AServiceInterface) {
+	purged := mustAdd(t, s, newAnswer(1, 1, "content"))
+	kept := mustAdd(t, s, newAnswer(1, 1, "content"))
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: purged.ID}))
+
+	n, err := s.PurgeDeleted(time.Now().Add(-time.Hour))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, n, "fresh trash is kept")
+	}
+
+	n, err = s.PurgeDeleted(time.Now().Add(time.Hour))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, n)
+	}
+	_, err = s.GetAnswerByID(purged.ID, true)
+	assert.Equal(t, ui.ErrNoResult, err)
+	_, err = s.RestoreAnswer(model.Answer{ID: purged.ID})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+	_, err = s.GetAnswerByID(kept.ID, false)
+	assert.Nil(t, err)
+}
+
+func testUpdateAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	isBest := true
+
+	updated, err := s.UpdateAnswer(model.Answer{ID: created.ID, IsBest: &isBest})
+	if assert.Nil(t, err) {
+		assert.Equal(t, created.ID, updated.ID)
+		assert.Equal(t, "content", *updated.Content)
+		assert.True(t, *updated.IsBest)
+	}
+
+	stored, err := s.GetAnswerByID(created.ID, false)
+	if assert.Nil(t, err) {
+		assert.True(t, *stored.IsBest)
+	}
+}
+
+func bestIDs(t *testing.T, s model.AServiceInterface, questionID int) []int {
+	answers, err := s.GetAnswersByQuestionID(questionID, model.AnswersQuery{})
+	if err != nil {
+		t.Fatalf("unable to get answers: %s", err.Error())
+	}
+	best := make([]int, 0)
+	for _, a := range answers.Answers {
+		if *a.IsBest {
+			best = append(best, a.ID)
+		}
+	}
+	return best
+}
+
+func testUpdateAnswerSingleBest(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "first"))
+	second := mustAdd(t, s, newAnswer(1, 2, "second"))
+	other := mustAdd(t, s, newAnswer(2, 1, "other question"))
+
+	best, err := s.UpdateAnswer(model.Answer{ID: other.ID})
+	if assert.Nil(t, err) {
+		assert.Nil(t, best.PreviousBestID)
+	}
+	best, err = s.UpdateAnswer(model.Answer{ID: first.ID})
+	if assert.Nil(t, err) {
+		assert.Nil(t, best.PreviousBestID, "best answer of other question is kept")
+	}
+
+	best, err = s.UpdateAnswer(model.Answer{ID: second.ID})
+	if assert.Nil(t, err) {
+		assert.Equal(t, second.ID, best.ID)
+		assert.True(t, *best.IsBest)
+		if assert.NotNil(t, best.PreviousBestID) {
+			assert.Equal(t, first.ID, *best.PreviousBestID)
+		}
+	}
+	assert.Equal(t, []int{second.ID}, bestIDs(t, s, 1))
+	assert.Equal(t, []int{other.ID}, bestIDs(t, s, 2))
+
+	best, err = s.UpdateAnswer(model.Answer{ID: second.ID})
+	if assert.Nil(t, err) {
+		assert.Nil(t, best.PreviousBestID, "marking best answer again changes nothing")
+	}
+}
+
+func testUnmarkBestAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	_, err := s.UpdateAnswer(model.Answer{ID: created.ID})
+	assert.Nil(t, err)
+
+	unmarked, err := s.UnmarkBestAnswer(model.Answer{ID: created.ID})
+	if assert.Nil(t, err) {
+		assert.False(t, *unmarked.IsBest)
+	}
+	assert.Equal(t, []int{}, bestIDs(t, s, 1))
+
+	unmarked, err = s.UnmarkBestAnswer(model.Answer{ID: created.ID})
+	if assert.Nil(t, err, "unmarking is idempotent") {
+		assert.False(t, *unmarked.IsBest)
+	}
+}
+
+func testUnmarkBestAnswerNotFound(t *testing.T, s model.AServiceInterface) {
+	_, err := s.UnmarkBestAnswer(model.Answer{ID: 1})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func testUpdateAnswerNotFound(t *testing.T, s model.AServiceInterface) {
+	isBest := true
+	_, err := s.UpdateAnswer(model.Answer{ID: 1, IsBest: &isBest})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func edit(answerID int, content string, editorID int, summary string) model.Revision {
+	return model.Revision{
+		AnswerID: answerID,
+		Content:  &content,
+		EditorID: editorID,
+		Summary:  summary,
+	}
+}
+
+func testEditAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "first"))
+	assert.Equal(t, 1, created.Revision)
+	assert.True(t, created.Created.Equal(created.Updated))
+
+	edited, err := s.EditAnswer(edit(created.ID, "second", 2, "typo"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, created.ID, edited.ID)
+		assert.Equal(t, "second", *edited.Content)
+		assert.Equal(t, 2, edited.Revision)
+		assert.Equal(t, created.AuthorID, edited.AuthorID)
+		assert.False(t, edited.Updated.Before(created.Updated))
+	}
+
+	revisions, err := s.GetRevisions(created.ID)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(revisions)) {
+		assert.Equal(t, 1, revisions[0].Revision)
+		assert.Equal(t, "first", *revisions[0].Content)
+		assert.Equal(t, created.AuthorID, revisions[0].EditorID)
+		assert.Equal(t, "", revisions[0].Summary)
+		assert.Equal(t, 2, revisions[1].Revision)
+		assert.Equal(t, "second", *revisions[1].Content)
+		assert.Equal(t, 2, revisions[1].EditorID)
+		assert.Equal(t, "typo", revisions[1].Summary)
+	}
+
+	revision, err := s.GetRevision(created.ID, 1)
+	if assert.Nil(t, err) {
+		assert.Equal(t, created.ID, revision.AnswerID)
+		assert.Equal(t, "first", *revision.Content)
+	}
+
+	_, err = s.GetRevision(created.ID, 3)
+	assert.Equal(t, ui.ErrNoResult, err)
+}
+
+func testEditAnswerNotFound(t *testing.T, s model.AServiceInterface) {
+	_, err := s.EditAnswer(edit(1, "content", 1, ""))
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func testRollbackAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "first"))
+	_, err := s.EditAnswer(edit(created.ID, "second", 1, ""))
+	assert.Nil(t, err)
+
+	restored, err := s.RollbackAnswer(model.Revision{AnswerID: created.ID, Revision: 1, EditorID: 3})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "first", *restored.Content)
+		assert.Equal(t, 3, restored.Revision)
+	}
+
+	revision, err := s.GetRevision(created.ID, 3)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "first", *revision.Content)
+		assert.Equal(t, 3, revision.EditorID)
+		assert.Equal(t, "Rollback to revision 1", revision.Summary)
+	}
+}
+
+func testRollbackAnswerUnknownRevision(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "first"))
+
+	_, err := s.RollbackAnswer(model.Revision{AnswerID: created.ID, Revision: 2, EditorID: 1})
+	assert.Equal(t, ui.ErrNoResult, err)
+
+	_, err = s.RollbackAnswer(model.Revision{AnswerID: created.ID + 1, Revision: 1, EditorID: 1})
+	assert.Equal(t, ui.ErrNoResult, err)
+}
+
+func testRevisionsNotFound(t *testing.T, s model.AServiceInterface) {
+	_, err := s.GetRevisions(1)
+	assert.Equal(t, ui.ErrNoResult, err)
+
+	_, err = s.GetRevision(1, 1)
+	assert.Equal(t, ui.ErrNoResult, err)
+}
+
+func testRevisionsHiddenWithAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "first"))
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: created.ID}))
+
+	_, err := s.GetRevisions(created.ID)
+	assert.Equal(t, ui.ErrNoResult, err)
+}
+
+func mustVote(t *testing.T, s model.AServiceInterface, v model.Vote) model.Answer {
+	a, err := s.VoteAnswer(v)
+	if err != nil {
+		t.Fatalf("unable to vote: %s", err.Error())
+	}
+	return a
+}
+
+func testVoteAnswer(t *testing.T, s model.AServiceInterface) {
+	created := mustAdd(t, s, newAnswer(1, 1, "content"))
+	assert.Equal(t, 0, created.Score)
+
+	assert.Equal(t, 1, mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 2, Value: 1}).Score)
+	assert.Equal(t, 1, mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 2, Value: 1}).Score, "repeated vote is counted once")
+	assert.Equal(t, 2, mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 3, Value: 1}).Score)
+	assert.Equal(t, 0, mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 2, Value: -1}).Score, "vote is changed")
+	assert.Equal(t, 1, mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 2, Value: 0}).Score, "vote is retracted")
+	assert.Equal(t, 1, mustVote(t, s, model.Vote{AnswerID: created.ID, VoterID: 4, Value: 0}).Score, "retract without vote")
+
+	stored, err := s.GetAnswerByID(created.ID, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, stored.Score)
+	}
+}
+
+func testVoteAnswerNotFound(t *testing.T, s model.AServiceInterface) {
+	_, err := s.VoteAnswer(model.Vote{AnswerID: 1, VoterID: 1, Value: 1})
+	assert.Equal(t, ui.ErrNoDataToUpdate, err)
+}
+
+func testGetAnswersOrderByScore(t *testing.T, s model.AServiceInterface) {
+	low := mustAdd(t, s, newAnswer(1, 1, "low"))
+	zero := mustAdd(t, s, newAnswer(1, 1, "zero"))
+	high := mustAdd(t, s, newAnswer(1, 1, "high"))
+	zeroToo := mustAdd(t, s, newAnswer(1, 1, "zero too"))
+	mustVote(t, s, model.Vote{AnswerID: low.ID, VoterID: 2, Value: -1})
+	mustVote(t, s, model.Vote{AnswerID: high.ID, VoterID: 2, Value: 1})
+
+	page, err := s.GetAnswersByQuestionID(1, model.AnswersQuery{Order: model.OrderByScore})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{high.ID, zero.ID, zeroToo.ID, low.ID}, ids(page.Answers))
+	}
+
+	byScore := func(page model.Page) (model.AnswersPage, error) {
+		return s.GetAnswersByQuestionID(1, model.AnswersQuery{Order: model.OrderByScore, Page: page})
+	}
+	assert.Equal(t, [][]int{{high.ID, zero.ID}, {zeroToo.ID, low.ID}}, walk(t, byScore, 2))
+	assert.Equal(t, [][]int{{high.ID}, {zero.ID}, {zeroToo.ID}, {low.ID}}, walk(t, byScore, 1), "equal scores are split by id")
+
+	last, err := byScore(model.Page{Limit: 3, Cursor: &model.Cursor{Order: model.OrderByScore, ID: zero.ID, Score: 0}})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{zeroToo.ID, low.ID}, ids(last.Answers))
+		if assert.NotNil(t, last.Prev) {
+			back, err := byScore(model.Page{Limit: 3, Cursor: last.Prev})
+			if assert.Nil(t, err) {
+				assert.Equal(t, []int{high.ID, zero.ID}, ids(back.Answers))
+			}
+		}
+	}
+}
+
+func searchIDs(results []model.SearchResult) []int {
+	res := make([]int, 0, len(results))
+	for _, r := range results {
+		res = append(res, r.ID)
+	}
+	return res
+}
+
+func testSearchAnswers(t *testing.T, s model.AServiceInterface) {
+	rare := mustAdd(t, s, newAnswer(1, 1, "Use channels when goroutines talk to each other and share nothing else at all"))
+	dense := mustAdd(t, s, newAnswer(2, 1, "Goroutines and channels: channels connect goroutines"))
+	mustAdd(t, s, newAnswer(1, 1, "Goroutines are cheap"))
+	deleted := mustAdd(t, s, newAnswer(1, 1, "channels and goroutines"))
+	assert.Nil(t, s.DeleteAnswerByID(model.Answer{ID: deleted.ID}))
+
+	found, err := s.SearchAnswers(model.SearchQuery{Text: "CHANNELS goroutines"})
+	if assert.Nil(t, err) && assert.Equal(t, []int{dense.ID, rare.ID}, searchIDs(found), "all the words are required, denser match first") {
+		assert.True(t, found[0].Rank > found[1].Rank)
+		assert.Equal(t, "<b>Goroutines</b> and <b>channels</b>: <b>channels</b> connect <b>goroutines</b>", found[0].Snippet)
+		assert.Equal(t, *dense.Content, *found[0].Content)
+	}
+
+	found, err = s.SearchAnswers(model.SearchQuery{Text: "mutex"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, make([]model.SearchResult, 0), found)
+	}
+
+	found, err = s.SearchAnswers(model.SearchQuery{Text: "goroutines", Limit: 1})
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, len(found))
+	}
+}
+
+func testSearchAnswersFilters(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "select statement"))
+	second := mustAdd(t, s, newAnswer(1, 2, "select statement"))
+	third := mustAdd(t, s, newAnswer(2, 2, "select statement"))
+	question, author := 1, 2
+
+	found, err := s.SearchAnswers(model.SearchQuery{Text: "select", QuestionID: &question})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{first.ID, second.ID}, searchIDs(found), "equal ranks are ordered by id")
+	}
+
+	found, err = s.SearchAnswers(model.SearchQuery{Text: "select", AuthorID: &author})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{second.ID, third.ID}, searchIDs(found))
+	}
+
+	found, err = s.SearchAnswers(model.SearchQuery{Text: "select", QuestionID: &question, AuthorID: &author})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []int{second.ID}, searchIDs(found))
+	}
+}
+
+func testUsageStatisticEmpty(t *testing.T, s model.AServiceInterface) {
+	stat, err := s.GetUsageStatistic("localhost")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "localhost", stat.Address)
+		assert.Equal(t, 0, stat.RequestsCount)
+		assert.Equal(t, model.NotUsedYet, stat.LastUsage.Request)
+		assert.True(t, stat.LastUsage.RequestTime.IsZero())
+		assert.Equal(t, 0, stat.LastUsage.ResponseStatus)
+		assert.Equal(t, "", stat.LastUsage.ResponseErrorText)
+	}
+}
+
+func testUsageStatistic(t *testing.T, s model.AServiceInterface) {
+	now := time.Now()
+	assert.Nil(t, s.LogStats(nil))
+	assert.Nil(t, s.LogStats([]model.RequestInfo{
+		{Request: "/answer", RequestTime: now, ResponseStatus: 201},
+		{Request: "/best", RequestTime: now, ResponseStatus: 404, ResponseErrorText: ui.ErrNoDataToUpdate.Error(), RequestID: "req-2"},
+	}))
+
+	stat, err := s.GetUsageStatistic("localhost")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "localhost", stat.Address)
+		assert.Equal(t, 2, stat.RequestsCount)
+		assert.Equal(t, "/best", stat.LastUsage.Request)
+		assert.Equal(t, 404, stat.LastUsage.ResponseStatus)
+		assert.Equal(t, ui.ErrNoDataToUpdate.Error(), stat.LastUsage.ResponseErrorText)
+		assert.Equal(t, "req-2", stat.LastUsage.RequestID)
+		assert.False(t, stat.LastUsage.RequestTime.IsZero())
+	}
+}
+
+func testRouteStats(t *testing.T, s model.AServiceInterface) {
+	now := time.Now()
+	stat := func(method, route string, status int, duration float64, age time.Duration) model.RequestInfo {
+		return model.RequestInfo{Request: route, RequestTime: now.Add(-age), ResponseStatus: status,
+			Method: method, Route: route, Duration: duration, Client: "ip:127.0.0.1"}
+	}
+	assert.Nil(t, s.LogStats([]model.RequestInfo{
+		stat("GET", "/answer/id:id", 200, 1, time.Minute),
+		stat("GET", "/answer/id:id", 200, 2, time.Minute),
+		stat("GET", "/answer/id:id", 404, 3, time.Minute),
+		stat("GET", "/answer/id:id", 500, 4, time.Minute),
+		stat("GET", "/answer/id:id", 200, 100, 2*time.Hour),
+		stat("PUT", "/answer", 201, 10, time.Minute),
+		{Request: "/answer/id1", RequestTime: now, ResponseStatus: 200},
+	}))
+
+	routes, err := s.GetRouteStats(now.Add(-time.Hour))
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(routes)) {
+		assert.Equal(t, model.RouteStat{Method: "PUT", Route: "/answer", Requests: 1, P50: 10, P95: 10, P99: 10}, routes[0])
+
+		get := routes[1]
+		assert.Equal(t, "GET", get.Method)
+		assert.Equal(t, "/answer/id:id", get.Route)
+		assert.Equal(t, 4, get.Requests, "older requests are out of window")
+		assert.Equal(t, 1, get.ClientErrors)
+		assert.Equal(t, 1, get.ServerErrors)
+		assert.InDelta(t, 0.25, get.ErrorRate, 1e-9)
+		assert.InDelta(t, 2.5, get.P50, 1e-9)
+		assert.InDelta(t, 3.85, get.P95, 1e-9)
+		assert.InDelta(t, 3.97, get.P99, 1e-9)
+	}
+
+	routes, err = s.GetRouteStats(now)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, len(routes), "requests without route are not reported")
+	}
+}
+
+func mustAddKey(t *testing.T, s model.AServiceInterface, name string, scopes ...string) model.APIKey {
+	k, err := s.AddAPIKey(model.APIKey{Name: name, Prefix: "ak_" + name, Hash: "hash-" + name, Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func testAPIKeys(t *testing.T, s model.AServiceInterface) {
+	gateway := mustAddKey(t, s, "gateway", "answers:read", "answers:write")
+	question := mustAddKey(t, s, "question", "answers:admin")
+	assert.NotEqual(t, gateway.ID, question.ID)
+	assert.False(t, gateway.Created.IsZero())
+
+	k, err := s.GetAPIKeyByHash("hash-gateway")
+	if assert.Nil(t, err) {
+		assert.Equal(t, gateway.ID, k.ID)
+		assert.Equal(t, "gateway", k.Name)
+		assert.Equal(t, "ak_gateway", k.Prefix)
+		assert.Equal(t, []string{"answers:read", "answers:write"}, k.Scopes)
+		assert.Nil(t, k.Revoked)
+	}
+
+	_, err = s.GetAPIKeyByHash("unknown")
+	assert.Equal(t, ui.ErrNoResult, err)
+
+	keys, err := s.GetAPIKeys()
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(keys)) {
+		assert.Equal(t, gateway.ID, keys[0].ID)
+		assert.Equal(t, question.ID, keys[1].ID)
+		assert.Equal(t, 0, keys[0].RequestsCount)
+		assert.Nil(t, keys[0].LastUsed)
+	}
+}
+
+func testRevokeAPIKey(t *testing.T, s model.AServiceInterface) {
+	k := mustAddKey(t, s, "gateway", "answers:read")
+
+	assert.Nil(t, s.RevokeAPIKey(k.ID))
+	assert.Equal(t, ui.ErrNoDataToUpdate, s.RevokeAPIKey(k.ID))
+	assert.Equal(t, ui.ErrNoDataToUpdate, s.RevokeAPIKey(k.ID+1))
+
+	_, err := s.GetAPIKeyByHash("hash-gateway")
+	assert.Equal(t, ui.ErrNoResult, err)
+
+	keys, err := s.GetAPIKeys()
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(keys)) {
+		assert.NotNil(t, keys[0].Revoked)
+	}
+}
+
+func testAPIKeyUsage(t *testing.T, s model.AServiceInterface) {
+	gateway := mustAddKey(t, s, "gateway", "answers:read")
+	mustAddKey(t, s, "question", "answers:read")
+
+	now := time.Now()
+	assert.Nil(t, s.LogStats([]model.RequestInfo{
+		{Request: "/answer/id1", RequestTime: now, ResponseStatus: 200, APIKeyID: gateway.ID},
+		{Request: "/answer/id2", RequestTime: now, ResponseStatus: 404, ResponseErrorText: ui.ErrNoResult.Error(), APIKeyID: gateway.ID},
+	}))
+	assert.Nil(t, s.LogStats([]model.RequestInfo{{Request: "/answer/id3", RequestTime: now, ResponseStatus: 200}}))
+
+	keys, err := s.GetAPIKeys()
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(keys)) {
+		assert.Equal(t, 2, keys[0].RequestsCount)
+		assert.NotNil(t, keys[0].LastUsed)
+		assert.Equal(t, 0, keys[1].RequestsCount)
+		assert.Nil(t, keys[1].LastUsed)
+	}
+
+	stat, err := s.GetUsageStatistic("localhost")
+	if assert.Nil(t, err) {
+		assert.Equal(t, 3, stat.RequestsCount)
+		assert.Equal(t, 0, stat.LastUsage.APIKeyID)
+	}
+}
+
+func testStatsRollup(t *testing.T, s model.AServiceInterface) {
+	key := mustAddKey(t, s, "gateway", "answers:read")
+	now := time.Now()
+	hour := now.Truncate(time.Hour)
+	stat := func(path string, at time.Time, status int, keyID int) model.RequestInfo {
+		return model.RequestInfo{Request: path, RequestTime: at, ResponseStatus: status, APIKeyID: keyID,
+			Method: "GET", Route: "/answer/id:id", Duration: 2}
+	}
+	assert.Nil(t, s.LogStats([]model.RequestInfo{
+		stat("/answer/id1", hour.Add(-2*time.Hour+time.Minute), 200, key.ID),
+		stat("/answer/id2", hour.Add(-2*time.Hour+2*time.Minute), 404, key.ID),
+		stat("/answer/id3", hour.Add(-30*time.Minute), 200, 0),
+		stat("/answer/id4", now, 500, key.ID),
+	}))
+
+	n, err := s.RollupStats(hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	n, err = s.RollupStats(hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n, "stats are rolled up once")
+
+	n, err = s.PurgeStats(now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n, "stats which are not rolled up are kept")
+
+	usage, err := s.GetUsageStatistic("localhost")
+	if assert.Nil(t, err) {
+		assert.Equal(t, 4, usage.RequestsCount, "purged stats are still counted")
+		assert.Equal(t, "/answer/id4", usage.LastUsage.Request)
+		assert.Equal(t, "/answer/id:id", usage.LastUsage.Route)
+	}
+
+	keys, err := s.GetAPIKeys()
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(keys)) {
+		assert.Equal(t, 3, keys[0].RequestsCount)
+		if assert.NotNil(t, keys[0].LastUsed) {
+			assert.WithinDuration(t, now, *keys[0].LastUsed, time.Millisecond)
+		}
+	}
+
+	// rollup of the next hour adds to the same total
+	n, err = s.RollupStats(now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	usage, _ = s.GetUsageStatistic("localhost")
+	assert.Equal(t, 4, usage.RequestsCount)
+	keys, _ = s.GetAPIKeys()
+	assert.Equal(t, 3, keys[0].RequestsCount)
+
+	// stat written late for an hour which is rolled up already is added by the next rollup
+	assert.Nil(t, s.LogStats([]model.RequestInfo{stat("/answer/id5", hour.Add(-2*time.Hour+3*time.Minute), 200, key.ID)}))
+	usage, _ = s.GetUsageStatistic("localhost")
+	assert.Equal(t, 5, usage.RequestsCount, "late stat is counted before rollup")
+	n, err = s.PurgeStats(now.Add(2 * time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n, "late stat is kept until it is rolled up")
+	n, err = s.RollupStats(now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n, "late stat is rolled up")
+	n, _ = s.PurgeStats(now.Add(2 * time.Hour))
+	assert.Equal(t, 1, n)
+
+	usage, _ = s.GetUsageStatistic("localhost")
+	assert.Equal(t, 5, usage.RequestsCount)
+	keys, _ = s.GetAPIKeys()
+	assert.Equal(t, 4, keys[0].RequestsCount)
+}
+
+func testEvents(t *testing.T, s model.AServiceInterface) {
+	first := mustAdd(t, s, newAnswer(1, 1, "first"))
+	second := mustAdd(t, s, newAnswer(1, 2, "second"))
+	_, err := s.UpdateAnswer(model.Answer{ID: second.ID})
+	assert.Nil(t, err)
+	n, err := s.DeleteAnswerByQuestionID(model.Answer{QuestionID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	events, err := s.ClaimEvents(10, time.Minute)
+	if assert.Nil(t, err) && assert.Equal(t, 5, len(events)) {
+		types := []string{}
+		ids := map[string]bool{}
+		for _, e := range events {
+			types = append(types, e.Type)
+			ids[e.ID] = true
+			assert.Equal(t, model.EventVersion, e.Version)
+			assert.Equal(t, 1, e.QuestionID)
+			assert.Equal(t, 0, e.Attempts)
+		}
+		assert.Equal(t, []string{model.EventAnswerCreated, model.EventAnswerCreated, model.EventAnswerBestMarked,
+			model.EventAnswerDeleted, model.EventAnswerDeleted}, types)
+		assert.Equal(t, 5, len(ids), "event ids are unique")
+
+		var created model.Answer
+		assert.Nil(t, json.Unmarshal(events[0].Payload, &created))
+		assert.Equal(t, first.ID, created.ID)
+		var best model.BestAnswer
+		assert.Nil(t, json.Unmarshal(events[2].Payload, &best))
+		assert.Equal(t, second.ID, best.ID)
+		var deleted model.Answer
+		assert.Nil(t, json.Unmarshal(events[3].Payload, &deleted))
+		assert.NotNil(t, deleted.DeletedAt)
+	}
+
+	again, err := s.ClaimEvents(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(again), "claimed events are leased")
+}
+
+func testEventsRetry(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "first"))
+	mustAdd(t, s, newAnswer(1, 2, "second"))
+
+	events, err := s.ClaimEvents(1, 0)
+	if !assert.Nil(t, err) || !assert.Equal(t, 1, len(events)) {
+		return
+	}
+	failed := events[0]
+	assert.Nil(t, s.RetryEvent(failed.ID, time.Now().Add(-time.Second), "connection refused"))
+
+	events, err = s.ClaimEvents(10, time.Minute)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(events)) {
+		assert.Equal(t, failed.ID, events[0].ID, "events are claimed in order")
+		assert.Equal(t, 1, events[0].Attempts)
+		assert.Nil(t, s.MarkEventsPublished([]string{events[0].ID, events[1].ID}))
+	}
+
+	events, err = s.ClaimEvents(10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(events), "published events are not claimed")
+	assert.Equal(t, ui.ErrNoDataToUpdate, s.RetryEvent(failed.ID, time.Now(), "late"))
+}
+
+func testPurgeEvents(t *testing.T, s model.AServiceInterface) {
+	mustAdd(t, s, newAnswer(1, 1, "first"))
+	mustAdd(t, s, newAnswer(1, 2, "second"))
+
+	events, err := s.ClaimEvents(1, 0)
+	if !assert.Nil(t, err) || !assert.Equal(t, 1, len(events)) {
+		return
+	}
+	assert.Nil(t, s.MarkEventsPublished([]string{events[0].ID}))
+
+	n, err := s.PurgeEvents(time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n, "recently published events are kept")
+	n, err = s.PurgeEvents(time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	events, err = s.ClaimEvents(10, 0)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(events), "pending events are kept") {
+		assert.Equal(t, model.EventAnswerCreated, events[0].Type)
+	}
+}
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+)
+
+// rollbackSummary default summary of rollback revisions
+func rollbackSummary(revision int) string {
+	return fmt.Sprintf("Rollback to revision %d", revision)
+}
+
+func addRevision(tx *pgx.Tx, r Revision) error {
+	_, err := tx.Exec(`
+		INSERT INTO answer.answer_revision
+			(answer_id, revision, content, editor_id, summary, created) VALUES ($1, $2, $3, $4, $5, $6)
+	`, r.AnswerID, r.Revision, r.Content, r.EditorID, r.Summary, r.Created)
+	return err
+}
+
+// editAnswer stores new content as the next revision within tx
+func editAnswer(tx *pgx.Tx, r Revision) (Answer, error) {
+	row := tx.QueryRow(`
+		UPDATE answer.answer SET content = $2, revision = revision + 1, updated = NOW()
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING `+answerColumns,
+		r.AnswerID, r.Content)
+	a, err := scanAnswer(row)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoDataToUpdate
+	}
+	if err != nil {
+		return a, err
+	}
+
+	r.Revision = a.Revision
+	r.Created = a.Updated
+	return a, addRevision(tx, r)
+}
+
+// EditAnswer change answer content keeping previous versions
+func (service *AService) EditAnswer(r Revision) (Answer, error) {
+	utils.Debug("Accessing database...")
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return Answer{}, err
+	}
+	defer tx.Rollback()
+
+	a, err := editAnswer(tx, r)
+	if err == nil {
+		err = tx.Commit()
+	}
+	return a, err
+}
+
+// RollbackAnswer restore content of r.Revision as the new revision
+func (service *AService) RollbackAnswer(r Revision) (Answer, error) {
+	utils.Debug("Accessing database...")
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return Answer{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		SELECT content FROM answer.answer_revision WHERE answer_id = $1 AND revision = $2
+	`, r.AnswerID, r.Revision).Scan(&r.Content)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoResult
+	}
+	if err != nil {
+		return Answer{}, err
+	}
+
+	if r.Summary == "" {
+		r.Summary = rollbackSummary(r.Revision)
+	}
+	a, err := editAnswer(tx, r)
+	if err == nil {
+		err = tx.Commit()
+	}
+	return a, err
+}
+
+// revisionColumns columns order expected by scanRevision
+const revisionColumns = `answer_id, revision, content, editor_id, summary, created`
+
+func scanRevision(row scanner) (Revision, error) {
+	var r Revision
+	err := row.Scan(
+		&r.AnswerID,
+		&r.Revision,
+		&r.Content,
+		&r.EditorID,
+		&r.Summary,
+		&r.Created)
+	return r, err
+}
+
+// GetRevisions get all the versions of answer content, oldest first
+func (service *AService) GetRevisions(aID int) ([]Revision, error) {
+	r := make([]Revision, 0)
+
+	utils.Debug("Accessing database...")
+	rows, err := service.Conn.Query(`
+		SELECT `+revisionColumns+` FROM answer.answer_revision WHERE answer_id = $1
+			AND answer_id IN (SELECT id FROM answer.answer WHERE deleted_at IS NULL)
+			ORDER BY revision ASC
+	`, aID)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tr Revision
+		tr, err = scanRevision(rows)
+		if err != nil {
+			return r, err
+		}
+		r = append(r, tr)
+	}
+	if err = rows.Err(); err != nil {
+		return r, err
+	}
+
+	if len(r) == 0 {
+		return r, ui.ErrNoResult
+	}
+	return r, nil
+}
+
+// GetRevision get one version of answer content
+func (service *AService) GetRevision(aID int, revision int) (Revision, error) {
+	utils.Debug("Accessing database...")
+	row := service.Conn.QueryRow(`
+		SELECT `+revisionColumns+` FROM answer.answer_revision WHERE answer_id = $1 AND revision = $2
+			AND answer_id IN (SELECT id FROM answer.answer WHERE deleted_at IS NULL)
+	`, aID, revision)
+
+	r, err := scanRevision(row)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoResult
+	}
+	return r, err
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/RSOI/answer/utils"
+)
+
+// searchVector must stay equal to content__search_index expression, otherwise the index isn't used
+const searchVector = `to_tsvector('simple', content::text)`
+
+// SearchAnswers find answers containing all the words of q.Text, most relevant first
+func (service *AService) SearchAnswers(q SearchQuery) ([]SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = PageSize
+	}
+
+	filters, args := filterConditions(AnswersQuery{AuthorID: q.AuthorID}, []interface{}{q.Text})
+	if q.QuestionID != nil {
+		args = append(args, *q.QuestionID)
+		filters += fmt.Sprintf(` AND question_id = $%d`, len(args))
+	}
+	args = append(args, q.Limit)
+
+	r := make([]SearchResult, 0)
+	utils.Debug("Accessing database...")
+	rows, err := service.Conn.Query(`
+		SELECT `+answerColumns+`,
+			ts_rank(`+searchVector+`, query) AS rank,
+			ts_headline('simple', content::text, query)
+		FROM answer.answer, plainto_tsquery('simple', $1) query
+		WHERE `+searchVector+` @@ query`+filters+`
+		ORDER BY rank DESC, id ASC
+		LIMIT $`+fmt.Sprint(len(args)), args...)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tr SearchResult
+		tr.Answer, err = scanAnswer(rows, &tr.Rank, &tr.Snippet)
+		if err != nil {
+			return r, err
+		}
+		r = append(r, tr)
+	}
+	return r, rows.Err()
+}
+
+// span position of one word in text
+type span struct {
+	start int
+	end   int
+	word  string
+}
+
+// tokenize splits text into lower case words of letters and digits
+func tokenize(text string) []span {
+	words := make([]span, 0)
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			words = append(words, span{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	return words
+}
+
+// snippetWords words count of search result fragment
+const snippetWords = 35
+
+// matchContent ranks content against terms, ok is false if any of the terms is missed.
+// Snippet starts a few words before the first match.
+func matchContent(content string, terms map[string]bool) (rank float32, snippet string, ok bool) {
+	words := tokenize(content)
+	found := make(map[string]bool)
+	first := -1
+	for i, w := range words {
+		if terms[w.word] {
+			found[w.word] = true
+			rank++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if len(terms) == 0 || len(found) != len(terms) {
+		return 0, "", false
+	}
+
+	from := first - 5
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(words) {
+		to = len(words)
+	}
+
+	var b strings.Builder
+	pos := words[from].start
+	for _, w := range words[from:to] {
+		b.WriteString(content[pos:w.start])
+		if terms[w.word] {
+			b.WriteString("<b>" + content[w.start:w.end] + "</b>")
+		} else {
+			b.WriteString(content[w.start:w.end])
+		}
+		pos = w.end
+	}
+	return rank / float32(len(words)), b.String(), true
+}
+
+// sortResults orders search results by rank, oldest first among equal ones
+func sortResults(r []SearchResult) {
+	sort.SliceStable(r, func(i, j int) bool {
+		if r[i].Rank != r[j].Rank {
+			return r[i].Rank > r[j].Rank
+		}
+		return r[i].ID < r[j].ID
+	})
+}
+
+// searchTerms returns unique words of search text
+func searchTerms(text string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, w := range tokenize(text) {
+		terms[w.word] = true
+	}
+	return terms
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	words := tokenize("Привет, World! go1.11")
+	got := make([]string, 0, len(words))
+	for _, w := range words {
+		got = append(got, w.word)
+	}
+	assert.Equal(t, []string{"привет", "world", "go1", "11"}, got)
+}
+
+func TestMatchContentSnippetWindow(t *testing.T) {
+	content := strings.Repeat("filler ", 50) + "needle " + strings.Repeat("tail ", 50)
+
+	_, snippet, ok := matchContent(content, searchTerms("needle"))
+	if assert.True(t, ok) {
+		assert.True(t, strings.HasPrefix(snippet, "filler filler filler filler filler <b>needle</b> tail"))
+		plain := strings.NewReplacer("<b>", "", "</b>", "").Replace(snippet)
+		assert.Equal(t, snippetWords, len(tokenize(plain)))
+	}
+
+	_, _, ok = matchContent(content, searchTerms("needle thread"))
+	assert.False(t, ok)
+}
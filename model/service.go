@@ -2,18 +2,58 @@ package model
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/RSOI/answer/utils"
 	"github.com/jackc/pgx"
 )
 
+// NotUsedYet request text reported while there is no usage logged
+const NotUsedYet = "Service wasn't used yet"
+
 // RequestInfo interface. Provides one request data.
 type RequestInfo struct {
 	Request           string    `json:"request"`
 	RequestTime       time.Time `json:"request_time"`
 	ResponseStatus    int       `json:"response_status"`
 	ResponseErrorText string    `json:"response_error_text"`
+	APIKeyID          int       `json:"api_key_id,omitempty"`
+	RequestID         string    `json:"request_id,omitempty"`
+	Method            string    `json:"method,omitempty"`
+	// Route pattern request was served by, requests logged before routes were recorded have none
+	Route string `json:"route,omitempty"`
+	// Duration from reading request until response was ready, in milliseconds
+	Duration float64 `json:"duration_ms"`
+	// Client API key, token subject or address of caller
+	Client string `json:"client,omitempty"`
+}
+
+// RouteStat usage of one route over window: requests, share of server errors and latency percentiles
+type RouteStat struct {
+	Method       string  `json:"method"`
+	Route        string  `json:"route"`
+	Requests     int     `json:"requests"`
+	ClientErrors int     `json:"client_errors"`
+	ServerErrors int     `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	P50          float64 `json:"p50_ms"`
+	P95          float64 `json:"p95_ms"`
+	P99          float64 `json:"p99_ms"`
+}
+
+// WindowStats usage of every route since start of window
+type WindowStats struct {
+	Window string      `json:"window"`
+	Since  time.Time   `json:"since"`
+	Routes []RouteStat `json:"routes"`
+}
+
+// StatsWindows periods per-route usage is reported for
+var StatsWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
 }
 
 // ServiceStatus interface. Provides usage data.
@@ -23,52 +63,180 @@ type ServiceStatus struct {
 	LastUsage     RequestInfo `json:"last_usage"`
 }
 
-// GetUsageStatistic provides access to logs
+// GetUsageStatistic provides access to logs. Requests are counted from rolled up total
+// and rows logged after it, so that the whole log is not scanned.
 func (service *AService) GetUsageStatistic(host string) (ServiceStatus, error) {
 	var err error
 
-	utils.LOG("Accessing database...")
+	utils.Debug("Accessing database...")
+	ServiceResponse := ServiceStatus{Address: host}
+	err = service.Conn.QueryRow(`
+		SELECT r.requests + (SELECT count(*) FROM answer.services WHERE NOT rolled_up)
+			FROM answer.services_rollup r
+	`).Scan(&ServiceResponse.RequestsCount)
+	if err != nil {
+		return ServiceResponse, err
+	}
+
 	row := service.Conn.QueryRow(`
-		SELECT cnt.*, last_usage.* FROM
-			(SELECT count(*) FROM answer.services) AS cnt,
-			(SELECT request, request_time, response_status, response_error_text
-				FROM answer.services ORDER BY id DESC LIMIT 1
-			) AS last_usage
+		SELECT request, request_time, response_status, response_error_text, COALESCE(api_key_id, 0), COALESCE(request_id, ''),
+			COALESCE(method, ''), COALESCE(route, ''), COALESCE(duration_ms, 0), COALESCE(client, '')
+			FROM answer.services ORDER BY id DESC LIMIT 1
 	`)
-	var ServiceResponse ServiceStatus
 	err = row.Scan(
-		&ServiceResponse.RequestsCount,
 		&ServiceResponse.LastUsage.Request,
 		&ServiceResponse.LastUsage.RequestTime,
 		&ServiceResponse.LastUsage.ResponseStatus,
-		&ServiceResponse.LastUsage.ResponseErrorText)
+		&ServiceResponse.LastUsage.ResponseErrorText,
+		&ServiceResponse.LastUsage.APIKeyID,
+		&ServiceResponse.LastUsage.RequestID,
+		&ServiceResponse.LastUsage.Method,
+		&ServiceResponse.LastUsage.Route,
+		&ServiceResponse.LastUsage.Duration,
+		&ServiceResponse.LastUsage.Client)
 	if err == pgx.ErrNoRows {
-		ServiceResponse.Address = host
-		ServiceResponse.RequestsCount = 0
-		ServiceResponse.LastUsage.Request = "Service wasn't used yet"
-		ServiceResponse.LastUsage.RequestTime = time.Time{}
+		ServiceResponse.LastUsage = RequestInfo{Request: NotUsedYet}
 		err = nil
 	}
 
 	return ServiceResponse, err
 }
 
-// LogStat Set request into log db table
-func (service *AService) LogStat(request []byte, responseStatus int, responseError string) {
-	var err error
+// StatColumns statement parameters taken by every stat of LogStats batch
+const StatColumns = 10
+
+// LogStats stores batch of requests into log db table with a single multi-row INSERT.
+// Zero APIKeyID and empty RequestID are stored as NULL.
+func (service *AService) LogStats(stats []RequestInfo) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(stats))
+	args := make([]interface{}, 0, len(stats)*StatColumns)
+	for i, s := range stats {
+		n := i * StatColumns
+		values = append(values, fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, NULLIF($%d, 0), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d, NULLIF($%d, ''))",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10))
+		args = append(args, s.Request, s.RequestTime, s.ResponseStatus, s.ResponseErrorText, s.APIKeyID, s.RequestID,
+			s.Method, s.Route, s.Duration, s.Client)
+	}
 
 	res, err := service.Conn.Exec(`
-		INSERT INTO answer.services 
-			(request, response_status, response_error_text) VALUES ($1, $2, $3)
-	`, string(request), responseStatus, responseError)
+		INSERT INTO answer.services
+			(request, request_time, response_status, response_error_text, api_key_id, request_id,
+				method, route, duration_ms, client)
+			VALUES `+strings.Join(values, ", "), args...)
+	if err != nil {
+		return err
+	}
+	if int(res.RowsAffected()) != len(stats) {
+		return fmt.Errorf("%d of %d statistic rows stored", res.RowsAffected(), len(stats))
+	}
+
+	utils.Debug("Statistic stored successfully", utils.Fields{"count": len(stats)})
+	return nil
+}
 
+// GetRouteStats provides per-route usage of requests logged since given time.
+// Percentiles are interpolated like percentile_cont does.
+func (service *AService) GetRouteStats(since time.Time) ([]RouteStat, error) {
+	rows, err := service.Conn.Query(`
+		SELECT method, route, count(*),
+			count(*) FILTER (WHERE response_status BETWEEN 400 AND 499),
+			count(*) FILTER (WHERE response_status >= 500),
+			COALESCE(percentile_cont(ARRAY[0.5, 0.95, 0.99]) WITHIN GROUP (ORDER BY duration_ms), ARRAY[0, 0, 0]::float8[])
+		FROM answer.services
+		WHERE request_time >= $1 AND route IS NOT NULL
+		GROUP BY method, route
+		ORDER BY route, method
+	`, since)
 	if err != nil {
-		utils.LOG(fmt.Sprintf("Error while storing statistic: %s", err.Error()))
-	} else {
-		if res.RowsAffected() != 1 {
-			utils.LOG("Unable store statistic")
-		} else {
-			utils.LOG("Statistic stored successfully")
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []RouteStat{}
+	for rows.Next() {
+		var r RouteStat
+		var percentiles []float64
+		var method *string
+		if err = rows.Scan(&method, &r.Route, &r.Requests, &r.ClientErrors, &r.ServerErrors, &percentiles); err != nil {
+			return nil, err
+		}
+		if method != nil {
+			r.Method = *method
 		}
+		if len(percentiles) == 3 {
+			r.P50, r.P95, r.P99 = percentiles[0], percentiles[1], percentiles[2]
+		}
+		r.ErrorRate = float64(r.ServerErrors) / float64(r.Requests)
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
+// RollupStats adds requests logged before given time which are not rolled up yet to hourly aggregates.
+// Rows are marked rolled up one by one, so stats written late for hours rolled up before are added too.
+// Replicas may run it concurrently, every row is rolled up once.
+func (service *AService) RollupStats(until time.Time) (int, error) {
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`SELECT 1 FROM answer.services_rollup FOR UPDATE`); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = tx.QueryRow(`
+		WITH marked AS (
+			UPDATE answer.services SET rolled_up = TRUE
+				WHERE NOT rolled_up AND request_time < $1
+				RETURNING request_time, method, route, api_key_id, response_status, duration_ms
+		), batch AS (
+			SELECT date_trunc('hour', request_time) AS hour, COALESCE(method, '') AS method, COALESCE(route, '') AS route,
+				COALESCE(api_key_id, 0) AS api_key_id, count(*) AS requests,
+				count(*) FILTER (WHERE response_status BETWEEN 400 AND 499) AS client_errors,
+				count(*) FILTER (WHERE response_status >= 500) AS server_errors,
+				COALESCE(sum(duration_ms), 0) AS duration_ms_sum, max(request_time) AS last_request_time
+			FROM marked
+			GROUP BY 1, 2, 3, 4
+		), rolled AS (
+			INSERT INTO answer.services_hourly AS h
+				(hour, method, route, api_key_id, requests, client_errors, server_errors, duration_ms_sum, last_request_time)
+				SELECT * FROM batch
+			ON CONFLICT (hour, method, route, api_key_id) DO UPDATE SET
+				requests = h.requests + EXCLUDED.requests,
+				client_errors = h.client_errors + EXCLUDED.client_errors,
+				server_errors = h.server_errors + EXCLUDED.server_errors,
+				duration_ms_sum = h.duration_ms_sum + EXCLUDED.duration_ms_sum,
+				last_request_time = GREATEST(h.last_request_time, EXCLUDED.last_request_time)
+		)
+		SELECT COALESCE(sum(requests), 0)::bigint FROM batch
+	`, until).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(`
+		UPDATE answer.services_rollup SET rolled_up_to = GREATEST(rolled_up_to, $1), requests = requests + $2
+	`, until, n); err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// PurgeStats removes rows logged before given time, rows which are not rolled up yet are kept
+func (service *AService) PurgeStats(before time.Time) (int, error) {
+	res, err := service.Conn.Exec(`
+		DELETE FROM answer.services WHERE rolled_up AND request_time < $1
+	`, before)
+	if err != nil {
+		return 0, err
 	}
+	return int(res.RowsAffected()), nil
 }
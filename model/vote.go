@@ -0,0 +1,52 @@
+package model
+
+import (
+	"github.com/jackc/pgx"
+
+	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/utils"
+)
+
+// VoteAnswer set, change or retract (Value 0) user vote and recalculate answer score
+func (service *AService) VoteAnswer(v Vote) (Answer, error) {
+	utils.Debug("Accessing database...")
+	tx, err := service.Conn.Begin()
+	if err != nil {
+		return Answer{}, err
+	}
+	defer tx.Rollback()
+
+	// every vote for the answer waits here, so score below sees all the committed votes
+	var id int
+	err = tx.QueryRow(`SELECT id FROM answer.answer WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, v.AnswerID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		err = ui.ErrNoDataToUpdate
+	}
+	if err != nil {
+		return Answer{}, err
+	}
+
+	if v.Value == 0 {
+		_, err = tx.Exec(`DELETE FROM answer.answer_vote WHERE answer_id = $1 AND voter_id = $2`, v.AnswerID, v.VoterID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO answer.answer_vote (answer_id, voter_id, value) VALUES ($1, $2, $3)
+				ON CONFLICT (answer_id, voter_id) DO UPDATE SET value = EXCLUDED.value
+		`, v.AnswerID, v.VoterID, v.Value)
+	}
+	if err != nil {
+		return Answer{}, err
+	}
+
+	row := tx.QueryRow(`
+		UPDATE answer.answer SET score = (
+			SELECT COALESCE(SUM(value), 0) FROM answer.answer_vote WHERE answer_id = $1
+		) WHERE id = $1
+		RETURNING `+answerColumns,
+		v.AnswerID)
+	a, err := scanAnswer(row)
+	if err == nil {
+		err = tx.Commit()
+	}
+	return a, err
+}
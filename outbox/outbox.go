@@ -0,0 +1,152 @@
+// Package outbox delivers domain events written to outbox along with answer changes.
+// Delivery is at least once: event is published again unless it was marked published,
+// so consumers drop events with ID they have seen.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/utils"
+)
+
+// Publisher sends event to other services
+type Publisher interface {
+	Publish(ctx context.Context, e model.Event) error
+}
+
+// PublisherFunc lets function be used as Publisher
+type PublisherFunc func(ctx context.Context, e model.Event) error
+
+// Publish implements Publisher
+func (f PublisherFunc) Publish(ctx context.Context, e model.Event) error {
+	return f(ctx, e)
+}
+
+// LogPublisher logs events, it is used while no broker is configured
+type LogPublisher struct{}
+
+// Publish implements Publisher
+func (LogPublisher) Publish(ctx context.Context, e model.Event) error {
+	utils.Info("Event published", utils.Fields{"event_id": e.ID, "type": e.Type, "answer_id": e.AnswerID})
+	return nil
+}
+
+// Store keeps events until they are published, model.AServiceInterface is one
+type Store interface {
+	ClaimEvents(limit int, lease time.Duration) ([]model.Event, error)
+	MarkEventsPublished(ids []string) error
+	RetryEvent(id string, at time.Time, reason string) error
+}
+
+var (
+	// Lease claimed events are hidden from other relays, it outlasts PublishTimeout
+	Lease = time.Minute
+	// PublishTimeout publishing of one event
+	PublishTimeout = 10 * time.Second
+	// MinBackoff delay before the first retry, every next one doubles up to max backoff
+	MinBackoff = time.Second
+)
+
+// Relay publishes pending events of store in order they were written
+type Relay struct {
+	store      Store
+	publisher  Publisher
+	batchSize  int
+	interval   time.Duration
+	maxBackoff time.Duration
+}
+
+// NewRelay returns relay claiming batches of cfg.BatchSize every cfg.Interval
+func NewRelay(store Store, publisher Publisher, cfg config.OutboxConfig) *Relay {
+	return &Relay{
+		store:      store,
+		publisher:  publisher,
+		batchSize:  cfg.BatchSize,
+		interval:   cfg.Interval,
+		maxBackoff: cfg.MaxBackoff,
+	}
+}
+
+// Backoff returns delay before retry of event failed attempts times
+func (r *Relay) Backoff(attempts int) time.Duration {
+	d := MinBackoff
+	for i := 0; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d
+}
+
+// Once publishes one batch of due events and returns how many were claimed.
+// Failed events are retried after backoff.
+func (r *Relay) Once(ctx context.Context) (int, error) {
+	events, err := r.store.ClaimEvents(r.batchSize, Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := make([]string, 0, len(events))
+	for _, e := range events {
+		pctx, cancel := context.WithTimeout(ctx, PublishTimeout)
+		err := r.publisher.Publish(pctx, e)
+		cancel()
+		if err == nil {
+			published = append(published, e.ID)
+			continue
+		}
+
+		retry := r.Backoff(e.Attempts)
+		utils.Warn("Event is not published", utils.Fields{"event_id": e.ID, "type": e.Type, "attempts": e.Attempts + 1, "retry_in": retry, "err": err})
+		if err := r.store.RetryEvent(e.ID, time.Now().Add(retry), err.Error()); err != nil {
+			utils.Error("Event retry is not stored", utils.Fields{"event_id": e.ID, "err": err})
+		}
+	}
+
+	if len(published) > 0 {
+		if err := r.store.MarkEventsPublished(published); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// Start runs Once every interval, full batches are followed by the next one at once.
+// Returned stop function waits for batch being published.
+func (r *Relay) Start() (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			n, err := r.Once(context.Background())
+			if err != nil {
+				utils.Error("Outbox relay error", utils.Fields{"err": err})
+			}
+			if err == nil && n == r.batchSize {
+				select {
+				case <-done:
+					return
+				default:
+					continue
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
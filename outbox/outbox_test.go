@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder publishes events, failing ones of answers in fail
+type recorder struct {
+	mu        sync.Mutex
+	published []model.Event
+	fail      map[int]bool
+}
+
+func (r *recorder) Publish(ctx context.Context, e model.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[e.AnswerID] {
+		return errors.New("broker is unavailable")
+	}
+	r.published = append(r.published, e)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.published)
+}
+
+func outboxConfig(batch int) config.OutboxConfig {
+	return config.OutboxConfig{Publisher: "log", BatchSize: batch, Interval: time.Hour, MaxBackoff: time.Minute}
+}
+
+func addAnswers(t *testing.T, s model.AServiceInterface, n int) {
+	content := "content"
+	for i := 0; i < n; i++ {
+		if _, err := s.AddAnswer(model.Answer{QuestionID: 1, AuthorID: 1, AuthorNickname: "Test", Content: &content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelayOnce(t *testing.T) {
+	s := &model.AMemoryService{}
+	addAnswers(t, s, 3)
+	pub := &recorder{}
+	relay := NewRelay(s, pub, outboxConfig(2))
+
+	n, err := relay.Once(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = relay.Once(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	n, _ = relay.Once(context.Background())
+	assert.Equal(t, 0, n, "published events are not published again")
+
+	if assert.Equal(t, 3, len(pub.published)) {
+		for i, e := range pub.published {
+			assert.Equal(t, model.EventAnswerCreated, e.Type)
+			assert.Equal(t, i+1, e.AnswerID, "events are published in order")
+		}
+	}
+}
+
+func TestRelayRetriesFailed(t *testing.T) {
+	s := &model.AMemoryService{}
+	addAnswers(t, s, 2)
+	pub := &recorder{fail: map[int]bool{1: true}}
+	relay := NewRelay(s, pub, outboxConfig(10))
+
+	prev := MinBackoff
+	MinBackoff = 0
+	defer func() { MinBackoff = prev }()
+
+	n, err := relay.Once(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1, len(pub.published))
+
+	pub.fail = nil
+	n, err = relay.Once(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n, "failed event is retried")
+	if assert.Equal(t, 2, len(pub.published)) {
+		assert.Equal(t, 1, pub.published[1].AnswerID)
+		assert.Equal(t, 1, pub.published[1].Attempts)
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(&model.AMemoryService{}, LogPublisher{}, outboxConfig(10))
+
+	assert.Equal(t, time.Second, relay.Backoff(0))
+	assert.Equal(t, 4*time.Second, relay.Backoff(2))
+	assert.Equal(t, time.Minute, relay.Backoff(10), "backoff is limited")
+}
+
+func TestRelayStart(t *testing.T) {
+	s := &model.AMemoryService{}
+	addAnswers(t, s, 5)
+	pub := &recorder{}
+
+	stop := NewRelay(s, pub, outboxConfig(2)).Start()
+	defer stop()
+	assert.Eventually(t, func() bool { return pub.count() == 5 }, time.Second, time.Millisecond,
+		"full batches are followed by the next one at once")
+}
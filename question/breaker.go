@@ -0,0 +1,84 @@
+package question
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RSOI/answer/utils"
+)
+
+// States of Breaker
+const (
+	// StateClosed requests are sent
+	StateClosed = "closed"
+	// StateOpen requests are not sent until cooldown is over
+	StateOpen = "open"
+	// StateHalfOpen one trial request is sent, its outcome closes or opens breaker again
+	StateHalfOpen = "half-open"
+)
+
+// Breaker stops requests to service which failed threshold times in a row, for cooldown
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	opened   time.Time
+}
+
+// NewBreaker returns closed breaker
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: StateClosed}
+}
+
+// Allow reports whether request may be sent. Once cooldown is over one trial request is allowed.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.opened) < b.cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		return true
+	case StateHalfOpen:
+		return false
+	}
+	return true
+}
+
+// Success closes breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateClosed {
+		utils.Info("Question service is available again")
+	}
+	b.state = StateClosed
+	b.failures = 0
+}
+
+// Failure counts failed request, breaker opens after threshold of them or failed trial
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.threshold) {
+		utils.Warn("Question service is unavailable, requests are stopped", utils.Fields{"failures": b.failures, "cooldown": b.cooldown})
+		b.state = StateOpen
+		b.opened = time.Now()
+	}
+}
+
+// State returns one of StateClosed, StateOpen and StateHalfOpen
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
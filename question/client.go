@@ -0,0 +1,153 @@
+// Package question is a client of RSOI question service.
+// Questions are read from GET /question/id<id>?include_deleted=true, which answers
+// in the {"status", "error", "data"} envelope shared by RSOI services.
+package question
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/utils"
+)
+
+var (
+	// ErrNotFound question service does not know the question
+	ErrNotFound = errors.New("question not found")
+	// ErrUnavailable question service failed every attempt or breaker is open
+	ErrUnavailable = errors.New("question service is unavailable")
+	// RetryBackoff delay before the first retry, every next one doubles
+	RetryBackoff = 100 * time.Millisecond
+)
+
+// Question as seen by answers
+type Question struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	AuthorID  int        `json:"author_id"`
+	Locked    bool       `json:"locked"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Client reads questions, failed requests are retried and stopped by circuit breaker
+type Client struct {
+	base    string
+	apiKey  string
+	timeout time.Duration
+	retries int
+	client  *fasthttp.Client
+	breaker *Breaker
+}
+
+// NewClient returns client of question service at cfg.URL
+func NewClient(cfg config.QuestionConfig) *Client {
+	return &Client{
+		base:    strings.TrimRight(cfg.URL, "/"),
+		apiKey:  cfg.APIKey,
+		timeout: cfg.Timeout,
+		retries: cfg.Retries,
+		client:  &fasthttp.Client{Name: "answer"},
+		breaker: NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// Breaker returns circuit breaker of client
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// GetQuestion returns question with id, deleted ones included. Unknown question is ErrNotFound,
+// every other failure is ErrUnavailable with cause logged.
+func (c *Client) GetQuestion(ctx context.Context, id int) (Question, error) {
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			sleep(ctx, RetryBackoff<<uint(attempt-1))
+		}
+		if ctx.Err() != nil {
+			// request is given up, it says nothing of question service
+			err = ctx.Err()
+			break
+		}
+		if !c.breaker.Allow() {
+			err = errors.New("circuit breaker is open")
+			break
+		}
+
+		var q Question
+		var retry bool
+		q, retry, err = c.get(ctx, id)
+		if err == nil || err == ErrNotFound {
+			c.breaker.Success()
+			return q, err
+		}
+		if !retry {
+			// service is up, it just did not accept the request
+			c.breaker.Success()
+			break
+		}
+		c.breaker.Failure()
+	}
+
+	utils.Warn("Question service request failed", utils.Fields{"question_id": id, "err": err})
+	return Question{}, ErrUnavailable
+}
+
+// get sends one request and tells whether failed one is worth retrying
+func (c *Client) get(ctx context.Context, id int) (Question, bool, error) {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(c.base + "/question/id" + strconv.Itoa(id) + "?include_deleted=true")
+	req.Header.SetMethod(fasthttp.MethodGet)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	if err := c.client.DoDeadline(req, resp, deadline); err != nil {
+		return Question{}, true, err
+	}
+	switch status := resp.StatusCode(); {
+	case status == 404:
+		return Question{}, false, ErrNotFound
+	case status == 429 || status >= 500:
+		return Question{}, true, fmt.Errorf("question service answered %d", status)
+	case status != 200:
+		return Question{}, false, fmt.Errorf("question service answered %d", status)
+	}
+
+	var r struct {
+		Data Question `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &r); err != nil {
+		return Question{}, false, fmt.Errorf("broken question: %s", err.Error())
+	}
+	if r.Data.ID != id {
+		return Question{}, false, fmt.Errorf("question %d is answered for %d", r.Data.ID, id)
+	}
+	return r.Data, false, nil
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,162 @@
+package question
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// stub question service answering with status and body of question path, counting requests
+type stub struct {
+	mu       sync.Mutex
+	answers  map[string]string
+	status   int
+	delay    time.Duration
+	requests int
+	apiKey   string
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	s.apiKey = r.Header.Get("X-API-Key")
+	status, delay := s.status, s.delay
+	body, ok := s.answers[r.URL.Path]
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+	if r.URL.Query().Get("include_deleted") != "true" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status":404,"error":"no data found","data":null}`))
+		return
+	}
+	w.Write([]byte(body))
+}
+
+func (s *stub) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *stub) answer(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func newStub() (*stub, *httptest.Server) {
+	s := &stub{answers: map[string]string{
+		"/question/id1": `{"status":200,"error":"","data":{"id":1,"title":"Why?","author_id":3,"locked":false}}`,
+		"/question/id2": `{"status":200,"error":"","data":{"id":2,"title":"Closed","author_id":3,"locked":true}}`,
+		"/question/id3": `{"status":200,"error":"","data":{"id":3,"title":"Gone","author_id":3,"deleted_at":"2026-01-02T03:04:05Z"}}`,
+	}}
+	return s, httptest.NewServer(s)
+}
+
+func questionConfig(url string, retries int) config.QuestionConfig {
+	return config.QuestionConfig{URL: url + "/", APIKey: "ak_test", Timeout: time.Second, Retries: retries,
+		BreakerThreshold: 3, BreakerCooldown: time.Hour, Policy: "fail-closed"}
+}
+
+func TestGetQuestion(t *testing.T) {
+	s, server := newStub()
+	defer server.Close()
+	c := NewClient(questionConfig(server.URL, 2))
+
+	q, err := c.GetQuestion(context.Background(), 1)
+	if assert.Nil(t, err) {
+		assert.Equal(t, Question{ID: 1, Title: "Why?", AuthorID: 3}, q)
+	}
+	assert.Equal(t, "ak_test", s.apiKey)
+
+	q, err = c.GetQuestion(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.True(t, q.Locked)
+	q, err = c.GetQuestion(context.Background(), 3)
+	if assert.Nil(t, err) && assert.NotNil(t, q.DeletedAt) {
+		assert.Equal(t, 2026, q.DeletedAt.Year())
+	}
+
+	_, err = c.GetQuestion(context.Background(), 4)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 4, s.count(), "found and unknown questions are not retried")
+}
+
+func TestGetQuestionRetries(t *testing.T) {
+	s, server := newStub()
+	defer server.Close()
+	prev := RetryBackoff
+	RetryBackoff = time.Millisecond
+	defer func() { RetryBackoff = prev }()
+
+	s.answer(http.StatusServiceUnavailable)
+	c := NewClient(questionConfig(server.URL, 2))
+	_, err := c.GetQuestion(context.Background(), 1)
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Equal(t, 3, s.count(), "failed request is retried")
+
+	s.answer(http.StatusUnauthorized)
+	c = NewClient(questionConfig(server.URL, 2))
+	_, err = c.GetQuestion(context.Background(), 1)
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Equal(t, 4, s.count(), "rejected request is not retried")
+}
+
+func TestGetQuestionTimeout(t *testing.T) {
+	s, server := newStub()
+	defer server.Close()
+	s.delay = 50 * time.Millisecond
+	cfg := questionConfig(server.URL, 0)
+	cfg.Timeout = 10 * time.Millisecond
+
+	_, err := NewClient(cfg).GetQuestion(context.Background(), 1)
+	assert.Equal(t, ErrUnavailable, err)
+}
+
+func TestBreakerStopsRequests(t *testing.T) {
+	s, server := newStub()
+	defer server.Close()
+	s.answer(http.StatusInternalServerError)
+	c := NewClient(questionConfig(server.URL, 0))
+
+	for i := 0; i < 3; i++ {
+		c.GetQuestion(context.Background(), 1)
+	}
+	assert.Equal(t, StateOpen, c.Breaker().State())
+	_, err := c.GetQuestion(context.Background(), 1)
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Equal(t, 3, s.count(), "no requests while breaker is open")
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b := NewBreaker(1, 10*time.Millisecond)
+
+	b.Failure()
+	assert.False(t, b.Allow())
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.Allow(), "trial request after cooldown")
+	assert.False(t, b.Allow(), "only one trial request")
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State(), "failed trial opens breaker again")
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, StateClosed, b.State())
+	assert.True(t, b.Allow())
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval how often buckets which are full again are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryStore in-process buckets. Zero value is ready to use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// Take takes one token from bucket of key if there is any
+func (s *MemoryStore) Take(key string, l Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets == nil {
+		s.buckets = make(map[string]*bucket)
+	}
+	if now.Sub(s.swept) > sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = refill(l, b.tokens, now.Sub(b.updated))
+	b.updated = now
+	b.limit = l
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(l, b.tokens, allowed), nil
+}
+
+// Prune drops full buckets, buckets are swept while taken from anyway, so idle is not needed
+func (s *MemoryStore) Prune(idle time.Duration, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweep(now), nil
+}
+
+// sweep drops full buckets, they are the same as missing ones. Lock must be held.
+func (s *MemoryStore) sweep(now time.Time) int {
+	n := 0
+	for key, b := range s.buckets {
+		if refill(b.limit, b.tokens, now.Sub(b.updated)) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+			n++
+		}
+	}
+	s.swept = now
+	return n
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// PostgresStore buckets shared by replicas in answer.rate_limit table.
+// Database clock is used, so replicas don't need synchronized clocks.
+type PostgresStore struct {
+	Conn *pgx.ConnPool
+}
+
+// Take takes one token from bucket of key if there is any
+func (s *PostgresStore) Take(key string, l Limit, now time.Time) (Result, error) {
+	var tokens float64
+	var allowed bool
+	// refilled bucket is LEAST($2, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $3)
+	err := s.Conn.QueryRow(`
+		INSERT INTO answer.rate_limit AS b (key, tokens, allowed, updated)
+			VALUES ($1, $2::float8 - 1, TRUE, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $3::float8) >= 1
+				THEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $3::float8) - 1
+				ELSE LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $3::float8)
+			END,
+			allowed = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated) * $3::float8) >= 1,
+			updated = NOW()
+		RETURNING tokens, allowed
+	`, key, float64(l.Burst), l.rate()).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(l, tokens, allowed), nil
+}
+
+// Prune deletes buckets not updated for idle by database clock
+func (s *PostgresStore) Prune(idle time.Duration, now time.Time) (int, error) {
+	tag, err := s.Conn.Exec(`
+		DELETE FROM answer.rate_limit WHERE updated < NOW() - $1::float8 * INTERVAL '1 second'
+	`, idle.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
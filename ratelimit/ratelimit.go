@@ -0,0 +1,104 @@
+// Package ratelimit limits requests of every client with token buckets
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Route groups limited separately
+const (
+	GroupRead  = "read"
+	GroupWrite = "write"
+	GroupAdmin = "admin"
+	// GroupKeys API key lookups, limited by address before the key is known
+	GroupKeys = "keys"
+)
+
+// Limit bucket of Burst requests refilled completely in Per. Zero limit is unlimited.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// rate refill speed, tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Result of taking one token from bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset time until bucket is full again
+	Reset time.Duration
+	// RetryAfter time until next token, set when request is not allowed
+	RetryAfter time.Duration
+}
+
+// result describes bucket having tokens after request
+func result(l Limit, tokens float64, allowed bool) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     l.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(l.Burst) - tokens) / l.rate()),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / l.rate())
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// refill returns tokens of bucket after elapsed time
+func refill(l Limit, tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.rate())
+}
+
+// Store keeps buckets. In-process store limits every replica separately,
+// a store shared by replicas makes limits global.
+type Store interface {
+	// Take takes one token from bucket of key if there is any
+	Take(key string, l Limit, now time.Time) (Result, error)
+	// Prune drops buckets not taken from for idle, they are full again
+	Prune(idle time.Duration, now time.Time) (int, error)
+}
+
+// Limiter limits clients in every route group
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+}
+
+// New returns limiter keeping buckets in store. Groups without limit are not limited.
+func New(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+// Allow takes token of client in group. Zero Result.Limit means group is not limited.
+func (l *Limiter) Allow(group string, client string) (Result, error) {
+	limit := l.limits[group]
+	if limit.Burst <= 0 || limit.Per <= 0 {
+		return Result{Allowed: true}, nil
+	}
+	return l.store.Take(group+":"+client, limit, time.Now())
+}
+
+// Prune drops buckets idle long enough to be full again in every group, that is the longest refill period
+func (l *Limiter) Prune() (int, error) {
+	var idle time.Duration
+	for _, limit := range l.limits {
+		if limit.Burst > 0 && limit.Per > idle {
+			idle = limit.Per
+		}
+	}
+	if idle == 0 {
+		return 0, nil
+	}
+	return l.store.Prune(idle, time.Now())
+}
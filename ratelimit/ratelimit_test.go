@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/database"
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+)
+
+var perMinute = Limit{Burst: 3, Per: 3 * time.Minute}
+
+func take(t *testing.T, s Store, key string, now time.Time) Result {
+	r, err := s.Take(key, perMinute, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func testBucket(t *testing.T, s Store, now time.Time) {
+	for i := 2; i >= 0; i-- {
+		r := take(t, s, "client", now)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 3, r.Limit)
+		assert.Equal(t, i, r.Remaining)
+	}
+
+	r := take(t, s, "client", now)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+	assert.InDelta(t, time.Minute.Seconds(), r.RetryAfter.Seconds(), 1)
+	assert.InDelta(t, (3 * time.Minute).Seconds(), r.Reset.Seconds(), 1)
+
+	assert.True(t, take(t, s, "other", now).Allowed, "buckets are per key")
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := &MemoryStore{}
+	now := time.Now()
+	testBucket(t, s, now)
+
+	r := take(t, s, "client", now.Add(30*time.Second))
+	assert.False(t, r.Allowed, "half of token")
+	assert.InDelta(t, (30 * time.Second).Seconds(), r.RetryAfter.Seconds(), 0.1)
+
+	r = take(t, s, "client", now.Add(time.Minute))
+	assert.True(t, r.Allowed, "refilled one token")
+	assert.Equal(t, 0, r.Remaining)
+
+	r = take(t, s, "client", now.Add(time.Hour))
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 2, r.Remaining, "bucket doesn't grow beyond burst")
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := &MemoryStore{}
+	now := time.Now()
+	take(t, s, "client", now)
+	take(t, s, "other", now)
+	take(t, s, "other", now.Add(2*time.Minute))
+
+	take(t, s, "third", now.Add(2*time.Minute+sweepInterval))
+	assert.Equal(t, 2, len(s.buckets), "full client bucket is dropped")
+	_, ok := s.buckets["client"]
+	assert.False(t, ok)
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	s := &MemoryStore{}
+	now := time.Now()
+	take(t, s, "client", now)
+	for i := 0; i < 3; i++ {
+		take(t, s, "other", now)
+	}
+
+	n, err := s.Prune(3*time.Minute, now.Add(2*time.Minute))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1, len(s.buckets), "spent bucket is not full yet")
+	}
+}
+
+// idleStore records idle time buckets are pruned after
+type idleStore struct {
+	MemoryStore
+	idle time.Duration
+}
+
+func (s *idleStore) Prune(idle time.Duration, now time.Time) (int, error) {
+	s.idle = idle
+	return 0, nil
+}
+
+func TestLimiterPrune(t *testing.T) {
+	s := &idleStore{}
+	l := New(s, map[string]Limit{
+		GroupRead:  {Burst: 300, Per: time.Minute},
+		GroupWrite: {Burst: 30, Per: time.Hour},
+		GroupAdmin: {Per: 24 * time.Hour},
+	})
+	l.Prune()
+	assert.Equal(t, time.Hour, s.idle, "longest refill of limited groups")
+
+	s.idle = 0
+	New(s, map[string]Limit{GroupRead: {}}).Prune()
+	assert.Equal(t, time.Duration(0), s.idle, "no buckets without limits")
+}
+
+func TestLimiter(t *testing.T) {
+	l := New(&MemoryStore{}, map[string]Limit{
+		GroupWrite: {Burst: 1, Per: time.Minute},
+		GroupRead:  {},
+	})
+
+	r, err := l.Allow(GroupWrite, "client")
+	if assert.Nil(t, err) {
+		assert.True(t, r.Allowed)
+	}
+	r, _ = l.Allow(GroupWrite, "client")
+	assert.False(t, r.Allowed)
+
+	r, _ = l.Allow(GroupAdmin, "client")
+	assert.True(t, r.Allowed, "groups are limited separately")
+
+	for i := 0; i < 10; i++ {
+		r, _ = l.Allow(GroupRead, "client")
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 0, r.Limit, "zero limit is unlimited")
+	}
+}
+
+// TestPostgresStore runs only when ANSWER_TEST_DSN is set, see model tests
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("ANSWER_TEST_DSN")
+	if dsn == "" {
+		t.Skip("ANSWER_TEST_DSN is not set")
+	}
+
+	config, err := pgx.ParseURI(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{ConnConfig: config, MaxConnections: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, "../database/migrations")
+	if err == nil {
+		err = migrator.Up()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(`DELETE FROM answer.rate_limit`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &PostgresStore{Conn: db}
+	testBucket(t, s, time.Now())
+
+	n, err := s.Prune(time.Hour, time.Now())
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, n, "buckets are just taken from")
+	}
+	if _, err = db.Exec(`UPDATE answer.rate_limit SET updated = NOW() - INTERVAL '2 hours' WHERE key = 'client'`); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.Prune(time.Hour, time.Now())
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, n)
+	}
+}
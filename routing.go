@@ -1,21 +1,33 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/RSOI/answer/auth"
 	"github.com/RSOI/answer/controller"
+	"github.com/RSOI/answer/health"
+	"github.com/RSOI/answer/metrics"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ratelimit"
+	"github.com/RSOI/answer/tracing"
 	"github.com/RSOI/answer/ui"
 	"github.com/RSOI/answer/utils"
+	"github.com/RSOI/answer/view"
 	"github.com/buaazp/fasthttprouter"
 	"github.com/valyala/fasthttp"
 )
 
 func sendResponse(ctx *fasthttp.RequestCtx, r ui.Response, nolog ...bool) {
 	ctx.Response.Header.Set("Content-Type", "application/json")
+	r.RequestID = requestID(ctx)
 	ctx.Response.SetStatusCode(r.Status)
-	utils.LOG(fmt.Sprintf("Sending response. Status: %d", r.Status))
 
 	doLog := true
 	if len(nolog) > 0 {
@@ -23,19 +35,293 @@ func sendResponse(ctx *fasthttp.RequestCtx, r ui.Response, nolog ...bool) {
 	}
 
 	if doLog {
-		controller.LogStat(ctx.Path(), r.Status, r.Error)
+		info := model.RequestInfo{
+			Request:           string(ctx.Path()),
+			ResponseStatus:    r.Status,
+			ResponseErrorText: r.Error,
+			RequestID:         r.RequestID,
+			Method:            string(ctx.Method()),
+			Route:             routeOf(ctx),
+			Duration:          float64(time.Since(ctx.Time()).Microseconds()) / 1000,
+			Client:            clientKey(ctx),
+		}
+		if who := identity(ctx); who != nil {
+			info.APIKeyID = who.KeyID
+		}
+		controller.LogStat(tracing.Context(ctx), info)
 	}
 
 	content, _ := json.Marshal(r)
 	ctx.Write(content)
 }
 
+var (
+	// verifier checks bearer tokens
+	verifier *auth.Verifier
+	// apiKeys requires credentials even when verifier is nil.
+	// Authentication is disabled when neither is set.
+	apiKeys bool
+	// openAdmin lets anyone call admin routes while authentication is disabled
+	openAdmin bool
+)
+
+// identityKey request user value holding *auth.Identity of caller
+const identityKey = "identity"
+
+// caller finds out who sends request: service by X-API-Key header, user by bearer token, anonymous otherwise.
+// Broken credentials are reported as ui.ErrUnauthorized.
+func caller(ctx *fasthttp.RequestCtx) (*auth.Identity, error) {
+	if key := string(ctx.Request.Header.Peek("X-API-Key")); key != "" {
+		return controller.CheckAPIKey(tracing.Context(ctx), key)
+	}
+
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if header == "" {
+		return auth.Anonymous(), nil
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header || verifier == nil {
+		utils.Debug("Token rejected: bearer tokens are not accepted")
+		return nil, ui.ErrUnauthorized
+	}
+	who, err := verifier.Verify(token)
+	if err != nil {
+		utils.Debug("Token rejected", utils.Fields{"err": err})
+		return nil, ui.ErrUnauthorized
+	}
+	return who, nil
+}
+
+// authenticate puts caller identity on request context and checks that caller is allowed scope.
+// Broken credentials and anonymous requests beyond reading answers are answered with 401.
+// While authentication is disabled admin routes are answered with 403 unless openAdmin is set.
+// Rejected requests are passed to denied with error in authErrorKey user value.
+func authenticate(h fasthttp.RequestHandler, denied fasthttp.RequestHandler, scope string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if verifier == nil && !apiKeys {
+			if scope == auth.ScopeAnswersAdmin && !openAdmin {
+				utils.Debug("Admin route is closed while authentication is disabled")
+				ctx.SetUserValue(authErrorKey, ui.ErrForbidden)
+				denied(ctx)
+				return
+			}
+			h(ctx)
+			return
+		}
+
+		who, err := caller(ctx)
+		if err == nil && !who.HasScope(scope) {
+			utils.Debug("Scope is not allowed", utils.Fields{"scope": scope, "subject": who.Subject})
+			err = ui.ErrForbidden
+			if who.Subject == "" {
+				err = ui.ErrUnauthorized
+			}
+		}
+		if err != nil {
+			ctx.SetUserValue(authErrorKey, err)
+			denied(ctx)
+			return
+		}
+
+		ctx.SetUserValue(identityKey, who)
+		h(ctx)
+	}
+}
+
+// authErrorKey request user value holding error of rejected credentials
+const authErrorKey = "auth_error"
+
+// rejectCaller answers request rejected by authenticate
+func rejectCaller(ctx *fasthttp.RequestCtx) {
+	var r ui.Response
+	err, _ := ctx.UserValue(authErrorKey).(error)
+	r.Status, r.Error = ui.ErrToResponse(err)
+	if r.Status == 401 {
+		ctx.Response.Header.Set("WWW-Authenticate", "Bearer")
+	}
+	sendResponse(ctx, r)
+}
+
+// limiter limits requests of every client, nil disables limits
+var limiter *ratelimit.Limiter
+
+// routeGroups rate limit groups of route scopes
+var routeGroups = map[string]string{
+	auth.ScopeAnswersRead:  ratelimit.GroupRead,
+	auth.ScopeAnswersWrite: ratelimit.GroupWrite,
+	auth.ScopeAnswersAdmin: ratelimit.GroupAdmin,
+	auth.ScopeStatsRead:    ratelimit.GroupAdmin,
+}
+
+// clientKey tells clients apart by API key, token subject or address
+func clientKey(ctx *fasthttp.RequestCtx) string {
+	who := identity(ctx)
+	switch {
+	case who != nil && who.KeyID != 0:
+		return "key:" + strconv.Itoa(who.KeyID)
+	case who != nil && who.Subject != "":
+		return "sub:" + who.Subject
+	}
+	return "ip:" + ctx.RemoteIP().String()
+}
+
+// rateLimit answers 429 when client has spent its requests of group.
+// Requests are let through if limit store fails.
+func rateLimit(h fasthttp.RequestHandler, group string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if limiter == nil {
+			h(ctx)
+			return
+		}
+
+		res, err := limiter.Allow(group, clientKey(ctx))
+		if err != nil {
+			utils.Error("Rate limit error", utils.Fields{"err": err})
+			h(ctx)
+			return
+		}
+		if res.Limit > 0 {
+			ctx.Response.Header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			ctx.Response.Header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			ctx.Response.Header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		}
+		if !res.Allowed {
+			var r ui.Response
+			r.Status, r.Error = ui.ErrToResponse(ui.ErrTooManyRequests)
+			ctx.Response.Header.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			sendResponse(ctx, r)
+			return
+		}
+		h(ctx)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// limitKeyLookups spends keys bucket of address before API key of request is looked up,
+// so guessing keys can't flood the storage
+func limitKeyLookups(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	limited := rateLimit(h, ratelimit.GroupKeys)
+	return func(ctx *fasthttp.RequestCtx) {
+		if (verifier != nil || apiKeys) && len(ctx.Request.Header.Peek("X-API-Key")) > 0 {
+			limited(ctx)
+			return
+		}
+		h(ctx)
+	}
+}
+
+// route lets caller allowed scope to handler, within rate limit of scope group.
+// Rejected callers spend bucket of their address, so broken credentials are limited too.
+func route(h fasthttp.RequestHandler, scope string) fasthttp.RequestHandler {
+	group := routeGroups[scope]
+	return limitKeyLookups(authenticate(rateLimit(h, group), rateLimit(rejectCaller, group), scope))
+}
+
+// requestIDKey request user value holding id of request
+const requestIDKey = "request_id"
+
+// maxRequestID longer X-Request-ID headers are replaced with generated id
+const maxRequestID = 128
+
+// withRequestID takes X-Request-ID of request or generates one and echoes it in response
+func withRequestID(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		id := string(ctx.Request.Header.Peek("X-Request-ID"))
+		if id == "" || len(id) > maxRequestID || strings.ContainsAny(id, " \t\r\n") {
+			id = newRequestID()
+		}
+		ctx.SetUserValue(requestIDKey, id)
+		ctx.Response.Header.Set("X-Request-ID", id)
+		h(ctx)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns id of request, empty outside withRequestID
+func requestID(ctx *fasthttp.RequestCtx) string {
+	id, _ := ctx.UserValue(requestIDKey).(string)
+	return id
+}
+
+// routeKey request user value holding pattern of route serving request
+const routeKey = "route"
+
+// withRoute remembers pattern of route, so that usage stats are grouped by it rather than by path
+func withRoute(route string, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(routeKey, route)
+		h(ctx)
+	}
+}
+
+// routeOf returns pattern of route serving request, empty outside withRoute
+func routeOf(ctx *fasthttp.RequestCtx) string {
+	r, _ := ctx.UserValue(routeKey).(string)
+	return r
+}
+
+// accessLog logs every request of route with its outcome, server errors at warn level
+func accessLog(route string, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		h(ctx)
+
+		status := ctx.Response.StatusCode()
+		fields := utils.Fields{
+			"request_id": requestID(ctx),
+			"method":     string(ctx.Method()),
+			"route":      route,
+			"path":       string(ctx.Path()),
+			"status":     status,
+			"latency":    time.Since(start),
+			"client":     clientKey(ctx),
+		}
+		if id := tracing.TraceID(ctx); id != "" {
+			fields["trace_id"] = id
+		}
+		if status >= 500 {
+			utils.Warn("Request served", fields)
+			return
+		}
+		utils.Info("Request served", fields)
+	}
+}
+
+// handle registers handler of path pattern guarded by route, measured by metrics middleware, logged and traced
+func handle(register func(string, fasthttp.RequestHandler), path string, h fasthttp.RequestHandler, scope string) {
+	register(path, metrics.Middleware(path, withRequestID(withRoute(path, accessLog(path, tracing.Middleware(path, route(h, scope)))))))
+}
+
+// identity returns caller of request, nil when authentication is disabled
+func identity(ctx *fasthttp.RequestCtx) *auth.Identity {
+	who, _ := ctx.UserValue(identityKey).(*auth.Identity)
+	return who
+}
+
 func indexGET(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Request: Get service stats (%s)", ctx.Path()))
 	var err error
 	var r ui.Response
 
-	r.Data, err = controller.IndexGET(ctx.Host())
+	r.Data, err = controller.IndexGET(tracing.Context(ctx), ctx.Host())
+	r.Status, r.Error = ui.ErrToResponse(err)
+
+	nolog := true
+	sendResponse(ctx, r, nolog)
+}
+
+func statsGET(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.StatsGET(tracing.Context(ctx), string(ctx.QueryArgs().Peek("window")))
 	r.Status, r.Error = ui.ErrToResponse(err)
 
 	nolog := true
@@ -43,11 +329,10 @@ func indexGET(ctx *fasthttp.RequestCtx) {
 }
 
 func answerPUT(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Answer question (%s)", ctx.Path()))
 	var err error
 	var r ui.Response
 
-	r.Data, err = controller.AnswerPUT(ctx.PostBody())
+	r.Data, err = controller.AnswerPUT(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
 	r.Status, r.Error = ui.ErrToResponse(err)
 	if r.Status == 200 {
 		r.Status = 201 // REST :)
@@ -56,84 +341,308 @@ func answerPUT(ctx *fasthttp.RequestCtx) {
 }
 
 func answerGET(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Get one answer (%s)", ctx.Path()))
 	var err error
 	var r ui.Response
 
 	id := ctx.UserValue("id").(string)
-	r.Data, err = controller.AnswerGET(id)
+	includeDeleted := ctx.QueryArgs().GetBool("include_deleted")
+	r.Data, err = controller.AnswerGET(tracing.Context(ctx), id, includeDeleted, identity(ctx))
 	r.Status, r.Error = ui.ErrToResponse(err)
 	sendResponse(ctx, r)
 }
 
-func answersAuthorGET(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Request: Get answers by author id (%s)", ctx.Path()))
-	var err error
+// pageLink returns current request URI pointing to the page of cursor
+func pageLink(ctx *fasthttp.RequestCtx, cursor string) string {
+	var u fasthttp.URI
+	ctx.URI().CopyTo(&u)
+	u.QueryArgs().Set("cursor", cursor)
+	return string(u.FullURI())
+}
+
+// answersGET serves listings sharing one pagination contract:
+// limit, cursor and total query arguments, cursors in the envelope and RFC 8288 Link header
+func answersGET(ctx *fasthttp.RequestCtx, searchby string, id string, args view.AnswersArgs) {
 	var r ui.Response
 
-	aid := ctx.UserValue("authorid").(string)
-	limit := ctx.QueryArgs().Peek("limit")
-	offset := ctx.QueryArgs().Peek("limit")
-	l := 0
-	o := 0
-	if len(limit) > 0 && len(offset) > 0 {
-		l, err = strconv.Atoi(string(limit))
-		o, err = strconv.Atoi(string(offset))
+	qa := ctx.QueryArgs()
+	args.Limit = string(qa.Peek("limit"))
+	args.Cursor = string(qa.Peek("cursor"))
+	args.Total = qa.GetBool("total")
+	args.Sort = string(qa.Peek("sort"))
+	args.IsBest = string(qa.Peek("is_best"))
+	args.CreatedFrom = string(qa.Peek("created_from"))
+	args.CreatedTo = string(qa.Peek("created_to"))
+	args.IncludeDeleted = qa.GetBool("include_deleted")
+
+	page, err := controller.AnswersGET(tracing.Context(ctx), id, searchby, args, identity(ctx))
+	if err == nil {
+		r.Data = page.Answers
+		r.Total = page.Total
+
+		links := make([]string, 0, 2)
+		if page.Next != nil {
+			r.NextCursor = page.Next.String()
+			links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageLink(ctx, r.NextCursor)))
+		}
+		if page.Prev != nil {
+			r.PrevCursor = page.Prev.String()
+			links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageLink(ctx, r.PrevCursor)))
+		}
+		if len(links) > 0 {
+			ctx.Response.Header.Set("Link", strings.Join(links, ", "))
+		}
 	}
-	r.Data, err = controller.AnswersGET(aid, "author", l, o)
+
 	r.Status, r.Error = ui.ErrToResponse(err)
 	sendResponse(ctx, r)
 }
 
+func answersAuthorGET(ctx *fasthttp.RequestCtx) {
+	answersGET(ctx, "author", ctx.UserValue("authorid").(string), view.AnswersArgs{})
+}
+
 func answersQuestionGET(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Request: Get answers by question id (%s)", ctx.Path()))
+	args := view.AnswersArgs{Author: string(ctx.QueryArgs().Peek("author"))}
+	answersGET(ctx, "question", ctx.UserValue("questionid").(string), args)
+}
+
+func searchGET(ctx *fasthttp.RequestCtx) {
 	var err error
 	var r ui.Response
 
-	qid := ctx.UserValue("questionid").(string)
-	page := ctx.QueryArgs().Peek("page")
-	countOnPage := ctx.QueryArgs().Peek("conp")
-	p := 0
-	c := 0
-	if len(page) > 0 && len(countOnPage) > 0 {
-		p, err = strconv.Atoi(string(page))
-		c, err = strconv.Atoi(string(countOnPage))
-	}
-	r.Data, err = controller.AnswersGET(qid, "question", c, (p-1)*c)
+	args := ctx.QueryArgs()
+	r.Data, err = controller.SearchGET(tracing.Context(ctx),
+		string(args.Peek("q")),
+		string(args.Peek("question")),
+		string(args.Peek("author")),
+		string(args.Peek("limit")))
 	r.Status, r.Error = ui.ErrToResponse(err)
 	sendResponse(ctx, r)
 }
 
 func makeBestPATCH(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Request: Mark answer as best (%s)", ctx.Path()))
 	var err error
 	var r ui.Response
 
-	r.Data, err = controller.MakeBestPATCH(ctx.PostBody())
+	r.Data, err = controller.MakeBestPATCH(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func unmarkBestDELETE(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.UnmarkBestDELETE(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
 	r.Status, r.Error = ui.ErrToResponse(err)
 	sendResponse(ctx, r)
 }
 
 func removeDELETE(ctx *fasthttp.RequestCtx) {
-	utils.LOG(fmt.Sprintf("Request: Delete answer (%s)", ctx.Path()))
 	var err error
 	var r ui.Response
 
-	err = controller.RemoveDELETE(ctx.PostBody())
+	err = controller.RemoveDELETE(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
 	r.Status, r.Error = ui.ErrToResponse(err)
 	sendResponse(ctx, r)
 }
 
+func restorePATCH(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.RestorePATCH(tracing.Context(ctx), ctx.PostBody())
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func editPATCH(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.EditPATCH(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func rollbackPATCH(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.RollbackPATCH(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func revisionsGET(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	id := ctx.UserValue("id").(string)
+	r.Data, err = controller.RevisionsGET(tracing.Context(ctx), id)
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func revisionGET(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	id := ctx.UserValue("id").(string)
+	revision := ctx.UserValue("revision").(string)
+	r.Data, err = controller.RevisionGET(tracing.Context(ctx), id, revision)
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func votePATCH(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.VotePATCH(tracing.Context(ctx), ctx.PostBody(), identity(ctx))
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func logLevelGET(ctx *fasthttp.RequestCtx) {
+	var r ui.Response
+
+	r.Data = controller.LogLevelGET()
+	r.Status, r.Error = ui.ErrToResponse(nil)
+	sendResponse(ctx, r)
+}
+
+func logLevelPUT(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.LogLevelPUT(ctx.PostBody())
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func webhookPUT(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.WebhookPUT(ctx.PostBody())
+	r.Status, r.Error = ui.ErrToResponse(err)
+	if r.Status == 200 {
+		r.Status = 201
+	}
+	sendResponse(ctx, r)
+}
+
+func webhooksGET(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.WebhooksGET()
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func webhookDELETE(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	err = controller.WebhookDELETE(ctx.PostBody())
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func deadLettersGET(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	r.Data, err = controller.DeadLettersGET()
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+func redeliverPATCH(ctx *fasthttp.RequestCtx) {
+	var err error
+	var r ui.Response
+
+	err = controller.RedeliverPATCH(ctx.PostBody())
+	r.Status, r.Error = ui.ErrToResponse(err)
+	sendResponse(ctx, r)
+}
+
+// healthzGET answers while process is able to serve requests at all
+func healthzGET(ctx *fasthttp.RequestCtx) {
+	var r ui.Response
+
+	r.Data = map[string]string{"status": "alive"}
+	r.Status, r.Error = ui.ErrToResponse(nil)
+	nolog := true
+	sendResponse(ctx, r, nolog)
+}
+
+// readyzGET answers 503 with failed checks while instance should not get requests
+func readyzGET(ctx *fasthttp.RequestCtx) {
+	var r ui.Response
+
+	report := health.Ready(context.Background())
+	r.Data = report
+	r.Status, r.Error = ui.ErrToResponse(nil)
+	if !report.Ready {
+		r.Status = 503
+		r.Error = "service is not ready"
+	}
+	nolog := true
+	sendResponse(ctx, r, nolog)
+}
+
+func drainPUT(ctx *fasthttp.RequestCtx) {
+	var r ui.Response
+
+	health.SetDraining(true)
+	utils.Warn("Instance is draining")
+	r.Status, r.Error = ui.ErrToResponse(nil)
+	sendResponse(ctx, r)
+}
+
+func drainDELETE(ctx *fasthttp.RequestCtx) {
+	var r ui.Response
+
+	health.SetDraining(false)
+	utils.Info("Instance is ready again")
+	r.Status, r.Error = ui.ErrToResponse(nil)
+	sendResponse(ctx, r)
+}
+
 func initRoutes() *fasthttprouter.Router {
-	utils.LOG("Setup router...")
+	utils.Debug("Setup router...")
 	router := fasthttprouter.New()
-	router.GET("/", indexGET)
-	router.PUT("/answer", answerPUT)
-	router.GET("/answer/id:id", answerGET)
-	router.GET("/answers/author:authorid", answersAuthorGET)
-	router.GET("/answers/question:questionid", answersQuestionGET)
-	router.PATCH("/best", makeBestPATCH)
-	router.DELETE("/delete", removeDELETE)
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/healthz", healthzGET)
+	router.GET("/readyz", readyzGET)
+	handle(router.GET, "/", indexGET, auth.ScopeStatsRead)
+	handle(router.GET, "/stats", statsGET, auth.ScopeStatsRead)
+	handle(router.PUT, "/answer", answerPUT, auth.ScopeAnswersWrite)
+	handle(router.GET, "/answer/id:id", answerGET, auth.ScopeAnswersRead)
+	handle(router.GET, "/answer/id:id/revisions", revisionsGET, auth.ScopeAnswersRead)
+	handle(router.GET, "/answer/id:id/revisions/:revision", revisionGET, auth.ScopeAnswersRead)
+	handle(router.GET, "/answers/author:authorid", answersAuthorGET, auth.ScopeAnswersRead)
+	handle(router.GET, "/answers/question:questionid", answersQuestionGET, auth.ScopeAnswersRead)
+	handle(router.GET, "/answers/search", searchGET, auth.ScopeAnswersRead)
+	handle(router.PATCH, "/best", makeBestPATCH, auth.ScopeAnswersWrite)
+	handle(router.DELETE, "/best", unmarkBestDELETE, auth.ScopeAnswersWrite)
+	handle(router.PATCH, "/edit", editPATCH, auth.ScopeAnswersWrite)
+	handle(router.PATCH, "/rollback", rollbackPATCH, auth.ScopeAnswersWrite)
+	handle(router.PATCH, "/vote", votePATCH, auth.ScopeAnswersWrite)
+	handle(router.DELETE, "/delete", removeDELETE, auth.ScopeAnswersWrite)
+	handle(router.PATCH, "/restore", restorePATCH, auth.ScopeAnswersAdmin)
+	handle(router.GET, "/admin/log-level", logLevelGET, auth.ScopeAnswersAdmin)
+	handle(router.PUT, "/admin/log-level", logLevelPUT, auth.ScopeAnswersAdmin)
+	handle(router.PUT, "/admin/drain", drainPUT, auth.ScopeAnswersAdmin)
+	handle(router.DELETE, "/admin/drain", drainDELETE, auth.ScopeAnswersAdmin)
+	handle(router.PUT, "/admin/webhooks", webhookPUT, auth.ScopeAnswersAdmin)
+	handle(router.GET, "/admin/webhooks", webhooksGET, auth.ScopeAnswersAdmin)
+	handle(router.DELETE, "/admin/webhooks", webhookDELETE, auth.ScopeAnswersAdmin)
+	handle(router.GET, "/admin/webhooks/dead-letters", deadLettersGET, auth.ScopeAnswersAdmin)
+	handle(router.PATCH, "/admin/webhooks/redeliver", redeliverPATCH, auth.ScopeAnswersAdmin)
 
 	return router
 }
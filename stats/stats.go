@@ -0,0 +1,170 @@
+// Package stats writes service usage to storage in batches off the path of requests
+package stats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/utils"
+)
+
+// WriteFunc stores batch of stats, batch is reused after it returns
+type WriteFunc func(batch []model.RequestInfo) error
+
+// Drops counts stats which never reached storage
+type Drops struct {
+	// Overflow stats added while queue was full or writer was closed
+	Overflow uint64
+	// Failed stats of batches storage returned error for
+	Failed uint64
+}
+
+// Writer queues stats and writes them with background worker
+type Writer struct {
+	write     WriteFunc
+	queue     chan model.RequestInfo
+	batchSize int
+	interval  time.Duration
+	block     bool
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	overflow uint64
+	failed   uint64
+}
+
+// New starts worker writing stats in batches of cfg.BatchSize, a smaller batch is written every cfg.FlushInterval
+func New(write WriteFunc, cfg config.StatsConfig) *Writer {
+	w := &Writer{
+		write:     write,
+		queue:     make(chan model.RequestInfo, cfg.QueueSize),
+		batchSize: cfg.BatchSize,
+		interval:  cfg.FlushInterval,
+		block:     cfg.Overflow == "block",
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add queues stat. When queue is full stat is dropped or, with "block" overflow, Add waits for room.
+// Stats added after Close are dropped.
+func (w *Writer) Add(info model.RequestInfo) {
+	if info.RequestTime.IsZero() {
+		info.RequestTime = time.Now()
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		atomic.AddUint64(&w.overflow, 1)
+		return
+	}
+	if w.block {
+		w.queue <- info
+		return
+	}
+	select {
+	case w.queue <- info:
+	default:
+		if atomic.AddUint64(&w.overflow, 1) == 1 {
+			utils.Warn("Stats queue is full, stats are dropped", utils.Fields{"queue_size": cap(w.queue)})
+		}
+	}
+}
+
+// Pending returns number of queued stats
+func (w *Writer) Pending() int {
+	return len(w.queue)
+}
+
+// Capacity returns how many stats queue holds
+func (w *Writer) Capacity() int {
+	return cap(w.queue)
+}
+
+// Dropped returns number of stats lost since start
+func (w *Writer) Dropped() Drops {
+	return Drops{
+		Overflow: atomic.LoadUint64(&w.overflow),
+		Failed:   atomic.LoadUint64(&w.failed),
+	}
+}
+
+// Check fails while requests wait for room in full queue, dropping stats does not make instance unready
+func (w *Writer) Check(ctx context.Context) error {
+	if w.block && w.Pending() >= w.Capacity() {
+		return fmt.Errorf("stats queue is full, %d stats are waiting", w.Pending())
+	}
+	return nil
+}
+
+// Close writes queued stats and stops worker, it returns error when ctx is done first
+func (w *Writer) Close(ctx context.Context) error {
+	// Add blocked on full queue holds the lock until worker makes room
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.closed {
+			w.closed = true
+			close(w.queue)
+		}
+	}()
+
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		return fmt.Errorf("%d stats were not written: %s", w.Pending(), ctx.Err().Error())
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d stats were not written: %s", w.Pending(), ctx.Err().Error())
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]model.RequestInfo, 0, w.batchSize)
+	for {
+		select {
+		case info, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, info)
+			if len(batch) >= w.batchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		}
+	}
+}
+
+// flush writes batch and returns it emptied, failed batch is counted and logged
+func (w *Writer) flush(batch []model.RequestInfo) []model.RequestInfo {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := w.write(batch); err != nil {
+		atomic.AddUint64(&w.failed, uint64(len(batch)))
+		utils.Error("Stats are not stored", utils.Fields{"err": err, "count": len(batch)})
+	}
+	return batch[:0]
+}
@@ -0,0 +1,159 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// store records written batches, write waits for release when it is set
+type store struct {
+	mu      sync.Mutex
+	batches [][]model.RequestInfo
+	err     error
+	release chan struct{}
+}
+
+func (s *store) write(batch []model.RequestInfo) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]model.RequestInfo(nil), batch...))
+	return s.err
+}
+
+func (s *store) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := []int{}
+	for _, b := range s.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func statsConfig(queue int, batch int, interval time.Duration, overflow string) config.StatsConfig {
+	return config.StatsConfig{QueueSize: queue, BatchSize: batch, FlushInterval: interval, Overflow: overflow}
+}
+
+func TestWriterFlushesFullBatches(t *testing.T) {
+	s := &store{}
+	w := New(s.write, statsConfig(10, 2, time.Hour, "drop"))
+	for i := 0; i < 5; i++ {
+		w.Add(model.RequestInfo{Request: "/answer", ResponseStatus: 200})
+	}
+
+	assert.Nil(t, w.Close(context.Background()))
+	assert.Equal(t, []int{2, 2, 1}, s.sizes(), "rest is written on close")
+	assert.False(t, s.batches[0][0].RequestTime.IsZero())
+	assert.Equal(t, Drops{}, w.Dropped())
+}
+
+func TestWriterFlushesOnInterval(t *testing.T) {
+	s := &store{}
+	w := New(s.write, statsConfig(10, 100, 5*time.Millisecond, "drop"))
+	defer w.Close(context.Background())
+	w.Add(model.RequestInfo{Request: "/answer"})
+
+	assert.Eventually(t, func() bool { return len(s.sizes()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestWriterDropsOnOverflow(t *testing.T) {
+	s := &store{release: make(chan struct{})}
+	w := New(s.write, statsConfig(2, 1, time.Hour, "drop"))
+
+	w.Add(model.RequestInfo{Request: "/1"})
+	assert.Eventually(t, func() bool { return w.Pending() == 0 }, time.Second, time.Millisecond, "worker is writing first stat")
+	w.Add(model.RequestInfo{Request: "/2"})
+	w.Add(model.RequestInfo{Request: "/3"})
+	w.Add(model.RequestInfo{Request: "/4"})
+	assert.Equal(t, Drops{Overflow: 1}, w.Dropped())
+	assert.Nil(t, w.Check(context.Background()), "dropping stats does not fail readiness")
+
+	close(s.release)
+	assert.Nil(t, w.Close(context.Background()))
+	assert.Equal(t, []int{1, 1, 1}, s.sizes())
+
+	w.Add(model.RequestInfo{Request: "/5"})
+	assert.Equal(t, Drops{Overflow: 2}, w.Dropped(), "stats added after close are dropped")
+}
+
+func TestWriterBlocksOnOverflow(t *testing.T) {
+	s := &store{release: make(chan struct{})}
+	w := New(s.write, statsConfig(1, 1, time.Hour, "block"))
+
+	w.Add(model.RequestInfo{Request: "/1"})
+	assert.Eventually(t, func() bool { return w.Pending() == 0 }, time.Second, time.Millisecond)
+	w.Add(model.RequestInfo{Request: "/2"})
+	assert.NotNil(t, w.Check(context.Background()))
+
+	added := make(chan struct{})
+	go func() {
+		w.Add(model.RequestInfo{Request: "/3"})
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Fatal("Add has not waited for room in queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(s.release)
+	<-added
+	assert.Nil(t, w.Close(context.Background()))
+	assert.Equal(t, []int{1, 1, 1}, s.sizes())
+	assert.Equal(t, Drops{}, w.Dropped())
+}
+
+func TestWriterCountsFailedBatches(t *testing.T) {
+	s := &store{err: errors.New("connection refused")}
+	w := New(s.write, statsConfig(10, 2, time.Hour, "drop"))
+	for i := 0; i < 3; i++ {
+		w.Add(model.RequestInfo{Request: "/answer"})
+	}
+
+	assert.Nil(t, w.Close(context.Background()))
+	assert.Equal(t, Drops{Failed: 3}, w.Dropped())
+}
+
+func TestWriterCloseTimeout(t *testing.T) {
+	s := &store{release: make(chan struct{})}
+	defer close(s.release)
+	w := New(s.write, statsConfig(10, 1, time.Hour, "drop"))
+	w.Add(model.RequestInfo{Request: "/answer"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, w.Close(ctx))
+}
+
+func TestWriterCloseTimeoutWhileAddBlocks(t *testing.T) {
+	s := &store{release: make(chan struct{})}
+	defer close(s.release)
+	w := New(s.write, statsConfig(1, 1, time.Hour, "block"))
+
+	w.Add(model.RequestInfo{Request: "/1"})
+	assert.Eventually(t, func() bool { return w.Pending() == 0 }, time.Second, time.Millisecond)
+	w.Add(model.RequestInfo{Request: "/2"})
+	go w.Add(model.RequestInfo{Request: "/3"})
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() { closed <- w.Close(ctx) }()
+	select {
+	case err := <-closed:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close has not returned on context deadline")
+	}
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextKey request user value holding context with span of request
+const contextKey = "trace_context"
+
+// headerCarrier lets propagators read and write fasthttp request headers
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	c.header.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	c.header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
+
+// Middleware records server span of every request of route continuing trace of traceparent header.
+// Handlers get context with the span from Context.
+func Middleware(route string, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Method())
+		parent := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{&ctx.Request.Header})
+		c, span := tracer().Start(parent, method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", string(ctx.Path())),
+			))
+		defer span.End()
+
+		ctx.SetUserValue(contextKey, c)
+		h(ctx)
+
+		status := ctx.Response.StatusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if id := ctx.Response.Header.Peek("X-Request-ID"); len(id) > 0 {
+			span.SetAttributes(attribute.String("http.request.id", string(id)))
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(status))
+		}
+	}
+}
+
+// Context returns context carrying span of request, background one outside Middleware
+func Context(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(contextKey).(context.Context); ok {
+		return c
+	}
+	return context.Background()
+}
+
+// TraceID returns trace of request, empty if request is not traced
+func TraceID(ctx *fasthttp.RequestCtx) string {
+	sc := trace.SpanContextFromContext(Context(ctx))
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,250 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// service records span of every call of wrapped model
+type service struct {
+	ctx  context.Context
+	next model.AServiceInterface
+}
+
+// Instrument returns model recording spans of s methods as children of span in ctx
+func Instrument(ctx context.Context, s model.AServiceInterface) model.AServiceInterface {
+	return &service{ctx: ctx, next: s}
+}
+
+func (s *service) start(method string) trace.Span {
+	_, span := tracer().Start(s.ctx, "AService."+method, trace.WithSpanKind(trace.SpanKindClient))
+	return span
+}
+
+// end finishes span of call failed with err, only server side failures mark span as error
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if status, _ := ui.ErrToResponse(err); status >= 500 {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (s *service) AddAnswer(a model.Answer) (model.Answer, error) {
+	span := s.start("AddAnswer")
+	res, err := s.next.AddAnswer(a)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) DeleteAnswerByID(a model.Answer) error {
+	span := s.start("DeleteAnswerByID")
+	err := s.next.DeleteAnswerByID(a)
+	end(span, err)
+	return err
+}
+
+func (s *service) DeleteAnswerByAuthorID(a model.Answer) (int, error) {
+	span := s.start("DeleteAnswerByAuthorID")
+	res, err := s.next.DeleteAnswerByAuthorID(a)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) DeleteAnswerByQuestionID(a model.Answer) (int, error) {
+	span := s.start("DeleteAnswerByQuestionID")
+	res, err := s.next.DeleteAnswerByQuestionID(a)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) RestoreAnswer(a model.Answer) (model.Answer, error) {
+	span := s.start("RestoreAnswer")
+	res, err := s.next.RestoreAnswer(a)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) PurgeDeleted(before time.Time) (int, error) {
+	span := s.start("PurgeDeleted")
+	res, err := s.next.PurgeDeleted(before)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetAnswerByID(aID int, includeDeleted bool) (model.Answer, error) {
+	span := s.start("GetAnswerByID")
+	res, err := s.next.GetAnswerByID(aID, includeDeleted)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetAnswersByAuthorID(aAuthorID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	span := s.start("GetAnswersByAuthorID")
+	res, err := s.next.GetAnswersByAuthorID(aAuthorID, q)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetAnswersByQuestionID(aQuestionID int, q model.AnswersQuery) (model.AnswersPage, error) {
+	span := s.start("GetAnswersByQuestionID")
+	res, err := s.next.GetAnswersByQuestionID(aQuestionID, q)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) SearchAnswers(q model.SearchQuery) ([]model.SearchResult, error) {
+	span := s.start("SearchAnswers")
+	res, err := s.next.SearchAnswers(q)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) UpdateAnswer(a model.Answer) (model.BestAnswer, error) {
+	span := s.start("UpdateAnswer")
+	res, err := s.next.UpdateAnswer(a)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) UnmarkBestAnswer(a model.Answer) (model.Answer, error) {
+	span := s.start("UnmarkBestAnswer")
+	res, err := s.next.UnmarkBestAnswer(a)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) EditAnswer(r model.Revision) (model.Answer, error) {
+	span := s.start("EditAnswer")
+	res, err := s.next.EditAnswer(r)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) RollbackAnswer(r model.Revision) (model.Answer, error) {
+	span := s.start("RollbackAnswer")
+	res, err := s.next.RollbackAnswer(r)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetRevisions(aID int) ([]model.Revision, error) {
+	span := s.start("GetRevisions")
+	res, err := s.next.GetRevisions(aID)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetRevision(aID int, revision int) (model.Revision, error) {
+	span := s.start("GetRevision")
+	res, err := s.next.GetRevision(aID, revision)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) VoteAnswer(v model.Vote) (model.Answer, error) {
+	span := s.start("VoteAnswer")
+	res, err := s.next.VoteAnswer(v)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) AddAPIKey(k model.APIKey) (model.APIKey, error) {
+	span := s.start("AddAPIKey")
+	res, err := s.next.AddAPIKey(k)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetAPIKeys() ([]model.APIKey, error) {
+	span := s.start("GetAPIKeys")
+	res, err := s.next.GetAPIKeys()
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetAPIKeyByHash(hash string) (model.APIKey, error) {
+	span := s.start("GetAPIKeyByHash")
+	res, err := s.next.GetAPIKeyByHash(hash)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) RevokeAPIKey(id int) error {
+	span := s.start("RevokeAPIKey")
+	err := s.next.RevokeAPIKey(id)
+	end(span, err)
+	return err
+}
+
+func (s *service) GetUsageStatistic(host string) (model.ServiceStatus, error) {
+	span := s.start("GetUsageStatistic")
+	res, err := s.next.GetUsageStatistic(host)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) GetRouteStats(since time.Time) ([]model.RouteStat, error) {
+	span := s.start("GetRouteStats")
+	res, err := s.next.GetRouteStats(since)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) RollupStats(until time.Time) (int, error) {
+	span := s.start("RollupStats")
+	res, err := s.next.RollupStats(until)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) PurgeStats(before time.Time) (int, error) {
+	span := s.start("PurgeStats")
+	res, err := s.next.PurgeStats(before)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) ClaimEvents(limit int, lease time.Duration) ([]model.Event, error) {
+	span := s.start("ClaimEvents")
+	res, err := s.next.ClaimEvents(limit, lease)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) MarkEventsPublished(ids []string) error {
+	span := s.start("MarkEventsPublished")
+	err := s.next.MarkEventsPublished(ids)
+	end(span, err)
+	return err
+}
+
+func (s *service) RetryEvent(id string, at time.Time, reason string) error {
+	span := s.start("RetryEvent")
+	err := s.next.RetryEvent(id, at, reason)
+	end(span, err)
+	return err
+}
+
+func (s *service) PurgeEvents(before time.Time) (int, error) {
+	span := s.start("PurgeEvents")
+	res, err := s.next.PurgeEvents(before)
+	end(span, err)
+	return res, err
+}
+
+func (s *service) LogStats(stats []model.RequestInfo) error {
+	span := s.start("LogStats")
+	span.SetAttributes(attribute.Int("stats.count", len(stats)))
+	err := s.next.LogStats(stats)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// record makes spans of global provider land in returned exporter until restore is called
+func record() (spans *tracetest.InMemoryExporter, restore func()) {
+	prev := otel.GetTracerProvider()
+	spans = tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(NewProvider(sdktrace.WithSyncer(spans)))
+	return spans, func() { otel.SetTracerProvider(prev) }
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	spans, restore := record()
+	defer restore()
+
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI("/answer/id1")
+	ctx.Request.Header.Set("traceparent", traceparent)
+
+	var traceID string
+	Middleware("/answer/id:id", func(ctx *fasthttp.RequestCtx) {
+		traceID = TraceID(ctx)
+		_, err := Instrument(Context(ctx), &model.AMemoryService{}).GetAnswerByID(1, false)
+		status, _ := ui.ErrToResponse(err)
+		ctx.Response.Header.Set("X-Request-ID", "req-1")
+		ctx.SetStatusCode(status)
+	})(&ctx)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	got := spans.GetSpans()
+	if assert.Equal(t, 2, len(got)) {
+		storage, server := got[0], got[1]
+		assert.Equal(t, "GET /answer/id:id", server.Name)
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+		assert.Equal(t, "AService.GetAnswerByID", storage.Name)
+		assert.Equal(t, server.SpanContext.SpanID(), storage.Parent.SpanID())
+		assert.Equal(t, traceID, storage.SpanContext.TraceID().String())
+		assert.Equal(t, codes.Unset, storage.Status.Code, "missing answer is not a failure")
+		assert.Equal(t, 1, len(storage.Events))
+
+		attrs := map[string]interface{}{}
+		for _, a := range server.Attributes {
+			attrs[string(a.Key)] = a.Value.AsInterface()
+		}
+		assert.Equal(t, int64(404), attrs["http.response.status_code"])
+		assert.Equal(t, "req-1", attrs["http.request.id"])
+	}
+}
+
+func TestMiddlewareServerError(t *testing.T) {
+	spans, restore := record()
+	defer restore()
+
+	var ctx fasthttp.RequestCtx
+	Middleware("/", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(503)
+	})(&ctx)
+
+	got := spans.GetSpans()
+	if assert.Equal(t, 1, len(got)) {
+		assert.Equal(t, codes.Error, got[0].Status.Code)
+		assert.False(t, got[0].Parent.IsValid(), "request without traceparent starts new trace")
+	}
+}
+
+func TestContextOutsideMiddleware(t *testing.T) {
+	var ctx fasthttp.RequestCtx
+	assert.NotNil(t, Context(&ctx))
+	assert.Equal(t, "", TraceID(&ctx))
+}
+
+func TestInit(t *testing.T) {
+	shutdown, err := Init(config.TracingConfig{Exporter: "none"})
+	if assert.Nil(t, err) {
+		assert.Nil(t, shutdown(context.Background()))
+	}
+
+	_, err = Init(config.TracingConfig{Exporter: "zipkin"})
+	assert.NotNil(t, err)
+}
@@ -6,11 +6,15 @@ import (
 	"github.com/jackc/pgx"
 )
 
-// Response interface
+// Response interface. Listings also fill cursors of the neighbour pages and total count if it was requested
 type Response struct {
-	Status int         `json:"status"`
-	Error  string      `json:"error"`
-	Data   interface{} `json:"data"`
+	Status     int         `json:"status"`
+	Error      string      `json:"error"`
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+	Total      *int        `json:"total,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"`
 }
 
 var (
@@ -24,6 +28,22 @@ var (
 	ErrUnavailable = errors.New("database is unavailable")
 	// ErrFieldsRequired some of required fields are missing
 	ErrFieldsRequired = errors.New("missed required field(s)")
+	// ErrInvalidParameter some of passed values are not acceptable
+	ErrInvalidParameter = errors.New("invalid parameter value")
+	// ErrUnauthorized bearer token is missing or not valid
+	ErrUnauthorized = errors.New("authentication required")
+	// ErrForbidden caller is not allowed to do this
+	ErrForbidden = errors.New("access denied")
+	// ErrTooManyRequests client has spent its requests for now
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrQuestionNotFound answer refers to question which does not exist
+	ErrQuestionNotFound = errors.New("question not found")
+	// ErrQuestionDeleted answer refers to deleted question
+	ErrQuestionDeleted = errors.New("question is deleted")
+	// ErrQuestionLocked question does not accept new answers
+	ErrQuestionLocked = errors.New("question is locked")
+	// ErrQuestionUnavailable question can't be checked while question service is unavailable
+	ErrQuestionUnavailable = errors.New("question service is unavailable")
 )
 
 // ErrToResponse status -> error
@@ -40,6 +60,20 @@ func ErrToResponse(err error) (int, string) {
 		statusCode = 200
 	case ErrFieldsRequired:
 		statusCode = 400
+	case ErrInvalidParameter:
+		statusCode = 400
+	case ErrUnauthorized:
+		statusCode = 401
+	case ErrForbidden:
+		statusCode = 403
+	case ErrTooManyRequests:
+		statusCode = 429
+	case ErrQuestionNotFound:
+		statusCode = 422
+	case ErrQuestionDeleted:
+		statusCode = 410
+	case ErrQuestionLocked:
+		statusCode = 409
 	case pgx.ErrNoRows:
 		statusText = ErrNoResult.Error()
 		statusCode = 404
@@ -51,6 +85,8 @@ func ErrToResponse(err error) (int, string) {
 		statusCode = 404
 	case ErrUnavailable:
 		statusCode = 503
+	case ErrQuestionUnavailable:
+		statusCode = 503
 	default:
 		statusCode = 500
 		//statusText = "Server error. Additional information may be contained in server logs."
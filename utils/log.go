@@ -1,13 +1,167 @@
 package utils
 
-import "log"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-// DEBUG mode
-var DEBUG = false
+// Level log message severity
+type Level int32
 
-// LOG message
-func LOG(msg string) {
-	if DEBUG {
-		log.Println(msg)
+// Log levels, messages below current level are dropped
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns level by its name
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if n == name {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// Fields key-value pairs attached to log message
+type Fields map[string]interface{}
+
+var (
+	level  = int32(LevelInfo)
+	mu     sync.Mutex
+	out    io.Writer = os.Stderr
+	asJSON bool
+	now    = time.Now
+)
+
+// SetLevel changes minimal level of logged messages, safe to call while serving
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+// GetLevel returns minimal level of logged messages
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&level))
+}
+
+// SetOutput sets where messages are written and their format: "logfmt" or "json"
+func SetOutput(w io.Writer, format string) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+	asJSON = format == "json"
+}
+
+// Enabled reports whether messages of level l are logged
+func Enabled(l Level) bool {
+	return l >= GetLevel()
+}
+
+// Debug logs message useful while developing
+func Debug(msg string, fields ...Fields) {
+	write(LevelDebug, msg, fields)
+}
+
+// Info logs message about normal service work
+func Info(msg string, fields ...Fields) {
+	write(LevelInfo, msg, fields)
+}
+
+// Warn logs message about something unexpected the service has coped with
+func Warn(msg string, fields ...Fields) {
+	write(LevelWarn, msg, fields)
+}
+
+// Error logs failure which should be looked into
+func Error(msg string, fields ...Fields) {
+	write(LevelError, msg, fields)
+}
+
+func write(l Level, msg string, fields []Fields) {
+	if !Enabled(l) {
+		return
+	}
+
+	line := Fields{}
+	for _, f := range fields {
+		for k, v := range f {
+			line[k] = value(v)
+		}
+	}
+	line["time"] = now().UTC().Format(time.RFC3339Nano)
+	line["level"] = l.String()
+	line["msg"] = msg
+
+	mu.Lock()
+	defer mu.Unlock()
+	if asJSON {
+		content, err := json.Marshal(line)
+		if err != nil {
+			content, _ = json.Marshal(Fields{"time": line["time"], "level": line["level"], "msg": msg, "log_error": err.Error()})
+		}
+		out.Write(append(content, '\n'))
+		return
+	}
+	out.Write([]byte(logfmt(line)))
+}
+
+// value makes field printable the same way in both formats
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case []byte:
+		return string(v)
+	}
+	return v
+}
+
+// logfmt writes time, level and msg first, other fields sorted by key
+func logfmt(line Fields) string {
+	keys := make([]string, 0, len(line))
+	for k := range line {
+		if k != "time" && k != "level" && k != "msg" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{"time", "level", "msg"}, keys...)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		s := fmt.Sprint(line[k])
+		if s == "" || strings.ContainsAny(s, " =\"\\\t\n") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
 	}
+	b.WriteByte('\n')
+	return b.String()
 }
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// capture writes log into returned buffer at fixed time until restore is called
+func capture(format string) (b *bytes.Buffer, restore func()) {
+	b = &bytes.Buffer{}
+	SetOutput(b, format)
+	now = func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) }
+	oldLevel := GetLevel()
+	return b, func() {
+		SetOutput(os.Stderr, "logfmt")
+		SetLevel(oldLevel)
+		now = time.Now
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warn", "error"} {
+		l, err := ParseLevel(name)
+		if assert.Nil(t, err) {
+			assert.Equal(t, name, l.String())
+		}
+	}
+	_, err := ParseLevel("verbose")
+	assert.NotNil(t, err)
+}
+
+func TestLevelFilter(t *testing.T) {
+	b, restore := capture("logfmt")
+	defer restore()
+	SetLevel(LevelWarn)
+
+	Debug("hidden")
+	Info("hidden")
+	Warn("shown")
+	Error("shown")
+	assert.Equal(t, 2, bytes.Count(b.Bytes(), []byte("msg=shown")))
+	assert.NotContains(t, b.String(), "hidden")
+}
+
+func TestLogfmt(t *testing.T) {
+	b, restore := capture("logfmt")
+	defer restore()
+	SetLevel(LevelDebug)
+
+	Info("Request served", Fields{"status": 200, "route": "/answer/id:id", "err": errors.New("no data found"), "latency": 1500 * time.Microsecond, "empty": ""})
+	assert.Equal(t,
+		`time=2019-01-02T03:04:05Z level=info msg="Request served" empty="" err="no data found" latency=1.5ms route=/answer/id:id status=200`+"\n",
+		b.String())
+}
+
+func TestJSON(t *testing.T) {
+	b, restore := capture("json")
+	defer restore()
+	SetLevel(LevelDebug)
+
+	Error("Data error", Fields{"err": errors.New("database is unavailable"), "count": 2})
+	assert.JSONEq(t,
+		`{"time":"2019-01-02T03:04:05Z","level":"error","msg":"Data error","err":"database is unavailable","count":2}`,
+		b.String())
+}
@@ -1,8 +1,16 @@
 package view
 
 import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RSOI/answer/auth"
 	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/question"
 	"github.com/RSOI/answer/ui"
+	"github.com/RSOI/answer/webhook"
 )
 
 // ValidateNewAnswer returns nil if all the required form values are passed
@@ -17,6 +25,17 @@ func ValidateNewAnswer(data model.Answer) error {
 	return nil
 }
 
+// ValidateQuestion returns nil if question accepts new answers
+func ValidateQuestion(q question.Question) error {
+	if q.DeletedAt != nil {
+		return ui.ErrQuestionDeleted
+	}
+	if q.Locked {
+		return ui.ErrQuestionLocked
+	}
+	return nil
+}
+
 // ValidateDeleteAnswer returns true if parameter to delete found
 func ValidateDeleteAnswer(data model.Answer) (string, error) {
 	if data.ID != 0 {
@@ -41,3 +60,215 @@ func ValidateMakeBestAnswer(data model.Answer) error {
 	}
 	return ui.ErrFieldsRequired
 }
+
+// ValidateRestore returns nil if id of answer to take back from trash is passed
+func ValidateRestore(data model.Answer) error {
+	if data.ID <= 0 {
+		return ui.ErrFieldsRequired
+	}
+	return nil
+}
+
+// ValidateEditAnswer returns nil if all the required form values are passed
+func ValidateEditAnswer(data model.Revision) error {
+	if data.AnswerID == 0 ||
+		data.Content == nil ||
+		*data.Content == "" ||
+		data.EditorID == 0 {
+		return ui.ErrFieldsRequired
+	}
+	return nil
+}
+
+// ValidateRollbackAnswer returns nil if all the required form values are passed
+func ValidateRollbackAnswer(data model.Revision) error {
+	if data.AnswerID == 0 ||
+		data.Revision <= 0 ||
+		data.EditorID == 0 {
+		return ui.ErrFieldsRequired
+	}
+	return nil
+}
+
+// ValidateVote returns nil if vote is complete and its value is -1, 0 or 1
+func ValidateVote(data model.Vote) error {
+	if data.AnswerID == 0 || data.VoterID == 0 {
+		return ui.ErrFieldsRequired
+	}
+	if data.Value < -1 || data.Value > 1 {
+		return ui.ErrInvalidParameter
+	}
+	return nil
+}
+
+// ValidateAnswersOrder returns listing order, empty value means order by id
+func ValidateAnswersOrder(order string) (model.AnswersOrder, error) {
+	switch model.AnswersOrder(order) {
+	case "", model.OrderByID:
+		return model.OrderByID, nil
+	case model.OrderByScore, model.OrderByBest, model.OrderByNewest, model.OrderByOldest:
+		return model.AnswersOrder(order), nil
+	}
+	return "", ui.ErrInvalidParameter
+}
+
+// ValidatePage returns listing page request, empty cursor means the first page.
+// Limit above model.MaxPageSize is rejected.
+func ValidatePage(limit string, cursor string, withTotal bool) (model.Page, error) {
+	page := model.Page{WithTotal: withTotal}
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l > model.MaxPageSize {
+			return page, ui.ErrInvalidParameter
+		}
+		page.Limit = l
+	}
+	if cursor == "" {
+		return page, nil
+	}
+	c, err := model.ParseCursor(cursor)
+	if err != nil {
+		return page, err
+	}
+	page.Cursor = &c
+	return page, nil
+}
+
+// AnswersArgs listing query arguments as passed by client, empty values are not applied
+type AnswersArgs struct {
+	Limit          string
+	Cursor         string
+	Total          bool
+	Sort           string
+	IsBest         string
+	CreatedFrom    string
+	CreatedTo      string
+	Author         string
+	IncludeDeleted bool
+}
+
+// ValidateAnswersQuery returns listing options. Times are RFC 3339.
+func ValidateAnswersQuery(args AnswersArgs) (model.AnswersQuery, error) {
+	q := model.AnswersQuery{IncludeDeleted: args.IncludeDeleted}
+
+	var err error
+	if q.Order, err = ValidateAnswersOrder(args.Sort); err != nil {
+		return q, err
+	}
+	if q.Page, err = ValidatePage(args.Limit, args.Cursor, args.Total); err != nil {
+		return q, err
+	}
+
+	if args.IsBest != "" {
+		isBest, err := strconv.ParseBool(args.IsBest)
+		if err != nil {
+			return q, ui.ErrInvalidParameter
+		}
+		q.IsBest = &isBest
+	}
+	if args.Author != "" {
+		author, err := strconv.Atoi(args.Author)
+		if err != nil || author <= 0 {
+			return q, ui.ErrInvalidParameter
+		}
+		q.AuthorID = &author
+	}
+
+	times := []struct {
+		value string
+		t     **time.Time
+	}{
+		{args.CreatedFrom, &q.CreatedFrom},
+		{args.CreatedTo, &q.CreatedTo},
+	}
+	for _, tv := range times {
+		if tv.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, tv.value)
+		if err != nil {
+			return q, ui.ErrInvalidParameter
+		}
+		*tv.t = &t
+	}
+	return q, nil
+}
+
+// ValidateSearch returns search request, text is required
+func ValidateSearch(text string, question string, author string, limit string) (model.SearchQuery, error) {
+	q := model.SearchQuery{Text: strings.TrimSpace(text)}
+	if q.Text == "" {
+		return q, ui.ErrFieldsRequired
+	}
+
+	ints := []struct {
+		value string
+		dest  **int
+	}{
+		{question, &q.QuestionID},
+		{author, &q.AuthorID},
+	}
+	for _, iv := range ints {
+		if iv.value == "" {
+			continue
+		}
+		i, err := strconv.Atoi(iv.value)
+		if err != nil || i <= 0 {
+			return q, ui.ErrInvalidParameter
+		}
+		*iv.dest = &i
+	}
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l > model.MaxPageSize {
+			return q, ui.ErrInvalidParameter
+		}
+		q.Limit = l
+	}
+	return q, nil
+}
+
+// ValidateAPIKey checks that key has name and every scope is known
+func ValidateAPIKey(data model.APIKey) error {
+	if data.Name == "" || len(data.Scopes) == 0 {
+		return ui.ErrFieldsRequired
+	}
+	for _, scope := range data.Scopes {
+		known := false
+		for _, s := range auth.Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return ui.ErrInvalidParameter
+		}
+	}
+	return nil
+}
+
+// ValidateWebhook checks that webhook has absolute http(s) URL of public host and every event type is known
+func ValidateWebhook(data webhook.Webhook) error {
+	if data.URL == "" {
+		return ui.ErrFieldsRequired
+	}
+	u, err := url.Parse(data.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ui.ErrInvalidParameter
+	}
+	if !webhook.AllowPrivateHosts && webhook.PrivateHost(u.Hostname()) {
+		return ui.ErrInvalidParameter
+	}
+	for _, eventType := range data.EventTypes {
+		known := false
+		for _, t := range model.EventTypes {
+			known = known || t == eventType
+		}
+		if !known {
+			return ui.ErrInvalidParameter
+		}
+	}
+	if data.QuestionID != nil && *data.QuestionID <= 0 {
+		return ui.ErrInvalidParameter
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/utils"
+)
+
+var (
+	// Lease claimed deliveries are hidden from other dispatchers for timeout of post and this
+	Lease = time.Minute
+	// MinBackoff delay before the first retry, every next one doubles up to max backoff
+	MinBackoff = time.Second
+)
+
+// Dispatcher posts due deliveries of store, deliveries of one batch are posted concurrently
+type Dispatcher struct {
+	store       Store
+	client      *fasthttp.Client
+	batchSize   int
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	maxBackoff  time.Duration
+}
+
+// NewDispatcher returns dispatcher claiming batches of cfg.BatchSize every cfg.Interval
+func NewDispatcher(store Store, cfg config.WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      &fasthttp.Client{Name: "answer-webhook", Dial: dial},
+		batchSize:   cfg.BatchSize,
+		interval:    cfg.Interval,
+		timeout:     cfg.Timeout,
+		maxAttempts: cfg.MaxAttempts,
+		maxBackoff:  cfg.MaxBackoff,
+	}
+}
+
+// dial connects to addr unless its host resolves to private addresses only, see AllowPrivateHosts.
+// Names are resolved here rather than on registration, so they can't be pointed at private addresses later.
+func dial(addr string) (net.Conn, error) {
+	if AllowPrivateHosts {
+		return fasthttp.Dial(addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !privateIP(ip) {
+			return fasthttp.Dial(net.JoinHostPort(ip.String(), port))
+		}
+	}
+	return nil, fmt.Errorf("%s resolves to private addresses only", host)
+}
+
+// Backoff returns delay before retry of delivery failed attempts times
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	b := MinBackoff
+	for i := 0; i < attempts && b < d.maxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.maxBackoff {
+		b = d.maxBackoff
+	}
+	return b
+}
+
+// Post sends event of delivery to webhook signed with its secret, answers other than 2xx are errors
+func (d *Dispatcher) Post(dl Delivery) error {
+	body, err := json.Marshal(dl.Event)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(dl.URL)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.Set("X-Answer-Event", dl.Event.Type)
+	req.Header.Set("X-Answer-Delivery", dl.Event.ID)
+	req.Header.Set("X-Answer-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Answer-Signature", Sign(dl.Secret, timestamp, body))
+	req.SetBody(body)
+
+	if err := d.client.DoTimeout(req, resp, d.timeout); err != nil {
+		return err
+	}
+	if status := resp.StatusCode(); status < 200 || status > 299 {
+		return fmt.Errorf("webhook answered %d", status)
+	}
+	return nil
+}
+
+// Once posts one batch of due deliveries and returns how many were claimed.
+// Failed deliveries are retried after backoff, the ones which failed max attempts become dead letters.
+func (d *Dispatcher) Once() (int, error) {
+	deliveries, err := d.store.ClaimDeliveries(d.batchSize, d.timeout+Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, dl := range deliveries {
+		wg.Add(1)
+		go func(dl Delivery) {
+			defer wg.Done()
+			d.deliver(dl)
+		}(dl)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// deliver posts delivery and stores its outcome
+func (d *Dispatcher) deliver(dl Delivery) {
+	fields := utils.Fields{"delivery_id": dl.ID, "webhook_id": dl.WebhookID, "event_id": dl.Event.ID, "attempts": dl.Attempts + 1}
+
+	err := d.Post(dl)
+	if err == nil {
+		if err := d.store.MarkDelivered(dl.ID); err != nil {
+			fields["err"] = err
+			utils.Error("Webhook delivery is not marked delivered", fields)
+		}
+		return
+	}
+
+	fields["err"] = err
+	if dl.Attempts+1 >= d.maxAttempts {
+		utils.Warn("Webhook delivery failed for the last time", fields)
+		if err := d.store.KillDelivery(dl.ID, err.Error()); err != nil {
+			utils.Error("Webhook delivery is not moved to dead letters", utils.Fields{"delivery_id": dl.ID, "err": err})
+		}
+		return
+	}
+
+	retry := d.Backoff(dl.Attempts)
+	fields["retry_in"] = retry
+	utils.Warn("Webhook delivery failed", fields)
+	if err := d.store.RetryDelivery(dl.ID, time.Now().Add(retry), err.Error()); err != nil {
+		utils.Error("Webhook delivery retry is not stored", utils.Fields{"delivery_id": dl.ID, "err": err})
+	}
+}
+
+// Start runs Once every interval, full batches are followed by the next one at once.
+// Returned stop function waits for batch being posted.
+func (d *Dispatcher) Start() (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			n, err := d.Once()
+			if err != nil {
+				utils.Error("Webhook dispatcher error", utils.Fields{"err": err})
+			}
+			if err == nil && n == d.batchSize {
+				select {
+				case <-done:
+					return
+				default:
+					continue
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+)
+
+type pending struct {
+	Delivery
+	next      time.Time
+	lastError string
+}
+
+// MemoryStore in-process webhooks. Zero value is ready to use.
+type MemoryStore struct {
+	mu             sync.Mutex
+	webhooks       []Webhook
+	deliveries     []*pending
+	dead           []DeadLetter
+	lastWebhookID  int
+	lastDeliveryID int64
+}
+
+// AddWebhook stores w with new id
+func (s *MemoryStore) AddWebhook(w Webhook) (Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastWebhookID++
+	w.ID = s.lastWebhookID
+	w.Created = time.Now()
+	s.webhooks = append(s.webhooks, w)
+	return w, nil
+}
+
+// GetWebhooks lists webhooks without their secrets
+func (s *MemoryStore) GetWebhooks() ([]Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make([]Webhook, 0, len(s.webhooks))
+	for _, w := range s.webhooks {
+		w.Secret = ""
+		data = append(data, w)
+	}
+	return data, nil
+}
+
+// RemoveWebhook drops webhook with its deliveries and dead letters
+func (s *MemoryStore) RemoveWebhook(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.webhook(id)
+	if i < 0 {
+		return ui.ErrNoDataToDelete
+	}
+	s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+
+	deliveries := s.deliveries[:0]
+	for _, d := range s.deliveries {
+		if d.WebhookID != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	s.deliveries = deliveries
+	dead := s.dead[:0]
+	for _, d := range s.dead {
+		if d.WebhookID != id {
+			dead = append(dead, d)
+		}
+	}
+	s.dead = dead
+	return nil
+}
+
+// Enqueue adds delivery of e to every subscribed webhook
+func (s *MemoryStore) Enqueue(e model.Event) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	for _, w := range s.webhooks {
+		if !w.Matches(e) || s.queued(w.ID, e.ID) {
+			continue
+		}
+		s.lastDeliveryID++
+		s.deliveries = append(s.deliveries, &pending{
+			Delivery: Delivery{ID: s.lastDeliveryID, WebhookID: w.ID, Event: e},
+			next:     time.Now(),
+		})
+		added++
+	}
+	return added, nil
+}
+
+// ClaimDeliveries returns up to limit oldest due deliveries and hides them for lease
+func (s *MemoryStore) ClaimDeliveries(limit int, lease time.Duration) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	claimed := make([]Delivery, 0)
+	for _, d := range s.deliveries {
+		if len(claimed) >= limit {
+			break
+		}
+		if d.next.After(now) {
+			continue
+		}
+		d.next = now.Add(lease)
+		w := s.webhooks[s.webhook(d.WebhookID)]
+		c := d.Delivery
+		c.URL = w.URL
+		c.Secret = w.Secret
+		claimed = append(claimed, c)
+	}
+	return claimed, nil
+}
+
+// MarkDelivered drops delivery
+func (s *MemoryStore) MarkDelivered(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.delivery(id)
+	if i < 0 {
+		return ui.ErrNoDataToUpdate
+	}
+	s.deliveries = append(s.deliveries[:i], s.deliveries[i+1:]...)
+	return nil
+}
+
+// RetryDelivery counts failed post of delivery and postpones next one until at
+func (s *MemoryStore) RetryDelivery(id int64, at time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.delivery(id)
+	if i < 0 {
+		return ui.ErrNoDataToUpdate
+	}
+	d := s.deliveries[i]
+	d.Attempts++
+	d.next = at
+	d.lastError = reason
+	return nil
+}
+
+// KillDelivery moves delivery to dead letters
+func (s *MemoryStore) KillDelivery(id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.delivery(id)
+	if i < 0 {
+		return ui.ErrNoDataToUpdate
+	}
+	d := s.deliveries[i]
+	s.deliveries = append(s.deliveries[:i], s.deliveries[i+1:]...)
+	s.dead = append(s.dead, DeadLetter{
+		ID:        d.ID,
+		WebhookID: d.WebhookID,
+		Event:     d.Event,
+		Attempts:  d.Attempts + 1,
+		LastError: reason,
+		Failed:    time.Now(),
+	})
+	return nil
+}
+
+// GetDeadLetters lists dead letters, the oldest first
+func (s *MemoryStore) GetDeadLetters() ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append(make([]DeadLetter, 0, len(s.dead)), s.dead...), nil
+}
+
+// Redeliver moves dead letter back to deliveries due at once
+func (s *MemoryStore) Redeliver(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := -1
+	for j, d := range s.dead {
+		if d.ID == id {
+			i = j
+		}
+	}
+	if i < 0 {
+		return ui.ErrNoDataToUpdate
+	}
+	d := s.dead[i]
+	s.dead = append(s.dead[:i], s.dead[i+1:]...)
+	if s.queued(d.WebhookID, d.Event.ID) {
+		return nil
+	}
+
+	s.deliveries = append(s.deliveries, &pending{
+		Delivery: Delivery{ID: d.ID, WebhookID: d.WebhookID, Event: d.Event},
+		next:     time.Now(),
+	})
+	sort.Slice(s.deliveries, func(a, b int) bool { return s.deliveries[a].ID < s.deliveries[b].ID })
+	return nil
+}
+
+// webhook returns index of webhook with id, -1 when it is missing. Lock must be held.
+func (s *MemoryStore) webhook(id int) int {
+	for i, w := range s.webhooks {
+		if w.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// delivery returns index of delivery with id, -1 when it is missing. Lock must be held.
+func (s *MemoryStore) delivery(id int64) int {
+	for i, d := range s.deliveries {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// queued reports whether delivery of event to webhook is pending. Lock must be held.
+func (s *MemoryStore) queued(webhookID int, eventID string) bool {
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID && d.Event.ID == eventID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+)
+
+// PostgresStore webhooks shared by replicas in answer.webhook, answer.webhook_delivery
+// and answer.webhook_dead_letter tables
+type PostgresStore struct {
+	Conn *pgx.ConnPool
+}
+
+// AddWebhook stores w with new id
+func (s *PostgresStore) AddWebhook(w Webhook) (Webhook, error) {
+	if w.EventTypes == nil {
+		w.EventTypes = []string{}
+	}
+	err := s.Conn.QueryRow(`
+		INSERT INTO answer.webhook (url, secret, event_types, question_id) VALUES ($1, $2, $3, $4)
+			RETURNING id, created
+	`, w.URL, w.Secret, w.EventTypes, w.QuestionID).Scan(&w.ID, &w.Created)
+	return w, err
+}
+
+// GetWebhooks lists webhooks without their secrets
+func (s *PostgresStore) GetWebhooks() ([]Webhook, error) {
+	rows, err := s.Conn.Query(`
+		SELECT id, url, event_types, question_id, created FROM answer.webhook ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data := make([]Webhook, 0)
+	for rows.Next() {
+		var w Webhook
+		if err = rows.Scan(&w.ID, &w.URL, &w.EventTypes, &w.QuestionID, &w.Created); err != nil {
+			return nil, err
+		}
+		data = append(data, w)
+	}
+	return data, rows.Err()
+}
+
+// RemoveWebhook drops webhook, its deliveries and dead letters are dropped by cascade
+func (s *PostgresStore) RemoveWebhook(id int) error {
+	res, err := s.Conn.Exec(`DELETE FROM answer.webhook WHERE id = $1`, id)
+	if err == nil && res.RowsAffected() != 1 {
+		err = ui.ErrNoDataToDelete
+	}
+	return err
+}
+
+// Enqueue adds delivery of e to every subscribed webhook
+func (s *PostgresStore) Enqueue(e model.Event) (int, error) {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.Conn.Exec(`
+		INSERT INTO answer.webhook_delivery (webhook_id, event_id, event)
+			SELECT id, $1, $2::jsonb FROM answer.webhook
+				WHERE (cardinality(event_types) = 0 OR $3 = ANY(event_types))
+					AND (question_id IS NULL OR question_id = $4)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`, e.ID, string(content), e.Type, e.QuestionID)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.RowsAffected()), nil
+}
+
+// ClaimDeliveries returns up to limit oldest due deliveries and hides them from other dispatchers for lease
+func (s *PostgresStore) ClaimDeliveries(limit int, lease time.Duration) ([]Delivery, error) {
+	rows, err := s.Conn.Query(`
+		WITH claimed AS (
+			UPDATE answer.webhook_delivery SET next_attempt = NOW() + $2 * INTERVAL '1 millisecond'
+				WHERE id IN (
+					SELECT id FROM answer.webhook_delivery WHERE next_attempt <= NOW()
+						ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, webhook_id, event::text, attempts
+		)
+		SELECT c.id, c.webhook_id, w.url, w.secret, c.event, c.attempts
+			FROM claimed c JOIN answer.webhook w ON w.id = c.webhook_id ORDER BY c.id
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		var d Delivery
+		var event string
+		if err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &event, &d.Attempts); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(event), &d.Event); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// MarkDelivered drops delivery
+func (s *PostgresStore) MarkDelivered(id int64) error {
+	res, err := s.Conn.Exec(`DELETE FROM answer.webhook_delivery WHERE id = $1`, id)
+	if err == nil && res.RowsAffected() != 1 {
+		err = ui.ErrNoDataToUpdate
+	}
+	return err
+}
+
+// RetryDelivery counts failed post of delivery and postpones next one until at
+func (s *PostgresStore) RetryDelivery(id int64, at time.Time, reason string) error {
+	res, err := s.Conn.Exec(`
+		UPDATE answer.webhook_delivery SET attempts = attempts + 1, next_attempt = $2, last_error = $3
+			WHERE id = $1
+	`, id, at, reason)
+	if err == nil && res.RowsAffected() != 1 {
+		err = ui.ErrNoDataToUpdate
+	}
+	return err
+}
+
+// KillDelivery moves delivery to dead letters
+func (s *PostgresStore) KillDelivery(id int64, reason string) error {
+	res, err := s.Conn.Exec(`
+		WITH dead AS (
+			DELETE FROM answer.webhook_delivery WHERE id = $1
+				RETURNING id, webhook_id, event_id, event, attempts
+		)
+		INSERT INTO answer.webhook_dead_letter (id, webhook_id, event_id, event, attempts, last_error)
+			SELECT id, webhook_id, event_id, event, attempts + 1, $2 FROM dead
+	`, id, reason)
+	if err == nil && res.RowsAffected() != 1 {
+		err = ui.ErrNoDataToUpdate
+	}
+	return err
+}
+
+// GetDeadLetters lists dead letters, the oldest first
+func (s *PostgresStore) GetDeadLetters() ([]DeadLetter, error) {
+	rows, err := s.Conn.Query(`
+		SELECT id, webhook_id, event::text, attempts, COALESCE(last_error, ''), failed
+			FROM answer.webhook_dead_letter ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data := make([]DeadLetter, 0)
+	for rows.Next() {
+		var d DeadLetter
+		var event string
+		if err = rows.Scan(&d.ID, &d.WebhookID, &event, &d.Attempts, &d.LastError, &d.Failed); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(event), &d.Event); err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
+
+// Redeliver moves dead letter back to deliveries due at once.
+// Dead letter of event which is pending again is just dropped.
+func (s *PostgresStore) Redeliver(id int64) error {
+	var n int
+	err := s.Conn.QueryRow(`
+		WITH revived AS (
+			DELETE FROM answer.webhook_dead_letter WHERE id = $1
+				RETURNING id, webhook_id, event_id, event
+		), requeued AS (
+			INSERT INTO answer.webhook_delivery (id, webhook_id, event_id, event)
+				SELECT id, webhook_id, event_id, event FROM revived
+			ON CONFLICT DO NOTHING
+		)
+		SELECT COUNT(*) FROM revived
+	`, id).Scan(&n)
+	if err == nil && n != 1 {
+		err = ui.ErrNoDataToUpdate
+	}
+	return err
+}
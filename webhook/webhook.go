@@ -0,0 +1,147 @@
+// Package webhook delivers answer events to URLs registered by other services.
+// Outbox relay hands events to Publisher, which adds a delivery for every subscribed webhook.
+// Dispatcher posts deliveries signed with secret of webhook, retries failed ones with backoff
+// and moves them to dead letters after the last attempt.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/utils"
+)
+
+// AllowPrivateHosts lets webhooks post to loopback and link-local addresses. It is off by default,
+// so registered URLs can't reach the host itself or cloud metadata endpoints.
+var AllowPrivateHosts bool
+
+// PrivateHost reports whether host is localhost or a loopback, link-local or unspecified address
+func PrivateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && privateIP(ip)
+}
+
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// Webhook URL receiving events of EventTypes (every type when empty) of answers to QuestionID (every question when nil)
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"events"`
+	QuestionID *int      `json:"question_id,omitempty"`
+	Created    time.Time `json:"created"`
+	// Secret signs deliveries, it is shown only once webhook is registered
+	Secret string `json:"secret,omitempty"`
+}
+
+// Matches reports whether webhook is subscribed to e
+func (w Webhook) Matches(e model.Event) bool {
+	if w.QuestionID != nil && *w.QuestionID != e.QuestionID {
+		return false
+	}
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery event waiting to be posted to webhook
+type Delivery struct {
+	ID        int64
+	WebhookID int
+	URL       string
+	Secret    string
+	Event     model.Event
+	// Attempts failed posts of delivery
+	Attempts int
+}
+
+// DeadLetter delivery which failed every attempt, it is posted again once redelivered
+type DeadLetter struct {
+	ID        int64       `json:"id"`
+	WebhookID int         `json:"webhook_id"`
+	Event     model.Event `json:"event"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"last_error"`
+	Failed    time.Time   `json:"failed"`
+}
+
+// Store keeps webhooks and their deliveries. Errors of missing webhooks and dead letters are ui ones.
+type Store interface {
+	AddWebhook(w Webhook) (Webhook, error)
+	GetWebhooks() ([]Webhook, error)
+	// RemoveWebhook drops webhook with its deliveries and dead letters
+	RemoveWebhook(id int) error
+
+	// Enqueue adds delivery of e to every subscribed webhook and returns how many were added.
+	// Delivery of event which is already pending is not added again.
+	Enqueue(e model.Event) (int, error)
+	// ClaimDeliveries returns up to limit oldest due deliveries and hides them from other dispatchers for lease
+	ClaimDeliveries(limit int, lease time.Duration) ([]Delivery, error)
+	MarkDelivered(id int64) error
+	RetryDelivery(id int64, at time.Time, reason string) error
+	// KillDelivery moves delivery to dead letters
+	KillDelivery(id int64, reason string) error
+
+	GetDeadLetters() ([]DeadLetter, error)
+	// Redeliver moves dead letter back to deliveries due at once
+	Redeliver(id int64) error
+}
+
+// Publisher adds deliveries of published events, it is the outbox.Publisher of webhooks
+type Publisher struct {
+	Store Store
+}
+
+// Publish implements outbox.Publisher
+func (p Publisher) Publish(ctx context.Context, e model.Event) error {
+	n, err := p.Store.Enqueue(e)
+	if err != nil {
+		return err
+	}
+	utils.Debug("Event is queued for webhooks", utils.Fields{"event_id": e.ID, "type": e.Type, "webhooks": n})
+	return nil
+}
+
+// NewSecret returns random secret of webhook
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns X-Answer-Signature of body posted at timestamp (unix seconds):
+// "sha256=" followed by hex HMAC-SHA256 of "timestamp.body" keyed by secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature of body posted at timestamp, receivers should also reject old timestamps
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
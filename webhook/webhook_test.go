@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RSOI/answer/config"
+	"github.com/RSOI/answer/model"
+	"github.com/RSOI/answer/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver records events posted with valid signature, answering status while it is set
+type receiver struct {
+	mu     sync.Mutex
+	secret string
+	events []model.Event
+	status int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get("X-Answer-Timestamp"), 10, 64)
+	if !Verify(rc.secret, timestamp, body, r.Header.Get("X-Answer-Signature")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rc.status != 0 {
+		w.WriteHeader(rc.status)
+		return
+	}
+	var e model.Event
+	json.Unmarshal(body, &e)
+	if r.Header.Get("X-Answer-Event") != e.Type || r.Header.Get("X-Answer-Delivery") != e.ID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.events = append(rc.events, e)
+}
+
+func (rc *receiver) received() []model.Event {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]model.Event(nil), rc.events...)
+}
+
+func (rc *receiver) answer(status int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.status = status
+}
+
+// allowPrivateHosts lets dispatcher post to receivers on loopback
+func allowPrivateHosts() func() {
+	AllowPrivateHosts = true
+	return func() { AllowPrivateHosts = false }
+}
+
+func webhookConfig(attempts int) config.WebhookConfig {
+	return config.WebhookConfig{BatchSize: 10, Interval: time.Hour, Timeout: time.Second, MaxAttempts: attempts, MaxBackoff: time.Minute}
+}
+
+func event(id string, eventType string, questionID int) model.Event {
+	return model.Event{ID: id, Type: eventType, Version: model.EventVersion, AnswerID: 1, QuestionID: questionID,
+		Payload: json.RawMessage(`{"id":1}`), Created: time.Now()}
+}
+
+// subscribe registers webhook of new receiver
+func subscribe(t *testing.T, s Store, w Webhook) (*receiver, *httptest.Server) {
+	rc := &receiver{secret: "secret"}
+	server := httptest.NewServer(rc)
+	w.URL = server.URL
+	w.Secret = rc.secret
+	if _, err := s.AddWebhook(w); err != nil {
+		t.Fatal(err)
+	}
+	return rc, server
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.True(t, Verify("secret", 1700000000, body, signature))
+	assert.False(t, Verify("other", 1700000000, body, signature))
+	assert.False(t, Verify("secret", 1700000001, body, signature), "timestamp is signed")
+	assert.False(t, Verify("secret", 1700000000, []byte(`{"id":"2"}`), signature))
+}
+
+func TestPublisherEnqueuesSubscribed(t *testing.T) {
+	s := &MemoryStore{}
+	question := 7
+	s.AddWebhook(Webhook{URL: "http://all"})
+	s.AddWebhook(Webhook{URL: "http://best", EventTypes: []string{model.EventAnswerBestMarked}})
+	s.AddWebhook(Webhook{URL: "http://question", QuestionID: &question})
+	pub := Publisher{Store: s}
+
+	assert.Nil(t, pub.Publish(context.Background(), event("1", model.EventAnswerCreated, 7)))
+	assert.Nil(t, pub.Publish(context.Background(), event("2", model.EventAnswerBestMarked, 8)))
+	assert.Nil(t, pub.Publish(context.Background(), event("2", model.EventAnswerBestMarked, 8)), "event published again")
+
+	claimed, err := s.ClaimDeliveries(10, time.Minute)
+	assert.Nil(t, err)
+	targets := []string{}
+	for _, d := range claimed {
+		targets = append(targets, d.Event.ID+" "+d.URL)
+	}
+	assert.Equal(t, []string{"1 http://all", "1 http://question", "2 http://all", "2 http://best"}, targets)
+
+	claimed, _ = s.ClaimDeliveries(10, time.Minute)
+	assert.Equal(t, 0, len(claimed), "claimed deliveries are hidden for lease")
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	defer allowPrivateHosts()()
+	s := &MemoryStore{}
+	rc, server := subscribe(t, s, Webhook{})
+	defer server.Close()
+	s.Enqueue(event("1", model.EventAnswerCreated, 1))
+	s.Enqueue(event("2", model.EventAnswerDeleted, 1))
+
+	n, err := NewDispatcher(s, webhookConfig(3)).Once()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	received := rc.received()
+	if assert.Equal(t, 2, len(received)) {
+		assert.ElementsMatch(t, []string{"1", "2"}, []string{received[0].ID, received[1].ID})
+		assert.JSONEq(t, `{"id":1}`, string(received[0].Payload))
+	}
+
+	claimed, _ := s.ClaimDeliveries(10, 0)
+	assert.Equal(t, 0, len(claimed), "delivered deliveries are dropped")
+}
+
+func TestDispatcherRetriesAndKills(t *testing.T) {
+	defer allowPrivateHosts()()
+	s := &MemoryStore{}
+	rc, server := subscribe(t, s, Webhook{})
+	defer server.Close()
+	rc.answer(http.StatusInternalServerError)
+	s.Enqueue(event("1", model.EventAnswerCreated, 1))
+
+	prev := MinBackoff
+	MinBackoff = 0
+	defer func() { MinBackoff = prev }()
+
+	d := NewDispatcher(s, webhookConfig(2))
+	n, _ := d.Once()
+	assert.Equal(t, 1, n)
+	dead, _ := s.GetDeadLetters()
+	assert.Equal(t, 0, len(dead), "failed delivery is retried")
+
+	n, _ = d.Once()
+	assert.Equal(t, 1, n)
+	dead, _ = s.GetDeadLetters()
+	if assert.Equal(t, 1, len(dead)) {
+		assert.Equal(t, 2, dead[0].Attempts)
+		assert.Equal(t, "webhook answered 500", dead[0].LastError)
+		assert.Equal(t, "1", dead[0].Event.ID)
+	}
+	n, _ = d.Once()
+	assert.Equal(t, 0, n, "dead letters are not posted")
+
+	rc.answer(0)
+	assert.Nil(t, s.Redeliver(dead[0].ID))
+	assert.Equal(t, ui.ErrNoDataToUpdate, s.Redeliver(dead[0].ID))
+	n, _ = d.Once()
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, len(rc.received()), "redelivered dead letter is posted")
+	dead, _ = s.GetDeadLetters()
+	assert.Equal(t, 0, len(dead))
+}
+
+func TestDispatcherTimeout(t *testing.T) {
+	defer allowPrivateHosts()()
+	s := &MemoryStore{}
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	s.AddWebhook(Webhook{URL: server.URL, Secret: "secret"})
+	s.Enqueue(event("1", model.EventAnswerCreated, 1))
+
+	cfg := webhookConfig(1)
+	cfg.Timeout = 20 * time.Millisecond
+	n, _ := NewDispatcher(s, cfg).Once()
+	assert.Equal(t, 1, n)
+	dead, _ := s.GetDeadLetters()
+	assert.Equal(t, 1, len(dead), "receiver which does not answer in time fails delivery")
+}
+
+func TestPrivateHost(t *testing.T) {
+	for _, host := range []string{"localhost", "api.localhost", "LOCALHOST.", "127.0.0.1", "::1", "169.254.169.254", "fe80::1", "0.0.0.0"} {
+		assert.True(t, PrivateHost(host), host)
+	}
+	for _, host := range []string{"questions.local", "10.0.0.1", "93.184.216.34", "2001:db8::1"} {
+		assert.False(t, PrivateHost(host), host)
+	}
+}
+
+func TestDispatcherRefusesPrivateHosts(t *testing.T) {
+	s := &MemoryStore{}
+	rc, server := subscribe(t, s, Webhook{})
+	defer server.Close()
+	s.Enqueue(event("1", model.EventAnswerCreated, 1))
+
+	NewDispatcher(s, webhookConfig(1)).Once()
+	assert.Equal(t, 0, len(rc.received()), "receiver on loopback is not posted to")
+	dead, _ := s.GetDeadLetters()
+	if assert.Equal(t, 1, len(dead)) {
+		assert.Contains(t, dead[0].LastError, "private addresses")
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(&MemoryStore{}, webhookConfig(10))
+
+	assert.Equal(t, time.Second, d.Backoff(0))
+	assert.Equal(t, 8*time.Second, d.Backoff(3))
+	assert.Equal(t, time.Minute, d.Backoff(20), "backoff is limited")
+}
+
+func TestRemoveWebhook(t *testing.T) {
+	s := &MemoryStore{}
+	w, _ := s.AddWebhook(Webhook{URL: "http://receiver", Secret: "secret"})
+	s.Enqueue(event("1", model.EventAnswerCreated, 1))
+
+	list, _ := s.GetWebhooks()
+	if assert.Equal(t, 1, len(list)) {
+		assert.Equal(t, "", list[0].Secret, "secret is not listed")
+	}
+	assert.Nil(t, s.RemoveWebhook(w.ID))
+	assert.Equal(t, ui.ErrNoDataToDelete, s.RemoveWebhook(w.ID))
+	claimed, _ := s.ClaimDeliveries(10, 0)
+	assert.Equal(t, 0, len(claimed), "deliveries of removed webhook are dropped")
+}